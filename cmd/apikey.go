@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/application"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// newAPIKeyCommand builds the admin command managing the API keys stored into
+// the configured database. getConfig provides the loaded configuration.
+func newAPIKeyCommand(getConfig func() *config.Config) *cobra.Command {
+	apikeyCmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage the API keys granting access to the scans of a company",
+	}
+
+	var name, company string
+	var scopes, roles []string
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an API key and print it once",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAuthUsecase(getConfig(), func(ctx context.Context, uc *application.AuthUsecase) error {
+				if len(scopes) == 0 && len(roles) == 0 {
+					scopes = []string{domain.ScopeRead}
+				}
+
+				raw, key, err := uc.CreateAPIKey(ctx, name, company, scopes, roles)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "id:      %s\ncompany: %s\nscopes:  %s\nroles:   %s\nkey:     %s\n\nstore the key safely. it cannot be displayed again.\n",
+					key.ID, key.CompanyID, strings.Join(key.Scopes, ","), strings.Join(key.Roles, ","), raw)
+				return nil
+			})
+		},
+	}
+	createCmd.Flags().StringVar(&name, "name", "", "description of the key owner")
+	createCmd.Flags().StringVar(&company, "company", "", "company whose scans the key grants access to")
+	createCmd.Flags().StringSliceVar(&scopes, "scopes", nil, "granted scopes among read, write and delete (default read when no role is granted)")
+	createCmd.Flags().StringSliceVar(&roles, "roles", nil, "granted roles among viewer, submitter, maintainer and admin")
+	_ = createCmd.MarkFlagRequired("company")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the API keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAuthUsecase(getConfig(), func(ctx context.Context, uc *application.AuthUsecase) error {
+				keys, err := uc.ListAPIKeys(ctx)
+				if err != nil {
+					return err
+				}
+
+				tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+				fmt.Fprintln(tw, "ID\tNAME\tCOMPANY\tSCOPES\tROLES\tCREATED\tREVOKED")
+				for _, k := range keys {
+					revoked := "-"
+					if k.Revoked() {
+						revoked = k.RevokedAt.Format(time.RFC3339)
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, k.CompanyID, strings.Join(k.Scopes, ","), strings.Join(k.Roles, ","), k.CreatedAt.Format(time.RFC3339), revoked)
+				}
+				return tw.Flush()
+			})
+		},
+	}
+
+	revokeCmd := &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Revoke an API key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withAuthUsecase(getConfig(), func(ctx context.Context, uc *application.AuthUsecase) error {
+				if err := uc.RevokeAPIKey(ctx, args[0]); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "api key %s revoked.\n", args[0])
+				return nil
+			})
+		},
+	}
+
+	apikeyCmd.AddCommand(createCmd, listCmd, revokeCmd)
+	return apikeyCmd
+}
+
+// withAuthUsecase connects to the configured database for the duration of fn.
+func withAuthUsecase(configData *config.Config, fn func(context.Context, *application.AuthUsecase) error) error {
+	switch configData.Database {
+	case "mockdb", "memory":
+		return fmt.Errorf("api keys of the %s database only live into the server process. use postgres or mongo", configData.Database)
+	}
+
+	logger := zap.NewNop()
+	store, err := openStorage(logger, configData)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	defer func() {
+		if err := store.handler.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to disconnect from database: %v\n", err)
+		}
+	}()
+
+	return fn(ctx, application.NewAuthUsecase(logger, config.NewLive(configData), store.apiKeys, nil))
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// newConfigCommand builds the command inspecting the configuration. getConfig
+// provides the loaded configuration.
+func newConfigCommand(getConfig func() *config.Config) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration and print the effective settings with the secrets masked",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := getConfig().Validate(); err != nil {
+				return fmt.Errorf("%s: %w", viper.ConfigFileUsed(), err)
+			}
+
+			for _, warning := range getConfig().Warnings() {
+				fmt.Fprintf(cmd.ErrOrStderr(), "warning: %s\n", warning)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "# %s is valid. effective settings:\n", viper.ConfigFileUsed())
+			enc := yaml.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent(2)
+			if err := enc.Encode(config.MaskedSettings()); err != nil {
+				return err
+			}
+			return enc.Close()
+		},
+	}
+
+	configCmd.AddCommand(validateCmd)
+	return configCmd
+}
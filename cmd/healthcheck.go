@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/spf13/cobra"
+)
+
+// newHealthcheckCommand builds the command probing the readiness of a running
+// server, for example from a docker HEALTHCHECK. It fails when the server is
+// not ready. getConfig provides the loaded configuration.
+func newHealthcheckCommand(getConfig func() *config.Config) *cobra.Command {
+	var url string
+	var timeout time.Duration
+	var insecure bool
+	healthcheckCmd := &cobra.Command{
+		Use:   "healthcheck",
+		Short: "Check that the server is ready to receive traffic",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if url == "" {
+				url = readinessURL(getConfig())
+			}
+
+			client := &http.Client{
+				Timeout: timeout,
+				Transport: &http.Transport{
+					// the local server certificate is usually not issued for the loopback address.
+					TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}, // nolint:gosec
+				},
+			}
+
+			resp, err := client.Get(url)
+			if err != nil {
+				return fmt.Errorf("readiness probe failed: %w", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("server not ready: %s", resp.Status)
+			}
+
+			fmt.Fprintln(cmd.OutOrStdout(), "server ready.")
+			return nil
+		},
+	}
+
+	healthcheckCmd.Flags().StringVar(&url, "url", "", "readiness endpoint to probe (default is /readyz of the configured server)")
+	healthcheckCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Second, "maximum duration of the probe")
+	healthcheckCmd.Flags().BoolVar(&insecure, "insecure", true, "skip the verification of the server certificate")
+	return healthcheckCmd
+}
+
+// readinessURL is the readiness endpoint of the configured server. The
+// loopback address is used when the server listens on all interfaces.
+func readinessURL(configData *config.Config) string {
+	host := configData.Server.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("https://%s/readyz", net.JoinHostPort(host, configData.Server.Port))
+}
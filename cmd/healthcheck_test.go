@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthcheckCommand(t *testing.T) {
+	status := http.StatusOK
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/readyz", r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	healthcheck := func() (string, error) {
+		cmd := newHealthcheckCommand(func() *config.Config { return &config.Config{} })
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs([]string{"--url", srv.URL + "/readyz"})
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := healthcheck()
+	assert.NoError(t, err)
+	assert.Equal(t, "server ready.\n", out)
+
+	status = http.StatusServiceUnavailable
+	_, err = healthcheck()
+	assert.EqualError(t, err, "server not ready: 503 Service Unavailable")
+
+	configData := &config.Config{}
+	configData.Server.Host, configData.Server.Port = "0.0.0.0", "8080"
+	assert.Equal(t, "https://127.0.0.1:8080/readyz", readinessURL(configData))
+	configData.Server.Host = "api.local"
+	assert.Equal(t, "https://api.local:8080/readyz", readinessURL(configData))
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultStopTimeout bounds the stop of a component added without timeout.
+const defaultStopTimeout = 5 * time.Second
+
+// component is a part of the service owned by the lifecycle. Run blocks while
+// the component serves and is nil for the components which only need to be
+// stopped. Stop must make Run return and is bounded by the timeout.
+type component struct {
+	name    string
+	run     func() error
+	stop    func(ctx context.Context) error
+	timeout time.Duration
+}
+
+// lifecycle runs the components of the service until a stop signal or until
+// one of them fails, then stops them in the reverse order of their addition.
+// So the components must be added after the ones they depend on.
+type lifecycle struct {
+	logger     *zap.Logger
+	components []component
+	reloaders  []reloader
+}
+
+// reloader reads again a part of the settings on SIGHUP.
+type reloader struct {
+	name   string
+	reload func() error
+}
+
+func newLifecycle(logger *zap.Logger) *lifecycle {
+	return &lifecycle{logger: logger}
+}
+
+// add registers a component. Its stop is skipped when nil.
+func (l *lifecycle) add(c component) {
+	l.components = append(l.components, c)
+}
+
+// onReload registers a function called on SIGHUP.
+func (l *lifecycle) onReload(name string, reload func() error) {
+	l.reloaders = append(l.reloaders, reloader{name: name, reload: reload})
+}
+
+// run starts the running components then waits for SIGINT or SIGTERM to stop
+// them all. SIGHUP triggers the reloaders without stopping. It returns nil
+// when the service was stopped by a signal and every component stopped in
+// time, otherwise the reason of the failure.
+func (l *lifecycle) run(signals <-chan os.Signal) error {
+	exited := make(chan componentExit, len(l.components))
+	for _, c := range l.components {
+		if c.run == nil {
+			continue
+		}
+
+		go func(c component) {
+			exited <- componentExit{name: c.name, err: c.run()}
+		}(c)
+	}
+
+	var cause error
+	for cause == nil {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				l.reload()
+				continue
+			}
+			l.logger.Info("stop signal received. shutting down all services...", zap.String("signal", sig.String()))
+			return l.stop()
+
+		case exit := <-exited:
+			cause = fmt.Errorf("%s stopped unexpectedly", exit.name)
+			if exit.err != nil {
+				cause = fmt.Errorf("%s failed: %w", exit.name, exit.err)
+			}
+		}
+	}
+
+	l.logger.Error("shutting down all services after a failure", zap.Error(cause))
+	if err := l.stop(); err != nil {
+		return fmt.Errorf("%v. %w", cause, err)
+	}
+	return cause
+}
+
+type componentExit struct {
+	name string
+	err  error
+}
+
+// reload calls every reloader. A failed reload is logged and leaves the
+// settings in use.
+func (l *lifecycle) reload() {
+	l.logger.Info("reload signal received. reloading settings...")
+	for _, r := range l.reloaders {
+		if err := r.reload(); err != nil {
+			l.logger.Error("settings reload failed", zap.String("component", r.name), zap.Error(err))
+			continue
+		}
+		l.logger.Info("settings reloaded", zap.String("component", r.name))
+	}
+}
+
+// stop stops the components in the reverse order of their addition, each
+// within its own timeout. A component which does not stop in time is given up
+// so that the following ones are still stopped.
+func (l *lifecycle) stop() error {
+	var failures []string
+	for i := len(l.components) - 1; i >= 0; i-- {
+		c := l.components[i]
+		if c.stop == nil {
+			continue
+		}
+
+		start := time.Now()
+		if err := stopWithin(c); err != nil {
+			l.logger.Error("platform shutdown: error stopping component", zap.String("component", c.name), zap.Error(err))
+			failures = append(failures, fmt.Sprintf("%s: %v", c.name, err))
+			continue
+		}
+		l.logger.Info("platform shutdown: component stopped", zap.String("component", c.name), zap.Duration("duration", time.Since(start)))
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("shutdown incomplete: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// stopWithin calls the stop of c without waiting for it beyond its timeout.
+func stopWithin(c component) error {
+	if c.timeout <= 0 {
+		c.timeout = defaultStopTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- c.stop(ctx) }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("not stopped after %s", c.timeout)
+	}
+}
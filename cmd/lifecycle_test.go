@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestLifecycle(t *testing.T) {
+	// server blocks until stopped like an http server.
+	server := func(name string, stopped *[]string) component {
+		done := make(chan struct{})
+		return component{
+			name: name,
+			run:  func() error { <-done; return nil },
+			stop: func(ctx context.Context) error {
+				*stopped = append(*stopped, name)
+				close(done)
+				return nil
+			},
+			timeout: time.Second,
+		}
+	}
+
+	worker := func(name string, stopped *[]string) component {
+		return component{name: name, stop: func(ctx context.Context) error {
+			*stopped = append(*stopped, name)
+			return nil
+		}, timeout: time.Second}
+	}
+
+	t.Run("should pass: stop signal stops in reverse order", func(t *testing.T) {
+		var stopped []string
+		lc := newLifecycle(zap.NewNop())
+		lc.add(worker("database", &stopped))
+		lc.add(worker("watcher", &stopped))
+		lc.add(server("server", &stopped))
+
+		signals := make(chan os.Signal, 1)
+		signals <- syscall.SIGTERM
+		assert.NoError(t, lc.run(signals))
+		assert.Equal(t, []string{"server", "watcher", "database"}, stopped)
+	})
+
+	t.Run("should pass: reload signal keeps running", func(t *testing.T) {
+		var stopped []string
+		reloads := 0
+		lc := newLifecycle(zap.NewNop())
+		lc.add(server("server", &stopped))
+		lc.onReload("config", func() error { reloads++; return nil })
+		lc.onReload("certificate", func() error { return errors.New("cannot load server certificate") })
+
+		signals := make(chan os.Signal, 2)
+		signals <- syscall.SIGHUP
+		signals <- syscall.SIGINT
+		assert.NoError(t, lc.run(signals))
+		assert.Equal(t, 1, reloads)
+		assert.Equal(t, []string{"server"}, stopped)
+	})
+
+	t.Run("should fail: failed component stops the others", func(t *testing.T) {
+		var stopped []string
+		lc := newLifecycle(zap.NewNop())
+		lc.add(worker("database", &stopped))
+		lc.add(component{name: "server", run: func() error { return errors.New("address already in use") }})
+
+		err := lc.run(make(chan os.Signal))
+		assert.EqualError(t, err, "server failed: address already in use")
+		assert.Equal(t, []string{"database"}, stopped)
+	})
+
+	t.Run("should fail: slow component is given up", func(t *testing.T) {
+		var stopped []string
+		lc := newLifecycle(zap.NewNop())
+		lc.add(worker("database", &stopped))
+		lc.add(component{name: "server", stop: func(ctx context.Context) error {
+			time.Sleep(time.Second) // ignores the deadline.
+			return nil
+		}, timeout: 20 * time.Millisecond})
+
+		signals := make(chan os.Signal, 1)
+		signals <- syscall.SIGTERM
+		start := time.Now()
+		err := lc.run(signals)
+		assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+		assert.EqualError(t, err, "shutdown incomplete: server: not stopped after 20ms")
+		assert.Equal(t, []string{"database"}, stopped)
+	})
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/jeamon/backend-api/pkg/infrastructure/postgres"
+	"github.com/spf13/cobra"
+)
+
+// newMigrateCommand builds the admin command managing the schema of the
+// postgres database. getConfig provides the loaded configuration.
+func newMigrateCommand(getConfig func() *config.Config) *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the schema migrations of the postgres database",
+	}
+
+	upCmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply all the pending migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrations(getConfig(), func(db *sql.DB, path string) error {
+				if err := postgres.Migrate(db, path, "up"); err != nil {
+					return err
+				}
+				return printVersion(cmd.OutOrStdout(), db, path)
+			})
+		},
+	}
+
+	var confirmed bool
+	downCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Revert all the applied migrations",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !confirmed {
+				return errors.New("reverting all migrations drops all the data. rerun with --yes to confirm")
+			}
+
+			return withMigrations(getConfig(), func(db *sql.DB, path string) error {
+				if err := postgres.Migrate(db, path, "down"); err != nil {
+					return err
+				}
+				return printVersion(cmd.OutOrStdout(), db, path)
+			})
+		},
+	}
+	downCmd.Flags().BoolVar(&confirmed, "yes", false, "confirm that all the data can be dropped")
+
+	gotoCmd := &cobra.Command{
+		Use:   "goto <version>",
+		Short: "Apply or revert the migrations needed to reach a version",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.ParseUint(args[0], 10, 32)
+			if err != nil || version == 0 {
+				return fmt.Errorf("invalid version %q. expect a positive number", args[0])
+			}
+
+			return withMigrations(getConfig(), func(db *sql.DB, path string) error {
+				if err := postgres.MigrateTo(db, path, uint(version)); err != nil {
+					return err
+				}
+				return printVersion(cmd.OutOrStdout(), db, path)
+			})
+		},
+	}
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version of the schema",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withMigrations(getConfig(), func(db *sql.DB, path string) error {
+				return printVersion(cmd.OutOrStdout(), db, path)
+			})
+		},
+	}
+
+	forceCmd := &cobra.Command{
+		Use:   "force <version>",
+		Short: "Set the version of the schema without migrating and clear the dirty flag",
+		Long: "Set the version of the schema without running any migration and clear the dirty flag. " +
+			"Use it to recover from a failed migration once the schema was fixed by hand. -1 means no version.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			version, err := strconv.Atoi(args[0])
+			if err != nil || version < -1 {
+				return fmt.Errorf("invalid version %q. expect -1 or a positive number", args[0])
+			}
+
+			return withMigrations(getConfig(), func(db *sql.DB, path string) error {
+				if err := postgres.ForceMigrationVersion(db, path, version); err != nil {
+					return err
+				}
+				return printVersion(cmd.OutOrStdout(), db, path)
+			})
+		},
+	}
+
+	migrateCmd.AddCommand(upCmd, downCmd, gotoCmd, versionCmd, forceCmd)
+	return migrateCmd
+}
+
+// withMigrations connects to the configured postgres database for the duration of fn.
+func withMigrations(configData *config.Config, fn func(db *sql.DB, path string) error) error {
+	if configData.Database != "postgres" {
+		return fmt.Errorf("migrations only apply to the postgres database. the configured database is %s", configData.Database)
+	}
+
+	pgConfig := postgres.Config{
+		Host:     configData.DBPostgresConfig.Host,
+		Port:     configData.DBPostgresConfig.Port,
+		User:     configData.DBPostgresConfig.User,
+		Password: configData.DBPostgresConfig.Password,
+		Database: configData.DBPostgresConfig.DatabaseName,
+	}
+	pool, db, err := postgres.Connect(&pgConfig)
+	if err != nil {
+		return fmt.Errorf("could not connect to db: %w", err)
+	}
+	defer pool.Close()
+	defer db.Close()
+
+	return fn(db, configData.DBPostgresConfig.MigrationsPath)
+}
+
+func printVersion(w io.Writer, db *sql.DB, path string) error {
+	version, dirty, err := postgres.MigrationVersion(db, path)
+	if errors.Is(err, postgres.ErrNilVersion) {
+		fmt.Fprintln(w, "no migration applied.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		fmt.Fprintf(w, "schema at version %d (dirty). fix the schema then force the version.\n", version)
+		return nil
+	}
+	fmt.Fprintf(w, "schema at version %d.\n", version)
+	return nil
+}
@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,48 +13,80 @@ import (
 	"github.com/gin-contrib/cors"
 	ginzap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
-	health "github.com/hellofresh/health-go/v4"
 	"github.com/jeamon/backend-api/pkg/application"
-	"github.com/jeamon/backend-api/pkg/domain"
 	"github.com/jeamon/backend-api/pkg/infrastructure/config"
-	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
-	"github.com/jeamon/backend-api/pkg/infrastructure/mongo"
-	"github.com/jeamon/backend-api/pkg/infrastructure/postgres"
+	"github.com/jeamon/backend-api/pkg/infrastructure/health"
+	"github.com/jeamon/backend-api/pkg/infrastructure/jwks"
+	"github.com/jeamon/backend-api/pkg/infrastructure/metrics"
+	"github.com/jeamon/backend-api/pkg/infrastructure/tracing"
 	apisweb "github.com/jeamon/backend-api/pkg/interfaces/public"
-	"github.com/jeamon/backend-api/pkg/interfaces/repository"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
-	"golang.org/x/sync/errgroup"
 )
 
-// Execute runs the main service command.
+// Execute runs the command line of the service. It exits with a non-zero code
+// when the command fails.
 func Execute(gitCommit, gitTag string) {
 	var configData *config.Config
+	getConfig := func() *config.Config { return configData }
 	rootCmd := &cobra.Command{
-		Use:   "rest-api service",
-		Short: "Demo RestFul API Backend",
-		Run: func(cmd *cobra.Command, args []string) {
-			configData.GitCommit = gitCommit
-			configData.GitTag = gitTag
-			err := run(configData, gitCommit, gitTag)
-			if err != nil {
-				log.Fatalf("Error occurred: %v", err)
-			}
-
-			fmt.Println("Stopped.")
-		},
+		Use:          "demo-rest-api-server",
+		Short:        "Demo RestFul API Backend",
+		SilenceUsage: true,
 	}
 
 	var cfgFile string
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./server.config.yaml)")
 
-	cobra.OnInitialize(func() {
-		configData = config.InitConfig(cfgFile)
-	})
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		var err error
+		configData, err = config.InitConfig(cfgFile)
+		return err
+	}
 
+	rootCmd.AddCommand(
+		newServeCommand(getConfig, gitCommit, gitTag),
+		newMigrateCommand(getConfig),
+		newConfigCommand(getConfig),
+		newHealthcheckCommand(getConfig),
+		newExportCommand(getConfig),
+		newImportCommand(getConfig),
+		newAPIKeyCommand(getConfig),
+	)
+
+	// cobra already printed the error.
 	if err := rootCmd.Execute(); err != nil {
-		panic("unable to execute " + err.Error())
+		os.Exit(1)
+	}
+}
+
+// newServeCommand builds the command running the https server until a stop signal.
+func newServeCommand(getConfig func() *config.Config, gitCommit, gitTag string) *cobra.Command {
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Start the https server",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configData := getConfig()
+			if err := configData.Validate(); err != nil {
+				return fmt.Errorf("%s: %w", viper.ConfigFileUsed(), err)
+			}
+
+			configData.GitCommit = gitCommit
+			configData.GitTag = gitTag
+			if err := run(configData, gitCommit, gitTag); err != nil {
+				return err
+			}
+
+			fmt.Println("Stopped.")
+			return nil
+		},
 	}
+
+	serveCmd.Flags().Bool("migrate", false, "apply the pending postgres migrations before serving (overrides db_postgres.auto_migrate)")
+	_ = viper.BindPFlag("db_postgres.auto_migrate", serveCmd.Flags().Lookup("migrate"))
+	return serveCmd
 }
 
 // nolint
@@ -83,10 +115,46 @@ func run(configData *config.Config, gitCommit, gitTag string) error {
 	)
 
 	logger.Info("starting demo-rest-api service...", zap.String("version", gitTag))
+	for _, warning := range configData.Warnings() {
+		logger.Warn(warning)
+	}
 
 	undo := zap.RedirectStdLog(logger)
 	defer undo()
 
+	shutdownTracing, err := tracing.Setup(logger, tracing.Config{
+		Exporter:    configData.Tracing.Exporter,
+		Endpoint:    configData.Tracing.Endpoint,
+		Insecure:    configData.Tracing.Insecure,
+		File:        configData.Tracing.File,
+		SampleRatio: configData.Tracing.SampleRatio,
+		ServiceName: configData.Tracing.ServiceName,
+		Version:     gitTag,
+	})
+	if err != nil {
+		return fmt.Errorf("setting up tracing failed: %w", err)
+	}
+
+	// the components are stopped in the reverse order so the spans recorded
+	// during the shutdown are still flushed.
+	lc := newLifecycle(logger)
+	lc.add(component{name: "tracing", stop: shutdownTracing, timeout: configData.Shutdown.TracingTimeout})
+	// configData is the snapshot the server starts with. The components
+	// following the reloads read settings instead.
+	settings := config.NewLive(configData)
+	lc.onReload("config", func() error { return config.Reload(settings) })
+	config.Watch(settings, func(err error) {
+		logger.Error("config file change not applied. keeping the settings in use", zap.Error(err))
+	})
+
+	// stops what was started when the setup fails.
+	started := false
+	defer func() {
+		if !started {
+			_ = lc.stop()
+		}
+	}()
+
 	if !configData.GinDisableReleaseMode {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -97,93 +165,69 @@ func run(configData *config.Config, gitCommit, gitTag string) error {
 		AllowWildcard:    true,
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
-		AllowHeaders:     []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Accept", "content-type", "User-Agent", "Accept-Language", "Referer", "DNT", "Connection", "Pragma", "Cache-Control", "TE", "Access-Control-Allow-Origin", "Access-Control-Allow-Headers"},
+		AllowHeaders:     []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Accept", "content-type", "User-Agent", "Accept-Language", "Referer", "DNT", "Connection", "Pragma", "Cache-Control", "TE", "Access-Control-Allow-Origin", "Access-Control-Allow-Headers", "Authorization", "X-API-Key", "If-Match", "Idempotency-Key", "X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	var h *health.Health
-	var dbHandler config.DBHandler
-	var scanInfosRepo domain.ScanInfosRepository
-	switch configData.Database {
-	case "postgres":
-		postgresDB := postgres.Config{
-			Host:          configData.DBPostgresConfig.Host,
-			Port:          configData.DBPostgresConfig.Port,
-			User:          configData.DBPostgresConfig.User,
-			Password:      configData.DBPostgresConfig.Password,
-			Database:      configData.DBPostgresConfig.DatabaseName,
-			MigrationPath: "pkg/infrastructure/postgres/migrations",
-		}
-		pgHandler, err := postgresDB.ConnectAndMigrate(logger)
-		if err != nil {
-			return err
-		}
-		dbHandler = pgHandler
-		scanInfosRepo = repository.NewPostgresScanInfosRepository(logger, pgHandler)
-
-		h, err = health.New(health.WithChecks(
-			health.Config{
-				Name:    "postgres",
-				Timeout: time.Second * 5,
-				Check:   postgresDB.Check(),
-			},
-		))
-		if err != nil {
-			return fmt.Errorf("unable to initialize postgres database health checks: %v", err)
-		}
+	store, err := openStorage(logger, configData)
+	if err != nil {
+		return err
+	}
+	lc.add(component{name: "database", stop: store.handler.Shutdown, timeout: configData.Shutdown.DatabaseTimeout})
 
-	case "mongo":
-		mongoDB := mongo.Config{
-			Host:          configData.DBMongoConfig.Host,
-			Port:          configData.DBMongoConfig.Port,
-			User:          configData.DBMongoConfig.User,
-			Password:      configData.DBMongoConfig.Password,
-			Database:      configData.DBMongoConfig.DatabaseName,
-			MigrationPath: "pkg/infrastructure/mongo/migrations",
-		}
-		mgoHandler, err := mongoDB.ConnectAndMigrate(logger)
-		if err != nil {
+	// each dependency registers its checks for the readiness probe.
+	healthRegistry := health.NewRegistry()
+	for _, check := range store.checks {
+		if err := healthRegistry.Register(check); err != nil {
 			return err
 		}
-		dbHandler = mgoHandler
-		scanInfosRepo = repository.NewMongoScanInfosRepository(logger, mgoHandler, mongoDB.Database)
-		h, err = health.New(health.WithChecks(
-			health.Config{
-				Name:    "mongo",
-				Timeout: time.Second * 5,
-				Check:   mongoDB.Check(),
-			},
-		))
+	}
+
+	appMetrics := metrics.New()
+	if err := store.instrument(logger, appMetrics); err != nil {
+		return err
+	}
+
+	quotaUc := application.NewQuotaUsecase(logger, settings, store.quotas)
+	scanInfosUc := application.NewScanInfosUsecase(logger, settings, store.scanInfos, quotaUc)
+	idempotencyUc := application.NewIdempotencyUsecase(logger, settings, store.idempotency)
+	var jwtKeys application.JWTKeyProvider
+	if jwtCfg := configData.Auth.JWT; jwtCfg.JWKSFile != "" || len(jwtCfg.PEMFiles) > 0 {
+		keySet, err := jwks.Load(logger, jwks.Config{JWKSFile: jwtCfg.JWKSFile, PEMFiles: jwtCfg.PEMFiles})
 		if err != nil {
-			return fmt.Errorf("unable to initialize mongo database health checks: %v", err)
+			return fmt.Errorf("loading jwt verification keys failed: %w", err)
 		}
 
-	case "mockdb": // set <database> field into the configuration to this for quick end-to-end test.
-		mockDB := mockdb.Config{}
-		mockdbHandler, err := mockDB.ConnectAndMigrate(zap.L())
-		if err != nil {
-			return err
+		if err := keySet.Watch(); err != nil {
+			return fmt.Errorf("watching jwt verification keys failed: %w", err)
 		}
-		dbHandler = mockdbHandler
-		scanInfosRepo = repository.NewMockDBScanInfosRepository(zap.L(), mockdbHandler, "mockdb")
+		lc.add(component{name: "jwks watcher", stop: func(context.Context) error { return keySet.Close() }, timeout: configData.Shutdown.WorkersTimeout})
+		lc.onReload("jwks", keySet.Reload)
+		jwtKeys = keySet
+	}
+	authUc := application.NewAuthUsecase(logger, settings, store.apiKeys, jwtKeys)
+	if configData.Auth.Disabled {
+		logger.Warn("authentication is disabled. all callers can access the scans of all companies")
 	}
-
-	scanInfosUc := application.NewScanInfosUsecase(logger, configData, scanInfosRepo)
 
 	// create the web service and setup the api endpoints.
-	apisweb.New(logger, scanInfosUc).Router(router)
+	apisweb.New(logger, scanInfosUc, idempotencyUc, authUc, quotaUc, appMetrics).Router(router)
 
 	// Useful routes to quickly check platform state.
 	router.GET("/ping", func(c *gin.Context) {
 		c.String(http.StatusOK, "pong")
 	})
 
+	// Probes for the orchestrator and the load balancers.
+	router.GET("/livez", gin.WrapH(health.LivenessHandler()))
+	router.GET("/readyz", gin.WrapH(health.ReadinessHandler(healthRegistry)))
+
 	router.GET("/status", func(c *gin.Context) {
-		healthCheck := h.Measure(c)
+		healthCheck := healthRegistry.Measure(c.Request.Context())
 		status := http.StatusOK
-		if healthCheck.Status == health.StatusOK {
-			status = http.StatusInternalServerError
+		if healthCheck.Status == health.StatusFailing {
+			status = http.StatusServiceUnavailable
 		}
 
 		c.JSON(status, gin.H{
@@ -195,37 +239,60 @@ func run(configData *config.Config, gitCommit, gitTag string) error {
 		})
 	})
 
+	tlsConfig, certReloader, err := newTLSConfig(logger, configData)
+	if err != nil {
+		return err
+	}
+	if certReloader != nil {
+		lc.add(component{name: "certificate watcher", stop: func(context.Context) error { return certReloader.Close() }, timeout: configData.Shutdown.WorkersTimeout})
+		lc.onReload("certificate", certReloader.Reload)
+	}
+
+	if configData.Server.MTLS.Enabled {
+		logger.Info("mutual tls enabled. client certificates are required on /api/v1", zap.String("identity", configData.Server.MTLS.Identity))
+	}
+
 	srv := &http.Server{
-		Addr:    fmt.Sprintf("%s:%s", configData.Server.Host, configData.Server.Port),
-		Handler: router,
+		Addr:      fmt.Sprintf("%s:%s", configData.Server.Host, configData.Server.Port),
+		Handler:   router,
+		TLSConfig: tlsConfig,
 	}
 
-	var g errgroup.Group
-	g.Go(func() error {
-		logger.Info("https server infos", zap.String("host", configData.Server.Host), zap.String("port", configData.Server.Port))
-		return srv.ListenAndServeTLS(configData.Server.CertsFile, configData.Server.KeyFile)
+	lc.add(component{
+		name: "https server",
+		run: func() error {
+			logger.Info("https server infos", zap.String("host", configData.Server.Host), zap.String("port", configData.Server.Port))
+			// the certificate is provided by the tls config.
+			if err := srv.ListenAndServeTLS("", ""); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		// waits for the in-flight requests to complete.
+		stop:    srv.Shutdown,
+		timeout: configData.Shutdown.HTTPTimeout,
 	})
-	go shutdownServer(srv, logger, dbHandler)
-
-	logger.Info("starting HTTPS server")
-	err = g.Wait()
-	return fmt.Errorf("serving server failed: %w", err)
-}
 
-// shutdownServer handles a stop signal and teardown the connection to in-use database.
-func shutdownServer(srv *http.Server, logger *zap.Logger, dbHandler config.DBHandler) {
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-	logger.Info("shutting down all services...")
+	// stopped first so that the load balancers stop sending traffic while
+	// the server still accepts requests.
+	lc.add(component{
+		name: "readiness",
+		stop: func(ctx context.Context) error {
+			healthRegistry.Shutdown()
+			select {
+			case <-time.After(configData.Shutdown.DrainDelay):
+			case <-ctx.Done():
+			}
+			return nil
+		},
+		timeout: configData.Shutdown.DrainDelay + time.Second,
+	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		logger.Error("platform shutdown: error shutting down server", zap.Error(err))
-	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
 
-	if err := dbHandler.Shutdown(ctx); err != nil {
-		logger.Error("platform shutdown: error disconnecting from database", zap.Error(err))
-	}
+	logger.Info("starting HTTPS server")
+	started = true
+	return lc.run(signals)
 }
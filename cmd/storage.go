@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/jeamon/backend-api/pkg/infrastructure/health"
+	"github.com/jeamon/backend-api/pkg/infrastructure/metrics"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mongo"
+	"github.com/jeamon/backend-api/pkg/infrastructure/postgres"
+	"github.com/jeamon/backend-api/pkg/interfaces/repository"
+	"go.uber.org/zap"
+)
+
+// storage gathers the connection to the configured database and the
+// repositories built on top of it.
+type storage struct {
+	backend string
+	handler config.DBHandler
+	// checks probe the database. They are critical since no request can
+	// be served without it.
+	checks      []health.Check
+	scanInfos   domain.ScanInfosRepository
+	idempotency domain.IdempotencyRepository
+	apiKeys     domain.APIKeyRepository
+	quotas      domain.QuotaRepository
+}
+
+// openStorage connects to the configured database, applies its migrations when
+// auto migration is enabled and builds the repositories.
+func openStorage(logger *zap.Logger, configData *config.Config) (*storage, error) {
+	s := &storage{backend: configData.Database}
+	switch configData.Database {
+	case "postgres":
+		postgresDB := postgres.Config{
+			Host:          configData.DBPostgresConfig.Host,
+			Port:          configData.DBPostgresConfig.Port,
+			User:          configData.DBPostgresConfig.User,
+			Password:      configData.DBPostgresConfig.Password,
+			Database:      configData.DBPostgresConfig.DatabaseName,
+			MigrationPath: configData.DBPostgresConfig.MigrationsPath,
+		}
+		open := postgresDB.Open
+		if configData.DBPostgresConfig.AutoMigrate {
+			open = postgresDB.ConnectAndMigrate
+		}
+		pgHandler, err := open(logger)
+		if err != nil {
+			return nil, err
+		}
+		s.handler = pgHandler
+		s.scanInfos = repository.NewPostgresScanInfosRepository(logger, pgHandler)
+		s.idempotency = repository.NewPostgresIdempotencyRepository(logger, pgHandler)
+		s.apiKeys = repository.NewPostgresAPIKeyRepository(logger, pgHandler)
+		s.quotas = repository.NewPostgresQuotaRepository(logger, pgHandler)
+
+		s.checks = []health.Check{{Name: "postgres", Critical: true, Timeout: 5 * time.Second, Probe: postgresDB.Check()}}
+
+	case "mongo":
+		mongoDB := mongo.Config{
+			Host:          configData.DBMongoConfig.Host,
+			Port:          configData.DBMongoConfig.Port,
+			User:          configData.DBMongoConfig.User,
+			Password:      configData.DBMongoConfig.Password,
+			Database:      configData.DBMongoConfig.DatabaseName,
+			MigrationPath: "pkg/infrastructure/mongo/migrations",
+		}
+		mgoHandler, err := mongoDB.ConnectAndMigrate(logger)
+		if err != nil {
+			return nil, err
+		}
+		s.handler = mgoHandler
+		s.scanInfos = repository.NewMongoScanInfosRepository(logger, mgoHandler, mongoDB.Database)
+		s.idempotency = repository.NewMongoIdempotencyRepository(logger, mgoHandler, mongoDB.Database)
+		s.apiKeys = repository.NewMongoAPIKeyRepository(logger, mgoHandler, mongoDB.Database)
+		s.quotas = repository.NewMongoQuotaRepository(logger, mgoHandler, mongoDB.Database)
+
+		s.checks = []health.Check{{Name: "mongo", Critical: true, Timeout: 5 * time.Second, Probe: mongoDB.Check()}}
+
+	case "mockdb", "memory": // set <database> field into the configuration to this for local development or demos.
+		mockDB := mockdb.Config{}
+		mockdbHandler, err := mockDB.ConnectAndMigrate(logger)
+		if err != nil {
+			return nil, err
+		}
+		s.backend = "memory"
+		s.handler = mockdbHandler
+		s.scanInfos = repository.NewInMemoryScanInfosRepository(logger, mockdbHandler)
+		s.idempotency = repository.NewInMemoryIdempotencyRepository(logger, mockdbHandler)
+		s.apiKeys = repository.NewInMemoryAPIKeyRepository(logger, mockdbHandler)
+		s.quotas = repository.NewInMemoryQuotaRepository(logger, mockdbHandler)
+		s.checks = []health.Check{{Name: "memory", Critical: true, Probe: mockdbHandler.Check}}
+
+	default:
+		return nil, fmt.Errorf("unsupported database %q. expect postgres, mongo, mockdb or memory", configData.Database)
+	}
+
+	return s, nil
+}
+
+// instrument records the calls to the scan infos repository and the stats of
+// the postgres connection pool into m.
+func (s *storage) instrument(logger *zap.Logger, m *metrics.Metrics) error {
+	s.scanInfos = repository.NewInstrumentedScanInfosRepository(logger, s.scanInfos, s.backend, m)
+	if pgHandler, ok := s.handler.(*postgres.Handler); ok {
+		if err := m.Register(metrics.NewPoolCollector(pgHandler.PGx)); err != nil {
+			return fmt.Errorf("unable to register postgres pool metrics: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jeamon/backend-api/pkg/infrastructure/certs"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// newTLSConfig builds the TLS settings of the server. The returned reloader
+// watches the certificate files until closed. It is nil when a self-signed
+// certificate is served instead.
+func newTLSConfig(logger *zap.Logger, configData *config.Config) (*tls.Config, *certs.Reloader, error) {
+	minVersion, err := certs.ParseVersion(configData.Server.TLS.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cipherSuites, err := certs.ParseCipherSuites(configData.Server.TLS.CipherSuites)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: minVersion, CipherSuites: cipherSuites}
+	reloader, err := serverCertificate(logger, configData, tlsConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mtls := configData.Server.MTLS
+	if !mtls.Enabled {
+		return tlsConfig, reloader, nil
+	}
+
+	if err := clientCertificates(configData, tlsConfig); err != nil {
+		if reloader != nil {
+			reloader.Close()
+		}
+		return nil, nil, err
+	}
+	return tlsConfig, reloader, nil
+}
+
+// serverCertificate sets the certificate served by tlsConfig. It falls back to
+// a self-signed certificate when enabled and the certificate files are missing.
+func serverCertificate(logger *zap.Logger, configData *config.Config, tlsConfig *tls.Config) (*certs.Reloader, error) {
+	certFile, keyFile := configData.Server.CertsFile, configData.Server.KeyFile
+	if configData.Server.TLS.SelfSigned && (!fileExists(certFile) || !fileExists(keyFile)) {
+		cert, err := certs.SelfSigned(configData.Server.Host)
+		if err != nil {
+			return nil, err
+		}
+
+		logger.Warn("serving an in-memory self-signed certificate. not suitable for production", zap.String("certs_file", certFile), zap.String("key_file", keyFile))
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		return nil, nil
+	}
+
+	reloader, err := certs.NewReloader(logger, certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w. set server.tls.self_signed to generate one for development", err)
+	}
+
+	if err := reloader.Watch(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig.GetCertificate = reloader.GetCertificate
+	return reloader, nil
+}
+
+// clientCertificates makes tlsConfig verify the client certificates against
+// the client CA bundle. They stay optional during the handshake so that
+// browsers can reach the routes which do not require them.
+func clientCertificates(configData *config.Config, tlsConfig *tls.Config) error {
+	mtls := configData.Server.MTLS
+	switch mtls.Identity {
+	case "common_name", "dns_san", "uri_san", "email_san":
+	default:
+		return fmt.Errorf("invalid mtls identity %q. expect common_name, dns_san, uri_san or email_san", mtls.Identity)
+	}
+
+	data, err := ioutil.ReadFile(mtls.ClientCAFile)
+	if err != nil {
+		return fmt.Errorf("cannot read client ca file: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no certificate found into client ca file %s", mtls.ClientCAFile)
+	}
+
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
@@ -0,0 +1,480 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// scanInfosRecord is a line of an export file. Unlike the api representation
+// of a scan infos, it carries its timestamps.
+type scanInfosRecord struct {
+	domain.ScanInfos
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r scanInfosRecord) toScanInfos() domain.ScanInfos {
+	s := r.ScanInfos
+	s.CreatedAt, s.UpdatedAt = r.CreatedAt, r.UpdatedAt
+	return s
+}
+
+// exportOptions describes an export. Output is a file path or - for the
+// standard output.
+type exportOptions struct {
+	filter     domain.ScanInfosFilter
+	output     string
+	gzip       bool
+	checkpoint string
+	pageSize   int
+}
+
+// exportCheckpoint is the progress of an export saved after each page so that
+// an interrupted export resumes after the last written page.
+type exportCheckpoint struct {
+	Output string                 `json:"output"`
+	Gzip   bool                   `json:"gzip"`
+	Filter domain.ScanInfosFilter `json:"filter"`
+	Cursor string                 `json:"cursor"`
+	// Offset is the size of the output once the last page was written.
+	// Anything written after it is dropped on resume.
+	Offset  int64 `json:"offset"`
+	Records int64 `json:"records"`
+}
+
+// importOptions describes an import. Input is a file path or - for the
+// standard input.
+type importOptions struct {
+	input      string
+	checkpoint string
+	batchSize  int
+}
+
+// importCheckpoint is the progress of an import saved after each batch so that
+// an interrupted import resumes after the last stored batch.
+type importCheckpoint struct {
+	Input   string `json:"input"`
+	Records int64  `json:"records"`
+}
+
+// newExportCommand builds the command writing the scan infos of the configured
+// database as NDJSON. getConfig provides the loaded configuration.
+func newExportCommand(getConfig func() *config.Config) *cobra.Command {
+	var opts exportOptions
+	var from, to string
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the scan infos as NDJSON, optionally gzipped",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if opts.filter.StartedFrom, err = parseTimeBound(from, false); err != nil {
+				return err
+			}
+			if opts.filter.StartedTo, err = parseTimeBound(to, true); err != nil {
+				return err
+			}
+			opts.gzip = opts.gzip || strings.HasSuffix(opts.output, ".gz")
+
+			return withScanInfosRepository(getConfig(), func(ctx context.Context, repo domain.ScanInfosRepository) error {
+				n, err := exportScanInfos(ctx, repo, opts)
+				if err != nil {
+					return fmt.Errorf("export interrupted after %d scan infos: %w", n, err)
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "%d scan infos exported.\n", n)
+				return nil
+			})
+		},
+	}
+
+	exportCmd.Flags().StringVarP(&opts.output, "output", "o", "-", "file to write or - for the standard output")
+	exportCmd.Flags().BoolVar(&opts.gzip, "gzip", false, "compress the output (default when the output ends with .gz)")
+	exportCmd.Flags().StringVar(&opts.filter.CompanyID, "company", "", "only export the scans of this company")
+	exportCmd.Flags().StringVar(&from, "from", "", "only export the scans started from this date (YYYY-MM-DD or RFC3339)")
+	exportCmd.Flags().StringVar(&to, "to", "", "only export the scans started until this date included (YYYY-MM-DD or RFC3339)")
+	exportCmd.Flags().StringVar(&opts.checkpoint, "checkpoint", "", "file recording the progress to resume an interrupted export")
+	exportCmd.Flags().IntVar(&opts.pageSize, "page-size", domain.MaxListLimit, "number of scan infos read at once")
+	return exportCmd
+}
+
+// newImportCommand builds the command storing the scan infos of an export
+// into the configured database. getConfig provides the loaded configuration.
+func newImportCommand(getConfig func() *config.Config) *cobra.Command {
+	var opts importOptions
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import the scan infos of an NDJSON export, gzipped or not",
+		Long: "Import the scan infos of an NDJSON export, gzipped or not. The IDs, versions and timestamps are " +
+			"kept and the scan infos whose ID is already stored are skipped.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withScanInfosRepository(getConfig(), func(ctx context.Context, repo domain.ScanInfosRepository) error {
+				read, imported, err := importScanInfos(ctx, repo, opts)
+				if err != nil {
+					return fmt.Errorf("import interrupted after %d scan infos: %w", imported, err)
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "%d scan infos read. %d imported, %d already stored.\n", read, imported, read-imported)
+				return nil
+			})
+		},
+	}
+
+	importCmd.Flags().StringVarP(&opts.input, "input", "i", "-", "file to read or - for the standard input")
+	importCmd.Flags().StringVar(&opts.checkpoint, "checkpoint", "", "file recording the progress to resume an interrupted import")
+	importCmd.Flags().IntVar(&opts.batchSize, "batch-size", 500, "number of scan infos stored at once")
+	return importCmd
+}
+
+// withScanInfosRepository connects to the configured database for the duration
+// of fn. The context of fn is cancelled on SIGINT or SIGTERM so that the
+// progress recorded into the checkpoint is kept.
+func withScanInfosRepository(configData *config.Config, fn func(context.Context, domain.ScanInfosRepository) error) error {
+	switch configData.Database {
+	case "mockdb", "memory":
+		return fmt.Errorf("scan infos of the %s database only live into the server process. use postgres or mongo", configData.Database)
+	}
+
+	store, err := openStorage(zap.NewNop(), configData)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), configData.Shutdown.DatabaseTimeout)
+		defer cancel()
+		if err := store.handler.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to disconnect from database: %v\n", err)
+		}
+	}()
+
+	return fn(ctx, store.scanInfos)
+}
+
+// exportScanInfos writes the scan infos matching the filter by creation order,
+// one json record per line. It returns the number of exported scan infos,
+// including the ones exported before resuming. Each page is written as its own
+// gzip member so that a resumed export appends to a valid file.
+func exportScanInfos(ctx context.Context, repo domain.ScanInfosRepository, opts exportOptions) (int64, error) {
+	cp := exportCheckpoint{Output: opts.output, Gzip: opts.gzip, Filter: opts.filter}
+	resumed := false
+	if opts.checkpoint != "" {
+		if opts.output == "-" {
+			return 0, errors.New("a checkpoint requires an output file")
+		}
+
+		var saved exportCheckpoint
+		found, err := loadCheckpoint(opts.checkpoint, &saved)
+		if err != nil {
+			return 0, err
+		}
+
+		if found {
+			if saved.Output != cp.Output || saved.Gzip != cp.Gzip || saved.Filter != cp.Filter {
+				return 0, fmt.Errorf("checkpoint %s belongs to another export. remove it to start over", opts.checkpoint)
+			}
+
+			// the export completed but the checkpoint was not removed.
+			if saved.Cursor == "" && saved.Records > 0 {
+				return saved.Records, removeCheckpoint(opts.checkpoint)
+			}
+			cp, resumed = saved, true
+		}
+	}
+
+	out := os.Stdout
+	if opts.output != "-" {
+		f, err := openExportFile(opts.output, cp.Offset, resumed)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	q := domain.ScanInfosQuery{Filter: opts.filter, SortBy: domain.SortByCreatedAt, Limit: opts.pageSize, Cursor: cp.Cursor, SkipTotal: true}
+	for {
+		page, err := repo.List(ctx, q)
+		if err != nil {
+			return cp.Records, err
+		}
+
+		if err := writeRecords(out, page.Infos, opts.gzip); err != nil {
+			return cp.Records, fmt.Errorf("cannot write export: %w", err)
+		}
+		cp.Records += int64(len(page.Infos))
+		cp.Cursor = page.NextCursor
+
+		if opts.checkpoint != "" {
+			if cp.Offset, err = syncedSize(out); err != nil {
+				return cp.Records, fmt.Errorf("cannot write export: %w", err)
+			}
+			if err := saveCheckpoint(opts.checkpoint, cp); err != nil {
+				return cp.Records, err
+			}
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+
+	return cp.Records, removeCheckpoint(opts.checkpoint)
+}
+
+// openExportFile opens the output of an export. A resumed export drops what
+// was written after the checkpoint offset.
+func openExportFile(path string, offset int64, resumed bool) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open export file: %w", err)
+	}
+
+	if !resumed {
+		offset = 0
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot truncate export file: %w", err)
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot seek export file: %w", err)
+	}
+	return f, nil
+}
+
+// writeRecords writes a page of scan infos, gzipped as a whole when enabled.
+func writeRecords(out io.Writer, infos []domain.ScanInfos, compress bool) error {
+	bw := bufio.NewWriter(out)
+	w := io.Writer(bw)
+	var gz *gzip.Writer
+	if compress {
+		gz = gzip.NewWriter(bw)
+		w = gz
+	}
+
+	enc := json.NewEncoder(w)
+	for _, s := range infos {
+		if err := enc.Encode(scanInfosRecord{ScanInfos: s, CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt}); err != nil {
+			return err
+		}
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// syncedSize flushes the written data to the disk and returns the file size.
+func syncedSize(out *os.File) (int64, error) {
+	if err := out.Sync(); err != nil {
+		return 0, err
+	}
+	return out.Seek(0, io.SeekCurrent)
+}
+
+// importScanInfos stores the scan infos read from the input by batches. It
+// returns the number of records read and of stored scan infos by this run. A
+// resumed import skips the records already imported. Replaying a batch
+// interrupted before its checkpoint is harmless since the stored IDs are
+// skipped.
+func importScanInfos(ctx context.Context, repo domain.ScanInfosRepository, opts importOptions) (int64, int64, error) {
+	if opts.batchSize <= 0 {
+		return 0, 0, fmt.Errorf("invalid batch size %d. expect a positive number", opts.batchSize)
+	}
+
+	cp := importCheckpoint{Input: opts.input}
+	if opts.checkpoint != "" {
+		if opts.input == "-" {
+			return 0, 0, errors.New("a checkpoint requires an input file")
+		}
+
+		var saved importCheckpoint
+		found, err := loadCheckpoint(opts.checkpoint, &saved)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if found {
+			if saved.Input != cp.Input {
+				return 0, 0, fmt.Errorf("checkpoint %s belongs to another import. remove it to start over", opts.checkpoint)
+			}
+			cp = saved
+		}
+	}
+
+	in := os.Stdin
+	if opts.input != "-" {
+		f, err := os.Open(opts.input)
+		if err != nil {
+			return 0, 0, fmt.Errorf("cannot open import file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	r, err := decompressed(in)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot read import file: %w", err)
+	}
+
+	// line counts the records of the input, read only the ones of this run.
+	var line, read, imported int64
+	dec := json.NewDecoder(r)
+	batch := make([]domain.ScanInfos, 0, opts.batchSize)
+	flush := func() error {
+		n, err := repo.Import(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("cannot import records %d to %d: %w", line-int64(len(batch))+1, line, err)
+		}
+
+		imported += int64(n)
+		cp.Records = line
+		batch = batch[:0]
+		if opts.checkpoint != "" {
+			return saveCheckpoint(opts.checkpoint, cp)
+		}
+		return nil
+	}
+
+	for {
+		var rec scanInfosRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return read, imported, fmt.Errorf("invalid record %d: %w", line+1, err)
+		}
+
+		line++
+		if line <= cp.Records {
+			continue
+		}
+		read++
+
+		batch = append(batch, rec.toScanInfos())
+		if len(batch) == opts.batchSize {
+			if err := flush(); err != nil {
+				return read, imported, err
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return read, imported, err
+		}
+	}
+
+	return read, imported, removeCheckpoint(opts.checkpoint)
+}
+
+// decompressed returns a reader of r which decompresses it when gzipped.
+func decompressed(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return gzip.NewReader(br)
+	}
+	return br, nil
+}
+
+// parseTimeBound converts a date or a time into unix epoch seconds. A date
+// ending a range includes the whole day. An empty value means no bound.
+func parseTimeBound(value string, end bool) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q. expect YYYY-MM-DD or RFC3339", value)
+	}
+
+	if end {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t.Unix(), nil
+}
+
+// loadCheckpoint reads the checkpoint saved at path into v. It reports false
+// when there is none.
+func loadCheckpoint(path string, v interface{}) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("cannot read checkpoint: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// saveCheckpoint replaces the checkpoint at path by v at once so that an
+// interruption never leaves a partial checkpoint.
+func saveCheckpoint(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("cannot save checkpoint: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("cannot save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// removeCheckpoint removes the checkpoint of a completed transfer.
+func removeCheckpoint(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("cannot remove checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
+	"github.com/jeamon/backend-api/pkg/interfaces/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// flakyRepository fails the calls of a scan infos repository from the given
+// call on, like an interrupted transfer.
+type flakyRepository struct {
+	domain.ScanInfosRepository
+	calls, failFrom int
+}
+
+func (f *flakyRepository) List(ctx context.Context, q domain.ScanInfosQuery) (domain.ScanInfosPage, error) {
+	if f.calls++; f.calls >= f.failFrom {
+		return domain.ScanInfosPage{}, errors.New("connection reset")
+	}
+	return f.ScanInfosRepository.List(ctx, q)
+}
+
+func (f *flakyRepository) Import(ctx context.Context, infos []domain.ScanInfos) (int, error) {
+	if f.calls++; f.calls >= f.failFrom {
+		return 0, errors.New("connection reset")
+	}
+	return f.ScanInfosRepository.Import(ctx, infos)
+}
+
+func newTransferRepository(t *testing.T, n int) domain.ScanInfosRepository {
+	h, err := (&mockdb.Config{}).ConnectAndMigrate(zap.NewNop())
+	require.NoError(t, err)
+	repo := repository.NewInMemoryScanInfosRepository(zap.NewNop(), h)
+
+	created := time.Date(2022, 6, 22, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		_, err := repo.Save(context.Background(), domain.ScanInfos{
+			CompanyID:     fmt.Sprintf("company-%d", i%2),
+			ClientID:      "v1.0.0",
+			RepositoryURL: "https://github.com/jeamon/backend-api",
+			Results:       []string{"found something"},
+			Findings:      []domain.Finding{{RuleID: "G101", Severity: domain.SeverityHigh, Title: "hardcoded credentials", Fingerprint: "f"}},
+			StartedAt:     created.AddDate(0, 0, i).Unix(),
+			CreatedAt:     created.Add(time.Duration(i) * time.Hour),
+			UpdatedAt:     created.Add(time.Duration(i) * time.Hour),
+			Metadata:      map[string]interface{}{"n": float64(i)},
+		})
+		require.NoError(t, err)
+	}
+	return repo
+}
+
+func listAll(t *testing.T, repo domain.ScanInfosRepository) []domain.ScanInfos {
+	page, err := repo.List(context.Background(), domain.ScanInfosQuery{Limit: domain.MaxListLimit})
+	require.NoError(t, err)
+	sort.Slice(page.Infos, func(i, j int) bool { return page.Infos[i].ID < page.Infos[j].ID })
+	return page.Infos
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should pass: gzipped round trip keeps ids and timestamps", func(t *testing.T) {
+		source := newTransferRepository(t, 7)
+		file := filepath.Join(t.TempDir(), "scans.ndjson.gz")
+		n, err := exportScanInfos(ctx, source, exportOptions{output: file, gzip: true, pageSize: 3})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), n)
+
+		target := newTransferRepository(t, 0)
+		read, imported, err := importScanInfos(ctx, target, importOptions{input: file, batchSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), read)
+		assert.Equal(t, int64(7), imported)
+
+		expected, actual := listAll(t, source), listAll(t, target)
+		require.Len(t, actual, 7)
+		for i := range expected {
+			assert.Equal(t, expected[i].ID, actual[i].ID)
+			assert.Equal(t, expected[i].Version, actual[i].Version)
+			assert.True(t, expected[i].CreatedAt.Equal(actual[i].CreatedAt))
+			assert.True(t, expected[i].UpdatedAt.Equal(actual[i].UpdatedAt))
+			assert.Equal(t, expected[i].Findings, actual[i].Findings)
+			assert.Equal(t, expected[i].Metadata, actual[i].Metadata)
+		}
+
+		read, imported, err = importScanInfos(ctx, target, importOptions{input: file, batchSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), read)
+		assert.Zero(t, imported)
+	})
+
+	t.Run("should pass: export filters by company and start date", func(t *testing.T) {
+		source := newTransferRepository(t, 7)
+		file := filepath.Join(t.TempDir(), "scans.ndjson")
+		from, err := parseTimeBound("2022-06-23", false)
+		require.NoError(t, err)
+		to, err := parseTimeBound("2022-06-26", true)
+		require.NoError(t, err)
+
+		n, err := exportScanInfos(ctx, source, exportOptions{
+			output:   file,
+			filter:   domain.ScanInfosFilter{CompanyID: "company-1", StartedFrom: from, StartedTo: to},
+			pageSize: 10,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+
+		target := newTransferRepository(t, 0)
+		_, _, err = importScanInfos(ctx, target, importOptions{input: file, batchSize: 10})
+		require.NoError(t, err)
+		for _, s := range listAll(t, target) {
+			assert.Equal(t, "company-1", s.CompanyID)
+			assert.Contains(t, []int64{from, from + 2*24*3600}, s.StartedAt)
+		}
+	})
+
+	t.Run("should pass: interrupted export resumes from checkpoint", func(t *testing.T) {
+		source := newTransferRepository(t, 7)
+		dir := t.TempDir()
+		file, checkpoint := filepath.Join(dir, "scans.ndjson.gz"), filepath.Join(dir, "export.checkpoint")
+		opts := exportOptions{output: file, gzip: true, checkpoint: checkpoint, pageSize: 2}
+
+		n, err := exportScanInfos(ctx, &flakyRepository{ScanInfosRepository: source, failFrom: 3}, opts)
+		assert.EqualError(t, err, "connection reset")
+		assert.Equal(t, int64(4), n)
+		assert.FileExists(t, checkpoint)
+
+		n, err = exportScanInfos(ctx, source, opts)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), n)
+		assert.NoFileExists(t, checkpoint)
+
+		target := newTransferRepository(t, 0)
+		read, _, err := importScanInfos(ctx, target, importOptions{input: file, batchSize: 3})
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), read)
+		assert.Len(t, listAll(t, target), 7)
+
+		_, err = exportScanInfos(ctx, source, exportOptions{output: "-", checkpoint: checkpoint, pageSize: 2})
+		assert.EqualError(t, err, "a checkpoint requires an output file")
+	})
+
+	t.Run("should pass: interrupted import resumes from checkpoint", func(t *testing.T) {
+		source := newTransferRepository(t, 7)
+		dir := t.TempDir()
+		file, checkpoint := filepath.Join(dir, "scans.ndjson"), filepath.Join(dir, "import.checkpoint")
+		_, err := exportScanInfos(ctx, source, exportOptions{output: file, pageSize: 10})
+		require.NoError(t, err)
+
+		target := newTransferRepository(t, 0)
+		opts := importOptions{input: file, checkpoint: checkpoint, batchSize: 3}
+		read, imported, err := importScanInfos(ctx, &flakyRepository{ScanInfosRepository: target, failFrom: 2}, opts)
+		assert.EqualError(t, err, "cannot import records 4 to 6: connection reset")
+		assert.Equal(t, int64(6), read)
+		assert.Equal(t, int64(3), imported)
+
+		read, imported, err = importScanInfos(ctx, target, opts)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), read)
+		assert.Equal(t, int64(4), imported)
+		assert.NoFileExists(t, checkpoint)
+		assert.Len(t, listAll(t, target), 7)
+	})
+
+	t.Run("should fail: invalid records and dates", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "scans.ndjson")
+		require.NoError(t, os.WriteFile(file, []byte("{\"id\":\"7aec1a3e-f22d-11ec-a1c2-37e6aab6bd2c\"}\n{oops\n"), 0o644))
+		_, _, err := importScanInfos(ctx, newTransferRepository(t, 0), importOptions{input: file, batchSize: 10})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid record 2")
+
+		_, err = parseTimeBound("22/06/2022", false)
+		assert.EqualError(t, err, `invalid date "22/06/2022". expect YYYY-MM-DD or RFC3339`)
+	})
+}
@@ -2,46 +2,307 @@ package application
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jeamon/backend-api/pkg/domain"
 	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/jeamon/backend-api/pkg/infrastructure/tracing"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
 // ScanInfosUsecase is handling the scan informations business logic.
 type ScanInfosUsecase struct {
 	Logger        *zap.Logger
-	ConfigData    *config.Config
+	Settings      *config.Live
 	scanInfosRepo domain.ScanInfosRepository
+	validator     *ScanInfosValidator
+	quotas        *QuotaUsecase
 }
 
 // NewScanInfosUsecase initialises and returns a new use case for scan infos use case.
-func NewScanInfosUsecase(logger *zap.Logger, configData *config.Config, repo domain.ScanInfosRepository) *ScanInfosUsecase {
+// Unless authentication is disabled, the repository calls are constrained to
+// the company of the caller carried by their context. Submissions are counted
+// against the daily quotas when quotas is not nil.
+func NewScanInfosUsecase(logger *zap.Logger, settings *config.Live, repo domain.ScanInfosRepository, quotas *QuotaUsecase) *ScanInfosUsecase {
+	if !settings.Load().Auth.Disabled {
+		repo = newTenantScanInfosRepository(repo)
+	}
+
 	uc := &ScanInfosUsecase{
 		Logger:        logger,
-		ConfigData:    configData,
+		Settings:      settings,
 		scanInfosRepo: repo,
+		validator:     NewScanInfosValidator(settings),
+		quotas:        quotas,
 	}
 
 	return uc
 }
 
+// Store saves a scan submission once validated.
 func (uc *ScanInfosUsecase) Store(ctx context.Context, req domain.StoreScanInfosRequest) (string, error) {
-	return uc.scanInfosRepo.Save(ctx, req.ToScanInfos())
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.Store")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionCreateScans); err != nil {
+		return "", err
+	}
+
+	s := req.ToScanInfos()
+	if err := checkClientID(ctx, s); err != nil {
+		return "", err
+	}
+
+	if err := uc.validator.Validate(s); err != nil {
+		return "", err
+	}
+	return uc.save(ctx, s)
+}
+
+// save stores a scan submission counted against the quota of its company.
+func (uc *ScanInfosUsecase) save(ctx context.Context, s domain.ScanInfos) (string, error) {
+	days, err := uc.consumeQuotas(ctx, []domain.ScanInfos{s})
+	if err != nil {
+		return "", err
+	}
+
+	id, err := uc.scanInfosRepo.Save(ctx, s)
+	if err != nil {
+		uc.refundQuotas(ctx, days, []domain.ScanInfos{s})
+	}
+	return id, err
+}
+
+// StoreV2 saves a scan submission along with its structured findings once validated.
+func (uc *ScanInfosUsecase) StoreV2(ctx context.Context, req domain.StoreScanInfosV2Request) (string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.StoreV2")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionCreateScans); err != nil {
+		return "", err
+	}
+
+	s := req.ToScanInfos()
+	if err := checkClientID(ctx, s); err != nil {
+		return "", err
+	}
+
+	if err := uc.validator.Validate(s); err != nil {
+		return "", err
+	}
+	return uc.save(ctx, s)
+}
+
+// StoreBatch saves a batch of scan submissions and reports the outcome of each
+// item. In atomic mode nothing is stored when an item is invalid or when the
+// batch cannot be saved. In best effort mode every valid item is stored and
+// each failure is reported on the result of its item. The whole batch is
+// rejected when an item belongs to another client than the certificate one or
+// when the valid items exceed the daily quota of their company.
+func (uc *ScanInfosUsecase) StoreBatch(ctx context.Context, items []domain.BatchItem, mode string) ([]domain.BatchItemResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.StoreBatch")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionCreateScans); err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		if item.Err != nil {
+			continue
+		}
+
+		if err := checkClientID(ctx, item.Request.ToScanInfos()); err != nil {
+			return nil, errors.Wrapf(err, "item %d", i)
+		}
+	}
+
+	results := make([]domain.BatchItemResult, len(items))
+	indexes := make([]int, 0, len(items))
+	infos := make([]domain.ScanInfos, 0, len(items))
+	for i, item := range items {
+		results[i].Index = i
+		if item.Err != nil {
+			results[i].Error = item.Err.Error()
+			continue
+		}
+
+		s := item.Request.ToScanInfos()
+		if err := uc.validator.Validate(s); err != nil {
+			results[i].Error = err.Error()
+			results[i].Errors, _ = err.(domain.ValidationErrors)
+			continue
+		}
+
+		indexes = append(indexes, i)
+		infos = append(infos, s)
+	}
+
+	if invalid := len(items) - len(infos); invalid > 0 && mode == domain.BatchModeAtomic {
+		return results, domain.NewError(domain.ErrValidation, fmt.Errorf("%d out of %d batch items are invalid", invalid, len(items)))
+	}
+
+	if len(infos) == 0 {
+		return results, nil
+	}
+
+	days, err := uc.consumeQuotas(ctx, infos)
+	if err != nil {
+		return results, err
+	}
+
+	ids, err := uc.scanInfosRepo.SaveBatch(ctx, infos)
+	if err == nil {
+		for k, i := range indexes {
+			results[i].ScanInfosID = ids[k]
+		}
+		return results, nil
+	}
+
+	if mode == domain.BatchModeAtomic {
+		uc.refundQuotas(ctx, days, infos)
+		return results, err
+	}
+
+	// the batch failed as a whole so each item is saved on its own to only
+	// report the ones the database rejects.
+	uc.Logger.Warn("failed to save scan infos batch. saving items one by one", zap.String("requestid", domain.RequestIDFromContext(ctx)), zap.Error(err))
+	failed := []domain.ScanInfos{}
+	for k, i := range indexes {
+		id, err := uc.scanInfosRepo.Save(ctx, infos[k])
+		if err != nil {
+			results[i].Error = err.Error()
+			failed = append(failed, infos[k])
+			continue
+		}
+		results[i].ScanInfosID = id
+	}
+	uc.refundQuotas(ctx, days, failed)
+
+	return results, nil
 }
 
 func (uc *ScanInfosUsecase) Get(ctx context.Context, id string) (domain.ScanInfos, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.Get")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionReadScans); err != nil {
+		return domain.ScanInfos{}, err
+	}
+
 	return uc.scanInfosRepo.FindByID(ctx, id)
 }
 
-func (uc *ScanInfosUsecase) GetAll(ctx context.Context) ([]domain.ScanInfos, error) {
-	return uc.scanInfosRepo.FindAll(ctx)
+func (uc *ScanInfosUsecase) List(ctx context.Context, q domain.ScanInfosQuery) (domain.ScanInfosPage, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.List")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionReadScans); err != nil {
+		return domain.ScanInfosPage{Infos: []domain.ScanInfos{}}, err
+	}
+
+	return uc.scanInfosRepo.List(ctx, q)
 }
 
-func (uc *ScanInfosUsecase) Delete(ctx context.Context, id string) error {
-	return uc.scanInfosRepo.DeleteByID(ctx, id)
+// Delete removes a scan infos. A non-zero version makes the deletion apply
+// only when it matches the stored version.
+func (uc *ScanInfosUsecase) Delete(ctx context.Context, id string, version int64) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.Delete")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionDeleteScans); err != nil {
+		return err
+	}
+
+	return uc.scanInfosRepo.DeleteByID(ctx, id, version)
 }
 
+// Update replaces a scan infos once validated. A non-zero infos version makes
+// the update apply only when it matches the stored version.
 func (uc *ScanInfosUsecase) Update(ctx context.Context, infos domain.ScanInfos) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.Update")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionUpdateScans); err != nil {
+		return err
+	}
+
+	if err := checkClientID(ctx, infos); err != nil {
+		return err
+	}
+
+	if err := uc.validator.Validate(infos); err != nil {
+		return err
+	}
 	return uc.scanInfosRepo.UpdateByID(ctx, infos.ID, infos)
 }
+
+// Diff compares the findings of the scan headID against the ones of the scan baseID.
+func (uc *ScanInfosUsecase) Diff(ctx context.Context, headID, baseID string) (domain.ScanInfosDiff, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.Diff")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionReadScans); err != nil {
+		return domain.ScanInfosDiff{}, err
+	}
+
+	base, err := uc.scanInfosRepo.FindByID(ctx, baseID)
+	if err != nil {
+		return domain.ScanInfosDiff{}, err
+	}
+
+	head, err := uc.scanInfosRepo.FindByID(ctx, headID)
+	if err != nil {
+		return domain.ScanInfosDiff{}, err
+	}
+
+	return domain.DiffScanInfos(base, head), nil
+}
+
+// DiffByRefs compares the latest scans of a repository taken at two commits or tags.
+func (uc *ScanInfosUsecase) DiffByRefs(ctx context.Context, repositoryURL, baseRef, headRef string) (domain.ScanInfosDiff, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.DiffByRefs")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionReadScans); err != nil {
+		return domain.ScanInfosDiff{}, err
+	}
+
+	base, err := uc.latestScanAt(ctx, repositoryURL, baseRef)
+	if err != nil {
+		return domain.ScanInfosDiff{}, err
+	}
+
+	head, err := uc.latestScanAt(ctx, repositoryURL, headRef)
+	if err != nil {
+		return domain.ScanInfosDiff{}, err
+	}
+
+	return domain.DiffScanInfos(base, head), nil
+}
+
+// latestScanAt returns the most recently completed scan of a repository at a
+// given commit or, when none exists, at a given tag.
+func (uc *ScanInfosUsecase) latestScanAt(ctx context.Context, repositoryURL, ref string) (domain.ScanInfos, error) {
+	for _, filter := range []domain.ScanInfosFilter{
+		{RepositoryURL: repositoryURL, CommitID: ref},
+		{RepositoryURL: repositoryURL, TagID: ref},
+	} {
+		page, err := uc.scanInfosRepo.List(ctx, domain.ScanInfosQuery{
+			Filter:     filter,
+			SortBy:     domain.SortByCompletedAt,
+			Descending: true,
+			Limit:      1,
+		})
+		if err != nil {
+			return domain.ScanInfos{}, err
+		}
+
+		if len(page.Infos) > 0 {
+			return page.Infos[0], nil
+		}
+	}
+
+	return domain.ScanInfos{}, domain.NewError(domain.ErrNotFound, fmt.Errorf("no scan infos found for repository %s at %s", repositoryURL, ref))
+}
@@ -0,0 +1,140 @@
+package application
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// APIKeyPrefix starts every API key so that they can be told apart from other
+// bearer credentials and spotted by secret scanners.
+const APIKeyPrefix = "bk_"
+
+// AuthUsecase is handling the API keys and the authentication of the callers.
+type AuthUsecase struct {
+	Logger     *zap.Logger
+	Settings   *config.Live
+	apiKeyRepo domain.APIKeyRepository
+	jwtKeys    JWTKeyProvider
+}
+
+// NewAuthUsecase initialises and returns a new use case for authentication.
+// Bearer JWTs are only accepted when jwtKeys is not nil.
+func NewAuthUsecase(logger *zap.Logger, settings *config.Live, repo domain.APIKeyRepository, jwtKeys JWTKeyProvider) *AuthUsecase {
+	return &AuthUsecase{
+		Logger:     logger,
+		Settings:   settings,
+		apiKeyRepo: repo,
+		jwtKeys:    jwtKeys,
+	}
+}
+
+// Disabled reports whether the authentication of the api calls is turned off.
+func (uc *AuthUsecase) Disabled() bool {
+	return uc.Settings.Load().Auth.Disabled
+}
+
+// CreateAPIKey generates a new API key granting scopes and roles on the scans
+// of a company. It returns the key itself, which is not stored and cannot be
+// retrieved afterwards, along with its stored description.
+func (uc *AuthUsecase) CreateAPIKey(ctx context.Context, name, companyID string, scopes, roles []string) (string, domain.APIKey, error) {
+	if companyID == "" {
+		return "", domain.APIKey{}, domain.NewError(domain.ErrValidation, errors.New("an api key must be bound to a company"))
+	}
+
+	if len(scopes) == 0 && len(roles) == 0 {
+		return "", domain.APIKey{}, domain.NewError(domain.ErrValidation, errors.New("an api key must be granted at least one scope or role"))
+	}
+
+	for _, scope := range scopes {
+		if !domain.IsValidScope(scope) {
+			return "", domain.APIKey{}, domain.NewError(domain.ErrValidation, fmt.Errorf("unknown scope %q. expect read, write or delete", scope))
+		}
+	}
+
+	for _, role := range roles {
+		if !domain.IsValidRole(role) {
+			return "", domain.APIKey{}, domain.NewError(domain.ErrValidation, fmt.Errorf("unknown role %q. expect viewer, submitter, maintainer or admin", role))
+		}
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", domain.APIKey{}, errors.Wrap(err, "could not create api key. unable to generate uuid")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", domain.APIKey{}, errors.Wrap(err, "could not create api key. unable to generate secret")
+	}
+
+	raw := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	key := domain.APIKey{
+		ID:        id.String(),
+		Name:      name,
+		Hash:      hashAPIKey(raw),
+		CompanyID: companyID,
+		Scopes:    scopes,
+		Roles:     roles,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := uc.apiKeyRepo.Save(ctx, key); err != nil {
+		return "", domain.APIKey{}, err
+	}
+	return raw, key, nil
+}
+
+// ListAPIKeys returns the description of all the API keys.
+func (uc *AuthUsecase) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return uc.apiKeyRepo.List(ctx)
+}
+
+// RevokeAPIKey prevents the API key id from being used anymore.
+func (uc *AuthUsecase) RevokeAPIKey(ctx context.Context, id string) error {
+	return uc.apiKeyRepo.Revoke(ctx, id, time.Now().UTC())
+}
+
+// AuthenticateAPIKey returns the caller identified by an API key.
+func (uc *AuthUsecase) AuthenticateAPIKey(ctx context.Context, raw string) (domain.Principal, error) {
+	if !strings.HasPrefix(raw, APIKeyPrefix) {
+		return domain.Principal{}, domain.NewError(domain.ErrUnauthenticated, errors.New("malformed api key"))
+	}
+
+	key, err := uc.apiKeyRepo.FindByHash(ctx, hashAPIKey(raw))
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.Principal{}, domain.NewError(domain.ErrUnauthenticated, errors.New("unknown api key"))
+	}
+	if err != nil {
+		return domain.Principal{}, err
+	}
+
+	if key.Revoked() {
+		return domain.Principal{}, domain.NewError(domain.ErrUnauthenticated, fmt.Errorf("api key %s is revoked", key.ID))
+	}
+
+	return domain.Principal{
+		Subject:   "apikey:" + key.ID,
+		CompanyID: key.CompanyID,
+		Scopes:    key.Scopes,
+		Roles:     key.Roles,
+	}, nil
+}
+
+// hashAPIKey returns the stored form of an API key. Keys are random enough for
+// a fast hash to be safe and to allow looking them up by hash.
+func hashAPIKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
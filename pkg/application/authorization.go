@@ -0,0 +1,42 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/pkg/errors"
+)
+
+// authorize checks that the caller carried by ctx is granted perm, so that
+// every entry point of the use case enforces the same access rules. All the
+// callers are granted every permission when authentication is disabled.
+func (uc *ScanInfosUsecase) authorize(ctx context.Context, perm domain.Permission) error {
+	if uc.Settings.Load().Auth.Disabled {
+		return nil
+	}
+
+	p, ok := domain.PrincipalFromContext(ctx)
+	if !ok {
+		return domain.NewError(domain.ErrUnauthenticated, errors.New("no authenticated caller"))
+	}
+	return p.Require(perm)
+}
+
+// checkClientID ensures that the scan infos is submitted by the client proven
+// by the certificate of the caller or, without certificate, by the client id
+// claim of its JWT. Callers proving no client identity are not checked.
+func checkClientID(ctx context.Context, s domain.ScanInfos) error {
+	if clientID, ok := domain.ClientIDFromContext(ctx); ok {
+		if s.ClientID == clientID {
+			return nil
+		}
+		return domain.NewError(domain.ErrForbidden, fmt.Errorf("client_id %q does not match the client certificate identity %q", s.ClientID, clientID))
+	}
+
+	p, ok := domain.PrincipalFromContext(ctx)
+	if !ok || p.ClientID == "" || s.ClientID == p.ClientID {
+		return nil
+	}
+	return domain.NewError(domain.ErrForbidden, fmt.Errorf("client_id %q does not match the client id %q of the caller", s.ClientID, p.ClientID))
+}
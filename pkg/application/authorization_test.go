@@ -0,0 +1,53 @@
+package application
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/jeamon/backend-api/pkg/interfaces/repository"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestScanInfosUsecaseAuthorization(t *testing.T) {
+	uc := NewScanInfosUsecase(zap.NewNop(), config.NewLive(&config.Config{}), repository.NewInMemoryScanInfosRepository(zap.NewNop(), nil), nil)
+	s := validTestScanInfos()
+	req := domain.StoreScanInfosRequest{
+		CompanyID:     s.CompanyID,
+		Username:      s.Username,
+		ClientID:      s.ClientID,
+		RepositoryURL: s.RepositoryURL,
+		CommitID:      s.CommitID,
+		TagID:         s.TagID,
+		Results:       s.Results,
+		StartedAt:     s.StartedAt,
+		CompletedAt:   s.CompletedAt,
+		SentAt:        s.SentAt,
+		Metadata:      s.Metadata,
+	}
+
+	_, err := uc.Store(context.Background(), req)
+	assert.ErrorIs(t, err, domain.ErrUnauthenticated)
+
+	submitter := domain.WithPrincipal(context.Background(), domain.Principal{CompanyID: s.CompanyID, Roles: []string{domain.RoleSubmitter}})
+	id, err := uc.Store(submitter, req)
+	assert.NoError(t, err)
+
+	_, err = uc.Get(submitter, id)
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+	assert.EqualError(t, err, "missing permission: scans:read")
+
+	err = uc.Delete(submitter, id, 0)
+	assert.ErrorIs(t, err, domain.ErrForbidden)
+
+	admin := domain.WithPrincipal(context.Background(), domain.Principal{Roles: []string{domain.RoleAdmin}})
+	found, err := uc.Get(admin, id)
+	assert.NoError(t, err)
+	assert.Equal(t, s.CompanyID, found.CompanyID)
+
+	other := domain.WithPrincipal(context.Background(), domain.Principal{CompanyID: "1", Roles: []string{domain.RoleMaintainer}})
+	err = uc.Delete(other, id, 0)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
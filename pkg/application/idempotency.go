@@ -0,0 +1,70 @@
+package application
+
+import (
+	"context"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// DefaultIdempotencyTTL is how long an idempotency key is remembered when the
+// configuration does not set it.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// DefaultIdempotencyLockTimeout is how long a request processing a key holds
+// it when the configuration does not set it.
+const DefaultIdempotencyLockTimeout = time.Minute
+
+// IdempotencyUsecase is handling the idempotency keys sent by the clients to
+// safely retry their requests.
+type IdempotencyUsecase struct {
+	Logger          *zap.Logger
+	Settings        *config.Live
+	idempotencyRepo domain.IdempotencyRepository
+}
+
+// NewIdempotencyUsecase initialises and returns a new use case for idempotency keys.
+func NewIdempotencyUsecase(logger *zap.Logger, settings *config.Live, repo domain.IdempotencyRepository) *IdempotencyUsecase {
+	return &IdempotencyUsecase{
+		Logger:          logger,
+		Settings:        settings,
+		idempotencyRepo: repo,
+	}
+}
+
+// Reserve claims the key for a request identified by requestHash. When the
+// key is already in use, it returns the record of the original request. The
+// key is held for the lock timeout, after which a retry of the same request
+// can take it over if the original request never completed.
+func (uc *IdempotencyUsecase) Reserve(ctx context.Context, key, requestHash string) (domain.IdempotencyRecord, bool, error) {
+	cfg := uc.Settings.Load().Idempotency
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	lockTimeout := cfg.LockTimeout
+	if lockTimeout <= 0 {
+		lockTimeout = DefaultIdempotencyLockTimeout
+	}
+
+	now := time.Now().UTC()
+	return uc.idempotencyRepo.Reserve(ctx, domain.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+		LockedUntil: now.Add(lockTimeout),
+	})
+}
+
+// Complete remembers the response of the request which reserved the key.
+func (uc *IdempotencyUsecase) Complete(ctx context.Context, key string, statusCode int, response []byte) error {
+	return uc.idempotencyRepo.Complete(ctx, key, statusCode, response)
+}
+
+// Release frees the key of a request which did not succeed so that it can be retried.
+func (uc *IdempotencyUsecase) Release(ctx context.Context, key string) error {
+	return uc.idempotencyRepo.Release(ctx, key)
+}
@@ -0,0 +1,104 @@
+package application
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/pkg/errors"
+)
+
+// JWTKeyProvider gives the public keys verifying the signature of the bearer
+// JWTs. Keys are looked up on each call so that they can be rotated.
+type JWTKeyProvider interface {
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// jwtMethods are the accepted signature algorithms. Symmetric algorithms and
+// unsigned tokens are refused since only public keys are configured.
+var jwtMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// JWTEnabled reports whether bearer JWTs are accepted.
+func (uc *AuthUsecase) JWTEnabled() bool {
+	return uc.jwtKeys != nil
+}
+
+// AuthenticateJWT returns the caller identified by a bearer JWT. The token
+// must be signed by one of the configured keys, be currently valid and, when
+// configured, be issued by the expected issuer for the expected audience.
+func (uc *AuthUsecase) AuthenticateJWT(ctx context.Context, raw string) (domain.Principal, error) {
+	if uc.jwtKeys == nil {
+		return domain.Principal{}, domain.NewError(domain.ErrUnauthenticated, errors.New("bearer jwts are not accepted"))
+	}
+
+	cfg := uc.Settings.Load().Auth.JWT
+	claims := jwt.MapClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(jwtMethods), jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return uc.jwtKeys.Key(kid)
+	})
+	if err != nil {
+		return domain.Principal{}, domain.NewError(domain.ErrUnauthenticated, errors.Wrap(err, "invalid jwt"))
+	}
+
+	now := time.Now().Unix()
+	leeway := int64(cfg.Leeway / time.Second)
+	switch {
+	case !claims.VerifyExpiresAt(now-leeway, true):
+		err = errors.New("jwt is expired or has no expiration time")
+	case !claims.VerifyNotBefore(now+leeway, false):
+		err = errors.New("jwt is not valid yet")
+	case !claims.VerifyIssuer(cfg.Issuer, true):
+		err = errors.New("jwt issuer is not accepted")
+	case !claims.VerifyAudience(cfg.Audience, true):
+		err = errors.New("jwt audience is not accepted")
+	}
+	if err != nil {
+		return domain.Principal{}, domain.NewError(domain.ErrUnauthenticated, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	companyID, _ := claims[cfg.CompanyClaim].(string)
+	clientID, _ := claims[cfg.ClientIDClaim].(string)
+	p := domain.Principal{
+		Subject:   "jwt:" + subject,
+		CompanyID: companyID,
+		ClientID:  clientID,
+		Scopes:    claimStrings(claims["scope"]),
+		Roles:     claimStrings(claims[cfg.RolesClaim]),
+	}
+
+	// Administrators of all the companies do not need to belong to one.
+	if companyID == "" && !p.Can(domain.PermissionAllCompanies) {
+		return domain.Principal{}, domain.NewError(domain.ErrUnauthenticated, fmt.Errorf("jwt has no %q claim", cfg.CompanyClaim))
+	}
+	return p, nil
+}
+
+// claimStrings reads a claim holding either a list of strings or a space
+// separated string like the OAuth2 scope claim.
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
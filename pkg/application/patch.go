@@ -0,0 +1,98 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/tracing"
+	"github.com/pkg/errors"
+)
+
+// Patch applies a JSON Merge Patch or a JSON Patch document to the scan infos
+// id and persists the result once validated. A non-zero version makes the
+// patch apply only when it matches the stored version. It returns the patched
+// scan infos.
+func (uc *ScanInfosUsecase) Patch(ctx context.Context, id, patchType string, patch []byte, version int64) (domain.ScanInfos, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ScanInfosUsecase.Patch")
+	defer span.End()
+
+	if err := uc.authorize(ctx, domain.PermissionUpdateScans); err != nil {
+		return domain.ScanInfos{}, err
+	}
+
+	current, err := uc.scanInfosRepo.FindByID(ctx, id)
+	if err != nil {
+		return domain.ScanInfos{}, err
+	}
+
+	if version != 0 && version != current.Version {
+		return domain.ScanInfos{}, domain.NewError(domain.ErrPreconditionFailed, fmt.Errorf("scan infos %s is at version %d", id, current.Version))
+	}
+
+	patched, err := applyPatch(current, patchType, patch)
+	if err != nil {
+		return domain.ScanInfos{}, err
+	}
+
+	if patched.ID != current.ID {
+		return domain.ScanInfos{}, domain.NewError(domain.ErrValidation, errors.New("the id of a scan infos cannot be modified"))
+	}
+
+	if err := checkClientID(ctx, patched); err != nil {
+		return domain.ScanInfos{}, err
+	}
+
+	if err := uc.validator.Validate(patched); err != nil {
+		return domain.ScanInfos{}, err
+	}
+
+	// the stored version guards against concurrent writes between the
+	// read above and the update.
+	patched.Version = current.Version
+	patched.CreatedAt = current.CreatedAt
+	domain.FillFingerprints(patched.Findings)
+	if err := uc.scanInfosRepo.UpdateByID(ctx, id, patched); err != nil {
+		return domain.ScanInfos{}, err
+	}
+
+	patched.Version++
+	return patched, nil
+}
+
+// applyPatch returns a copy of the scan infos modified by the patch document.
+func applyPatch(s domain.ScanInfos, patchType string, patch []byte) (domain.ScanInfos, error) {
+	doc, err := json.Marshal(s)
+	if err != nil {
+		return s, errors.Wrap(err, "cannot encode scan infos to patch")
+	}
+
+	switch patchType {
+	case domain.MergePatchType:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	case domain.JSONPatchType:
+		var ops jsonpatch.Patch
+		ops, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			doc, err = ops.Apply(doc)
+		}
+	default:
+		return s, domain.NewError(domain.ErrValidation, fmt.Errorf("unsupported patch type %q", patchType))
+	}
+
+	if errors.Is(err, jsonpatch.ErrTestFailed) {
+		return s, domain.NewError(domain.ErrConflict, errors.Wrap(err, "cannot apply patch"))
+	}
+	if err != nil {
+		return s, domain.NewError(domain.ErrValidation, errors.Wrap(err, "cannot apply patch"))
+	}
+
+	var patched domain.ScanInfos
+	if err := json.Unmarshal(doc, &patched); err != nil {
+		return s, domain.NewError(domain.ErrValidation, errors.Wrap(err, "invalid patched scan infos"))
+	}
+
+	return patched, nil
+}
@@ -0,0 +1,144 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"go.uber.org/zap"
+)
+
+// QuotaUsecase is counting the scans submitted by each company per UTC day
+// against their configured daily quota.
+type QuotaUsecase struct {
+	Logger    *zap.Logger
+	Settings  *config.Live
+	quotaRepo domain.QuotaRepository
+	now       func() time.Time
+}
+
+// NewQuotaUsecase initialises and returns a new use case for quotas.
+func NewQuotaUsecase(logger *zap.Logger, settings *config.Live, repo domain.QuotaRepository) *QuotaUsecase {
+	return &QuotaUsecase{
+		Logger:    logger,
+		Settings:  settings,
+		quotaRepo: repo,
+		now:       time.Now,
+	}
+}
+
+// Limit returns the daily quota of a company. Zero means no limit.
+func (uc *QuotaUsecase) Limit(companyID string) int64 {
+	quota := uc.Settings.Load().Quota
+	for _, q := range quota.Companies {
+		if q.CompanyID == companyID {
+			return q.DailySubmissions
+		}
+	}
+	return quota.DailySubmissions
+}
+
+// Consume counts n submissions of a company for the current day and returns
+// that day, which the submissions are refunded to if they cannot be stored. It
+// fails with a domain.QuotaExceededError when they exceed the company quota.
+func (uc *QuotaUsecase) Consume(ctx context.Context, companyID string, n int64) (string, error) {
+	now := uc.now().UTC()
+	day := now.Format(domain.QuotaDayLayout)
+	limit := uc.Limit(companyID)
+	_, ok, err := uc.quotaRepo.Consume(ctx, companyID, day, n, limit)
+	if err != nil {
+		return day, err
+	}
+
+	if !ok {
+		return day, &domain.QuotaExceededError{
+			CompanyID: companyID,
+			Limit:     limit,
+			ResetAt:   now.Truncate(24 * time.Hour).Add(24 * time.Hour),
+		}
+	}
+	return day, nil
+}
+
+// Refund uncounts n submissions of a company for the day they were counted
+// on, once they could not be stored.
+func (uc *QuotaUsecase) Refund(ctx context.Context, companyID, day string, n int64) {
+	err := uc.quotaRepo.Refund(ctx, companyID, day, n)
+	if err != nil {
+		uc.Logger.Error("failed to refund quota", zap.String("requestid", domain.RequestIDFromContext(ctx)), zap.String("company_id", companyID), zap.String("day", day), zap.Int64("count", n), zap.Error(err))
+	}
+}
+
+// Usages returns the submissions counted for the day, which defaults to the
+// current one, along with the quota of each company.
+func (uc *QuotaUsecase) Usages(ctx context.Context, day string) ([]domain.QuotaUsage, error) {
+	if day == "" {
+		day = uc.now().UTC().Format(domain.QuotaDayLayout)
+	}
+
+	if _, err := time.Parse(domain.QuotaDayLayout, day); err != nil {
+		return nil, domain.NewError(domain.ErrValidation, fmt.Errorf("invalid day %q. expect the %s format", day, domain.QuotaDayLayout))
+	}
+
+	usages, err := uc.quotaRepo.List(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range usages {
+		usages[i].Limit = uc.Limit(usages[i].CompanyID)
+	}
+	return usages, nil
+}
+
+// companyCounts returns the number of scans of each company in order of
+// first appearance.
+func companyCounts(infos []domain.ScanInfos) ([]string, map[string]int64) {
+	companies := []string{}
+	counts := map[string]int64{}
+	for _, s := range infos {
+		if counts[s.CompanyID] == 0 {
+			companies = append(companies, s.CompanyID)
+		}
+		counts[s.CompanyID]++
+	}
+	return companies, counts
+}
+
+// consumeQuotas counts the scans against the daily quota of their company and
+// returns the day each company was charged on. Nothing is counted when one of
+// the companies exceeds its quota.
+func (uc *ScanInfosUsecase) consumeQuotas(ctx context.Context, infos []domain.ScanInfos) (map[string]string, error) {
+	days := map[string]string{}
+	if uc.quotas == nil {
+		return days, nil
+	}
+
+	companies, counts := companyCounts(infos)
+	for _, companyID := range companies {
+		day, err := uc.quotas.Consume(ctx, companyID, counts[companyID])
+		if err != nil {
+			uc.refundQuotas(ctx, days, infos)
+			return days, err
+		}
+		days[companyID] = day
+	}
+	return days, nil
+}
+
+// refundQuotas uncounts scans which could not be stored from the days their
+// company was charged on. The scans of the companies not charged are skipped.
+func (uc *ScanInfosUsecase) refundQuotas(ctx context.Context, days map[string]string, infos []domain.ScanInfos) {
+	if uc.quotas == nil {
+		return
+	}
+
+	companies, counts := companyCounts(infos)
+	for _, companyID := range companies {
+		if day, charged := days[companyID]; charged {
+			uc.quotas.Refund(ctx, companyID, day, counts[companyID])
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/jeamon/backend-api/pkg/interfaces/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// failingRepository fails the saves of a scan infos repository after running
+// before, like a database timing out across midnight.
+type failingRepository struct {
+	domain.ScanInfosRepository
+	before func()
+}
+
+func (f failingRepository) Save(ctx context.Context, s domain.ScanInfos) (string, error) {
+	f.before()
+	return "", errors.New("connection reset")
+}
+
+func TestQuotaRefundedToChargedDay(t *testing.T) {
+	ctx := context.Background()
+	quotaRepo := repository.NewInMemoryQuotaRepository(zap.NewNop(), nil)
+	settings := &config.Config{}
+	settings.Auth.Disabled = true
+	settings.Quota.DailySubmissions = 10
+	quotas := NewQuotaUsecase(zap.NewNop(), config.NewLive(settings), quotaRepo)
+	now := time.Date(2022, 6, 22, 23, 59, 59, 0, time.UTC)
+	quotas.now = func() time.Time { return now }
+
+	_, ok, err := quotaRepo.Consume(ctx, "0", "2022-06-23", 1, 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	repo := failingRepository{
+		ScanInfosRepository: repository.NewInMemoryScanInfosRepository(zap.NewNop(), nil),
+		before:              func() { now = now.Add(2 * time.Second) },
+	}
+	uc := NewScanInfosUsecase(zap.NewNop(), config.NewLive(settings), repo, quotas)
+	_, err = uc.save(ctx, validTestScanInfos())
+	assert.EqualError(t, err, "connection reset")
+
+	usages, err := quotaRepo.List(ctx, "2022-06-22")
+	require.NoError(t, err)
+	assert.Equal(t, []domain.QuotaUsage{{CompanyID: "0", Day: "2022-06-22", Count: 0}}, usages)
+
+	usages, err = quotaRepo.List(ctx, "2022-06-23")
+	require.NoError(t, err)
+	assert.Equal(t, []domain.QuotaUsage{{CompanyID: "0", Day: "2022-06-23", Count: 1}}, usages)
+}
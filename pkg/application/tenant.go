@@ -0,0 +1,150 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/pkg/errors"
+)
+
+// tenantScanInfosRepository constrains every call of a scan infos repository
+// to the company of the caller found into the context, so that a tenant can
+// never read nor modify the scans of another one. Calls without caller fail.
+// Callers granted the permission to access all companies are not constrained.
+type tenantScanInfosRepository struct {
+	repo domain.ScanInfosRepository
+}
+
+// newTenantScanInfosRepository wraps repo into a repository isolating tenants.
+func newTenantScanInfosRepository(repo domain.ScanInfosRepository) *tenantScanInfosRepository {
+	return &tenantScanInfosRepository{repo: repo}
+}
+
+// caller returns the company of the authenticated caller. It is empty when the
+// caller can access the scans of all companies.
+func (t *tenantScanInfosRepository) caller(ctx context.Context) (string, error) {
+	p, ok := domain.PrincipalFromContext(ctx)
+	if ok && p.Can(domain.PermissionAllCompanies) {
+		return "", nil
+	}
+
+	if !ok || p.CompanyID == "" {
+		return "", domain.NewError(domain.ErrUnauthenticated, errors.New("no authenticated caller"))
+	}
+	return p.CompanyID, nil
+}
+
+// owned checks that the scan infos belongs to the company. Every scan infos
+// belongs to the empty company of the callers accessing all companies.
+func owned(companyID string, s domain.ScanInfos) error {
+	if companyID != "" && s.CompanyID != companyID {
+		return domain.NewError(domain.ErrForbidden, fmt.Errorf("scan infos of company %q cannot be written by company %q", s.CompanyID, companyID))
+	}
+	return nil
+}
+
+func (t *tenantScanInfosRepository) Save(ctx context.Context, s domain.ScanInfos) (string, error) {
+	companyID, err := t.caller(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if err := owned(companyID, s); err != nil {
+		return "", err
+	}
+	return t.repo.Save(ctx, s)
+}
+
+func (t *tenantScanInfosRepository) SaveBatch(ctx context.Context, infos []domain.ScanInfos) ([]string, error) {
+	companyID, err := t.caller(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range infos {
+		if err := owned(companyID, infos[i]); err != nil {
+			return nil, errors.Wrapf(err, "item %d", i)
+		}
+	}
+	return t.repo.SaveBatch(ctx, infos)
+}
+
+// FindByID reports the scans of other companies as not found to not leak
+// their existence.
+func (t *tenantScanInfosRepository) FindByID(ctx context.Context, id string) (domain.ScanInfos, error) {
+	companyID, err := t.caller(ctx)
+	if err != nil {
+		return domain.ScanInfos{}, err
+	}
+
+	s, err := t.repo.FindByID(ctx, id)
+	if err != nil {
+		return s, err
+	}
+
+	if companyID != "" && s.CompanyID != companyID {
+		return domain.ScanInfos{}, domain.NewError(domain.ErrNotFound, fmt.Errorf("could not find scan infos with ID: %s", id))
+	}
+	return s, nil
+}
+
+func (t *tenantScanInfosRepository) UpdateByID(ctx context.Context, id string, s domain.ScanInfos) error {
+	companyID, err := t.caller(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := owned(companyID, s); err != nil {
+		return err
+	}
+
+	if _, err := t.FindByID(ctx, id); err != nil {
+		return err
+	}
+	return t.repo.UpdateByID(ctx, id, s)
+}
+
+func (t *tenantScanInfosRepository) DeleteByID(ctx context.Context, id string, version int64) error {
+	if _, err := t.FindByID(ctx, id); err != nil {
+		return err
+	}
+	return t.repo.DeleteByID(ctx, id, version)
+}
+
+// List only returns the scans of the caller company whatever the filter.
+func (t *tenantScanInfosRepository) List(ctx context.Context, q domain.ScanInfosQuery) (domain.ScanInfosPage, error) {
+	companyID, err := t.caller(ctx)
+	if err != nil {
+		return domain.ScanInfosPage{Infos: []domain.ScanInfos{}}, err
+	}
+
+	if companyID == "" {
+		return t.repo.List(ctx, q)
+	}
+
+	if q.Filter.CompanyID != "" && q.Filter.CompanyID != companyID {
+		if err := q.Normalize(); err != nil {
+			return domain.ScanInfosPage{Infos: []domain.ScanInfos{}}, err
+		}
+		return domain.ScanInfosPage{Infos: []domain.ScanInfos{}}, nil
+	}
+
+	q.Filter.CompanyID = companyID
+	return t.repo.List(ctx, q)
+}
+
+// Import only accepts the scans of the caller company.
+func (t *tenantScanInfosRepository) Import(ctx context.Context, infos []domain.ScanInfos) (int, error) {
+	companyID, err := t.caller(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range infos {
+		if err := owned(companyID, infos[i]); err != nil {
+			return 0, errors.Wrapf(err, "item %d", i)
+		}
+	}
+	return t.repo.Import(ctx, infos)
+}
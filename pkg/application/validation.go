@@ -0,0 +1,151 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+)
+
+// Limits applied when the configuration does not set them.
+const (
+	DefaultMaxResults       = 1000
+	DefaultMaxMetadataBytes = 64 * 1024
+	DefaultMaxClockSkew     = time.Hour
+)
+
+// commitIDPattern matches full or abbreviated SHA-1 and SHA-256 commit hashes.
+var commitIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+
+// repositoryURLSchemes are the schemes of the accepted repository URLs.
+var repositoryURLSchemes = map[string]bool{"http": true, "https": true, "ssh": true, "git": true}
+
+// structValidator checks the binding constraints declared on the domain
+// models, the same way the web layer does when binding a request body.
+// Fields are named after their json key.
+var structValidator = newStructValidator()
+
+func newStructValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// ScanInfosValidator checks that a scan infos is consistent before it is
+// stored. Its limits are read from the current settings on every check so
+// that they follow configuration reloads.
+type ScanInfosValidator struct {
+	Settings *config.Live
+	now      func() time.Time
+}
+
+// NewScanInfosValidator provides an instance of ScanInfosValidator structure.
+func NewScanInfosValidator(settings *config.Live) *ScanInfosValidator {
+	return &ScanInfosValidator{Settings: settings, now: time.Now}
+}
+
+// Validate returns a domain.ValidationErrors listing every invalid field of
+// the scan infos or nil when it is valid. The ID is not checked since a scan
+// infos to store does not have one yet.
+func (v *ScanInfosValidator) Validate(s domain.ScanInfos) error {
+	var errs domain.ValidationErrors
+	errs = append(errs, structErrors("", structValidator.StructExcept(s, "ID"))...)
+	for i := range s.Findings {
+		errs = append(errs, structErrors(fmt.Sprintf("findings[%d].", i), structValidator.Struct(s.Findings[i]))...)
+	}
+
+	if s.CommitID != "" && !commitIDPattern.MatchString(s.CommitID) {
+		errs = append(errs, domain.FieldError{Field: "commit_id", Message: "must be a hexadecimal commit hash of 7 to 64 characters"})
+	}
+
+	if s.RepositoryURL != "" && !isRepositoryURL(s.RepositoryURL) {
+		errs = append(errs, domain.FieldError{Field: "repository_url", Message: "must be an absolute http, https, ssh or git url"})
+	}
+
+	if s.StartedAt != 0 && s.CompletedAt != 0 && s.CompletedAt < s.StartedAt {
+		errs = append(errs, domain.FieldError{Field: "completed_at", Message: "must not be before started_at"})
+	}
+
+	limits := v.Settings.Load().Validation
+	skew := limits.MaxClockSkew
+	if skew <= 0 {
+		skew = DefaultMaxClockSkew
+	}
+	if limit := v.now().Add(skew).Unix(); s.SentAt > limit {
+		errs = append(errs, domain.FieldError{Field: "sent_at", Message: fmt.Sprintf("must not be more than %s in the future", skew)})
+	}
+
+	maxResults := limits.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultMaxResults
+	}
+	if len(s.Results) > maxResults {
+		errs = append(errs, domain.FieldError{Field: "results", Message: fmt.Sprintf("must hold at most %d items", maxResults)})
+	}
+	if len(s.Findings) > maxResults {
+		errs = append(errs, domain.FieldError{Field: "findings", Message: fmt.Sprintf("must hold at most %d items", maxResults)})
+	}
+
+	maxMetadata := limits.MaxMetadataBytes
+	if maxMetadata <= 0 {
+		maxMetadata = DefaultMaxMetadataBytes
+	}
+	if data, err := json.Marshal(s.Metadata); err != nil {
+		errs = append(errs, domain.FieldError{Field: "metadata", Message: "must be encodable to json"})
+	} else if len(data) > maxMetadata {
+		errs = append(errs, domain.FieldError{Field: "metadata", Message: fmt.Sprintf("must not exceed %d bytes once json encoded", maxMetadata)})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// isRepositoryURL reports whether value is an absolute url of a repository.
+func isRepositoryURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && repositoryURLSchemes[strings.ToLower(u.Scheme)] && u.Host != ""
+}
+
+// structErrors converts the errors reported by the struct validator into
+// field errors whose names are prefixed by prefix.
+func structErrors(prefix string, err error) domain.ValidationErrors {
+	verrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		if err != nil {
+			return domain.ValidationErrors{{Field: strings.TrimSuffix(prefix, "."), Message: err.Error()}}
+		}
+		return nil
+	}
+
+	errs := make(domain.ValidationErrors, 0, len(verrs))
+	for _, e := range verrs {
+		field := e.Namespace()
+		// drop the name of the validated struct.
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+
+		msg := "is required"
+		if e.Tag() != "required" {
+			msg = fmt.Sprintf("must satisfy %s", strings.TrimSpace(e.Tag()+" "+e.Param()))
+		}
+		errs = append(errs, domain.FieldError{Field: prefix + field, Message: msg})
+	}
+	return errs
+}
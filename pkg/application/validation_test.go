@@ -0,0 +1,75 @@
+package application
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func validTestScanInfos() domain.ScanInfos {
+	return domain.ScanInfos{
+		CompanyID:     "0",
+		Username:      "jeamon",
+		ClientID:      "v1.0.0",
+		RepositoryURL: "https://github.com/jeamon/backend-api",
+		CommitID:      "d7b8ff1412ebfcde26f9ddfdf9608d1525647958",
+		TagID:         "v1.0.0",
+		Results:       []string{"found something"},
+		StartedAt:     1655903720,
+		CompletedAt:   1655903723,
+		SentAt:        1655903725,
+		Metadata:      map[string]interface{}{"os": "linux"},
+	}
+}
+
+func TestScanInfosValidator(t *testing.T) {
+	now := time.Unix(1655903800, 0)
+	cfg := &config.Config{}
+	cfg.Validation.MaxResults = 2
+	cfg.Validation.MaxMetadataBytes = 32
+	v := NewScanInfosValidator(config.NewLive(cfg))
+	v.now = func() time.Time { return now }
+
+	tests := []struct {
+		name   string
+		modify func(s *domain.ScanInfos)
+		fields []string
+	}{
+		{"valid scan infos", func(s *domain.ScanInfos) {}, nil},
+		{"abbreviated commit id", func(s *domain.ScanInfos) { s.CommitID = "d7b8ff1" }, nil},
+		{"ssh repository url", func(s *domain.ScanInfos) { s.RepositoryURL = "ssh://git@github.com/jeamon/backend-api.git" }, nil},
+		{"missing required fields", func(s *domain.ScanInfos) { s.CompanyID, s.Metadata = "", nil }, []string{"company_id", "metadata"}},
+		{"completed before started", func(s *domain.ScanInfos) { s.CompletedAt = s.StartedAt - 1 }, []string{"completed_at"}},
+		{"sent in the future", func(s *domain.ScanInfos) { s.SentAt = now.Add(365 * 24 * time.Hour).Unix() }, []string{"sent_at"}},
+		{"commit id is not a hash", func(s *domain.ScanInfos) { s.CommitID = "main" }, []string{"commit_id"}},
+		{"repository url is not an url", func(s *domain.ScanInfos) { s.RepositoryURL = "backend-api" }, []string{"repository_url"}},
+		{"too many results", func(s *domain.ScanInfos) { s.Results = []string{"a", "b", "c"} }, []string{"results"}},
+		{"metadata too large", func(s *domain.ScanInfos) { s.Metadata["notes"] = strings.Repeat("x", 32) }, []string{"metadata"}},
+		{"invalid finding", func(s *domain.ScanInfos) {
+			s.Findings = []domain.Finding{{RuleID: "G101", Severity: "urgent", Title: "credentials", Fingerprint: "f"}}
+		}, []string{"findings[0].severity"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := validTestScanInfos()
+			tc.modify(&s)
+			err := v.Validate(s)
+			if tc.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, domain.ErrValidation)
+			var fields []string
+			for _, f := range err.(domain.ValidationErrors) {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, tc.fields, fields)
+		})
+	}
+}
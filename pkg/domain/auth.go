@@ -0,0 +1,162 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Scopes granted to an API key.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+)
+
+// IsValidScope reports whether scope is a known scope.
+func IsValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite || scope == ScopeDelete
+}
+
+// Permission allows an operation on the scans.
+type Permission string
+
+// Permissions required by the scan operations. Unless granted the permission
+// to access all companies, a caller is restricted to the scans of its company.
+const (
+	PermissionReadScans    Permission = "scans:read"
+	PermissionCreateScans  Permission = "scans:create"
+	PermissionUpdateScans  Permission = "scans:update"
+	PermissionDeleteScans  Permission = "scans:delete"
+	PermissionAllCompanies Permission = "scans:all_companies"
+)
+
+// Roles granted to a caller.
+const (
+	// RoleViewer reads the scans.
+	RoleViewer = "viewer"
+	// RoleSubmitter only submits new scans.
+	RoleSubmitter = "submitter"
+	// RoleMaintainer reads, submits, updates and deletes the scans.
+	RoleMaintainer = "maintainer"
+	// RoleAdmin manages the scans of all the companies.
+	RoleAdmin = "admin"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleViewer:    {PermissionReadScans},
+	RoleSubmitter: {PermissionCreateScans},
+	RoleMaintainer: {
+		PermissionReadScans, PermissionCreateScans, PermissionUpdateScans, PermissionDeleteScans,
+	},
+	RoleAdmin: {
+		PermissionReadScans, PermissionCreateScans, PermissionUpdateScans, PermissionDeleteScans,
+		PermissionAllCompanies,
+	},
+}
+
+// scopePermissions are the permissions granted by the scopes of an API key.
+var scopePermissions = map[string][]Permission{
+	ScopeRead:   {PermissionReadScans},
+	ScopeWrite:  {PermissionCreateScans, PermissionUpdateScans},
+	ScopeDelete: {PermissionDeleteScans},
+}
+
+// IsValidRole reports whether role is a known role.
+func IsValidRole(role string) bool {
+	_, found := rolePermissions[role]
+	return found
+}
+
+// APIKey grants access to the scans of a company. Only the hash of the key is
+// stored. The key itself is shown once, when created.
+type APIKey struct {
+	ID        string     `db:"id" json:"id" bson:"_id"`
+	Name      string     `db:"name" json:"name" bson:"name"`
+	Hash      string     `db:"key_hash" json:"-" bson:"key_hash"`
+	CompanyID string     `db:"company_id" json:"company_id" bson:"company_id"`
+	Scopes    []string   `db:"scopes" json:"scopes" bson:"scopes"`
+	Roles     []string   `db:"roles" json:"roles" bson:"roles"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at" bson:"created_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key can no longer be used.
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// APIKeyRepository stores the API keys into the active database.
+type APIKeyRepository interface {
+	Save(ctx context.Context, key APIKey) error
+	FindByHash(ctx context.Context, hash string) (APIKey, error)
+	List(ctx context.Context) ([]APIKey, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+}
+
+// Principal is the authenticated caller of an operation.
+type Principal struct {
+	// Subject identifies the credentials used by the caller.
+	Subject   string
+	CompanyID string
+	ClientID  string
+	Scopes    []string
+	Roles     []string
+}
+
+// Can reports whether the roles or the scopes of the caller grant perm.
+func (p Principal) Can(perm Permission) bool {
+	for _, role := range p.Roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == perm {
+				return true
+			}
+		}
+	}
+
+	for _, scope := range p.Scopes {
+		for _, granted := range scopePermissions[scope] {
+			if granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Require returns an error of the ErrForbidden kind naming perm when the
+// caller is not granted perm.
+func (p Principal) Require(perm Permission) error {
+	if p.Can(perm) {
+		return nil
+	}
+	return NewError(ErrForbidden, fmt.Errorf("missing permission: %s", perm))
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the authenticated caller carried by ctx.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+type clientIDKey struct{}
+
+// WithClientID returns a copy of ctx carrying the client identity proven by
+// the certificate of the caller.
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, clientID)
+}
+
+// ClientIDFromContext returns the client identity proven by the certificate of
+// the caller carried by ctx.
+func ClientIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(clientIDKey{}).(string)
+	return id, ok
+}
@@ -0,0 +1,35 @@
+package domain
+
+// Modes of a batch submission of scan infos.
+const (
+	// BatchModeAtomic stores all the items of a batch or none of them.
+	BatchModeAtomic = "atomic"
+	// BatchModeBestEffort stores the valid items of a batch and reports the
+	// failure of each invalid one.
+	BatchModeBestEffort = "best_effort"
+)
+
+// MaxBatchSize is the maximum number of scan infos accepted in a single batch.
+const MaxBatchSize = 1000
+
+// IsValidBatchMode reports whether mode is a supported batch mode.
+func IsValidBatchMode(mode string) bool {
+	return mode == BatchModeAtomic || mode == BatchModeBestEffort
+}
+
+// BatchItem is a scan submission read from a batch. Err is set when the item
+// could not be decoded.
+type BatchItem struct {
+	Request StoreScanInfosRequest
+	Err     error
+}
+
+// BatchItemResult reports the outcome of a batch item: the ID of the stored
+// scan infos or the reason it was not stored.
+type BatchItemResult struct {
+	Index       int    `json:"index"`
+	ScanInfosID string `json:"scaninfos_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// Errors lists the invalid fields of an item rejected by the validation.
+	Errors ValidationErrors `json:"errors,omitempty"`
+}
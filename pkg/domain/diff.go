@@ -0,0 +1,89 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// DiffEntry is a single finding of a scan to scan comparison. Structured
+// findings are reported as is while legacy results are reported as text.
+type DiffEntry struct {
+	Fingerprint string   `json:"fingerprint"`
+	Finding     *Finding `json:"finding,omitempty"`
+	Result      string   `json:"result,omitempty"`
+}
+
+// ScanInfosDiff holds the findings introduced, resolved and persisted between
+// a base scan and a head scan.
+type ScanInfosDiff struct {
+	BaseID    string      `json:"base_id"`
+	HeadID    string      `json:"head_id"`
+	New       []DiffEntry `json:"new"`
+	Fixed     []DiffEntry `json:"fixed"`
+	Unchanged []DiffEntry `json:"unchanged"`
+}
+
+// DiffScanInfos compares the findings of two scans. Findings are matched by
+// fingerprint and legacy results by their normalized text. A finding reported
+// several times is matched as many times as it appears on both sides.
+func DiffScanInfos(base, head ScanInfos) ScanInfosDiff {
+	diff := ScanInfosDiff{
+		BaseID:    base.ID,
+		HeadID:    head.ID,
+		New:       []DiffEntry{},
+		Fixed:     []DiffEntry{},
+		Unchanged: []DiffEntry{},
+	}
+
+	remaining := make(map[string][]DiffEntry)
+	baseEntries := diffEntries(base)
+	for _, e := range baseEntries {
+		remaining[e.Fingerprint] = append(remaining[e.Fingerprint], e)
+	}
+
+	for _, e := range diffEntries(head) {
+		if matches := remaining[e.Fingerprint]; len(matches) > 0 {
+			remaining[e.Fingerprint] = matches[1:]
+			diff.Unchanged = append(diff.Unchanged, e)
+			continue
+		}
+		diff.New = append(diff.New, e)
+	}
+
+	// walk the base entries again to report the fixed ones in their order.
+	for _, e := range baseEntries {
+		if matches := remaining[e.Fingerprint]; len(matches) > 0 {
+			remaining[e.Fingerprint] = matches[1:]
+			diff.Fixed = append(diff.Fixed, e)
+		}
+	}
+
+	return diff
+}
+
+// diffEntries lists the findings and the legacy results of a scan.
+func diffEntries(s ScanInfos) []DiffEntry {
+	entries := make([]DiffEntry, 0, len(s.Findings)+len(s.Results))
+	for i := range s.Findings {
+		f := s.Findings[i]
+		if f.Fingerprint == "" {
+			f.Fingerprint = f.ComputeFingerprint()
+		}
+		entries = append(entries, DiffEntry{Fingerprint: f.Fingerprint, Finding: &f})
+	}
+
+	for _, r := range s.Results {
+		entries = append(entries, DiffEntry{Fingerprint: ResultFingerprint(r), Result: r})
+	}
+
+	return entries
+}
+
+// ResultFingerprint returns the fingerprint of a legacy free-form result.
+// The text is compared case insensitively and regardless of its spacing.
+func ResultFingerprint(result string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(result), " "))
+	sum := sha256.Sum256([]byte("result:" + normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffScanInfos(t *testing.T) {
+	leak := Finding{RuleID: "G101", Severity: SeverityHigh, Title: "hardcoded credentials", FilePath: "main.go", StartLine: 10}
+	moved := leak
+	moved.StartLine = 42
+	sqli := Finding{RuleID: "G201", Severity: SeverityMedium, Title: "sql string formatting", FilePath: "repo.go"}
+	xss := Finding{RuleID: "G203", Severity: SeverityLow, Title: "unescaped html", FilePath: "web.go", Fingerprint: "custom"}
+
+	base := ScanInfos{
+		ID:       "base",
+		Findings: []Finding{leak, sqli},
+		Results:  []string{"Found  something X", "found something y"},
+	}
+	head := ScanInfos{
+		ID:       "head",
+		Findings: []Finding{moved, xss},
+		Results:  []string{"found something x ", "found something z"},
+	}
+
+	diff := DiffScanInfos(base, head)
+	assert.Equal(t, "base", diff.BaseID)
+	assert.Equal(t, "head", diff.HeadID)
+
+	t.Run("findings are matched by fingerprint regardless of lines", func(t *testing.T) {
+		assert.Len(t, diff.Unchanged, 2)
+		assert.Equal(t, 42, diff.Unchanged[0].Finding.StartLine)
+		assert.Equal(t, "found something x ", diff.Unchanged[1].Result)
+	})
+
+	t.Run("new findings and results are reported", func(t *testing.T) {
+		assert.Len(t, diff.New, 2)
+		assert.Equal(t, "custom", diff.New[0].Fingerprint)
+		assert.Equal(t, "found something z", diff.New[1].Result)
+	})
+
+	t.Run("fixed findings and results are reported", func(t *testing.T) {
+		assert.Len(t, diff.Fixed, 2)
+		assert.Equal(t, "G201", diff.Fixed[0].Finding.RuleID)
+		assert.Equal(t, "found something y", diff.Fixed[1].Result)
+	})
+
+	t.Run("duplicated findings are matched one to one", func(t *testing.T) {
+		d := DiffScanInfos(ScanInfos{Results: []string{"a", "a"}}, ScanInfos{Results: []string{"a"}})
+		assert.Len(t, d.Unchanged, 1)
+		assert.Len(t, d.Fixed, 1)
+		assert.Empty(t, d.New)
+	})
+}
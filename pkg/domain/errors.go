@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kinds of errors reported by the repositories and the use cases. They are
+// meant to be checked with errors.Is so that callers do not depend on the
+// underlying storage driver errors.
+var (
+	ErrNotFound    = errors.New("resource not found")
+	ErrConflict    = errors.New("resource conflict")
+	ErrValidation  = errors.New("validation failed")
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrPreconditionFailed reports a write made against an outdated version.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnauthenticated reports a caller without valid credentials.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	// ErrForbidden reports a caller not allowed to perform an operation.
+	ErrForbidden = errors.New("forbidden")
+	// ErrTooManyRequests reports a caller which exceeded its rate limit or quota.
+	ErrTooManyRequests = errors.New("too many requests")
+)
+
+// Error classifies an error under one of the domain error kinds while
+// keeping the original error message and chain.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+// NewError wraps err into an error of the given kind. It returns nil when err is nil.
+func NewError(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap gives access to the original error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error belongs to the target kind.
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+// FieldError describes why the value of a field is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists the invalid fields of a scan infos. It is reported
+// as an error of the ErrValidation kind.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "invalid fields: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is the ErrValidation kind.
+func (e ValidationErrors) Is(target error) bool {
+	return target == ErrValidation
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Severity levels a finding can be reported with.
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Finding is a single issue reported by a scanner on a repository.
+type Finding struct {
+	RuleID    string `db:"rule_id" json:"rule_id" bson:"rule_id" binding:"required"`
+	Severity  string `db:"severity" json:"severity" bson:"severity" binding:"required,oneof=info low medium high critical"`
+	Title     string `db:"title" json:"title" bson:"title" binding:"required"`
+	FilePath  string `db:"file_path" json:"file_path" bson:"file_path"`
+	StartLine int    `db:"start_line" json:"start_line" bson:"start_line" binding:"gte=0"`
+	EndLine   int    `db:"end_line" json:"end_line" bson:"end_line" binding:"gte=0"`
+	// Fingerprint identifies the same finding across scans. It is computed
+	// from the rule, the file and the title when not provided by the scanner.
+	Fingerprint string                 `db:"fingerprint" json:"fingerprint" bson:"fingerprint"`
+	Scanner     string                 `db:"scanner" json:"scanner" bson:"scanner"`
+	Properties  map[string]interface{} `db:"properties" json:"properties" bson:"properties"`
+}
+
+// ComputeFingerprint returns a stable hash of the finding. Line numbers are
+// left out on purpose so that a finding keeps its fingerprint when the code
+// around it moves.
+func (f Finding) ComputeFingerprint() string {
+	h := sha256.New()
+	for _, part := range []string{f.Scanner, f.RuleID, f.FilePath, strings.TrimSpace(f.Title)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FillFingerprints computes the missing fingerprints of the findings in place.
+func FillFingerprints(findings []Finding) {
+	for i := range findings {
+		if findings[i].Fingerprint == "" {
+			findings[i].Fingerprint = findings[i].ComputeFingerprint()
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord remembers the outcome of a request sent with an
+// idempotency key so that its retries are answered without being replayed.
+type IdempotencyRecord struct {
+	Key string `db:"key" json:"key" bson:"_id"`
+	// RequestHash identifies the request originally sent with the key.
+	RequestHash string `db:"request_hash" json:"request_hash" bson:"request_hash"`
+	// StatusCode and Response are zero until the original request completes.
+	StatusCode int       `db:"status_code" json:"status_code" bson:"status_code"`
+	Response   []byte    `db:"response" json:"response" bson:"response"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at" bson:"created_at"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expires_at" bson:"expires_at"`
+	// LockedUntil ends the lease of the request processing the key. A retry
+	// of the same request may reserve the key again once it ended without a
+	// response, for example after the instance processing it crashed.
+	LockedUntil time.Time `db:"locked_until" json:"locked_until" bson:"locked_until"`
+}
+
+// Completed reports whether the response of the original request is known.
+func (r IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+// IdempotencyRepository stores the idempotency keys shared by all instances
+// of the service.
+type IdempotencyRepository interface {
+	// Reserve stores the record unless an unexpired record exists with the
+	// same key. It returns the stored record and whether it was reserved by
+	// this call. Expired records are replaced, as well as the records of the
+	// same request whose lease ended before they were completed.
+	Reserve(ctx context.Context, record IdempotencyRecord) (IdempotencyRecord, bool, error)
+	// Complete saves the response of the request which reserved the key.
+	Complete(ctx context.Context, key string, statusCode int, response []byte) error
+	// Release removes a reserved key so that the request can be retried.
+	Release(ctx context.Context, key string) error
+}
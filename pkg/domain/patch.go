@@ -0,0 +1,14 @@
+package domain
+
+// Media types of the documents accepted to partially update a scan infos.
+const (
+	// MergePatchType is a JSON Merge Patch document (RFC 7396).
+	MergePatchType = "application/merge-patch+json"
+	// JSONPatchType is a JSON Patch document (RFC 6902).
+	JSONPatchType = "application/json-patch+json"
+)
+
+// IsValidPatchType reports whether mediaType is a supported patch document type.
+func IsValidPatchType(mediaType string) bool {
+	return mediaType == MergePatchType || mediaType == JSONPatchType
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// QuotaDayLayout formats the UTC days quota usages are counted for.
+const QuotaDayLayout = "2006-01-02"
+
+// QuotaUsage counts the scans submitted by a company during a UTC day.
+type QuotaUsage struct {
+	CompanyID string `db:"company_id" json:"company_id" bson:"company_id"`
+	Day       string `db:"day" json:"day" bson:"day"`
+	Count     int64  `db:"count" json:"count" bson:"count"`
+	// Limit is the daily quota of the company. It is not stored.
+	Limit int64 `db:"-" json:"limit" bson:"-"`
+}
+
+// QuotaRepository stores the quota usages shared by all instances of the service.
+type QuotaRepository interface {
+	// Consume adds n to the usage of the company for the day unless it would
+	// exceed limit. A limit lower than 1 means no limit. It returns the usage
+	// after the call and whether n was added.
+	Consume(ctx context.Context, companyID, day string, n, limit int64) (int64, bool, error)
+	// Refund removes n from the usage of the company for the day. The usage
+	// does not go below zero.
+	Refund(ctx context.Context, companyID, day string, n int64) error
+	// List returns the usages of all the companies for the day.
+	List(ctx context.Context, day string) ([]QuotaUsage, error)
+}
+
+// QuotaExceededError reports a company which submitted all the scans its
+// daily quota allows. It is an error of the ErrTooManyRequests kind.
+type QuotaExceededError struct {
+	CompanyID string
+	Limit     int64
+	ResetAt   time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("daily quota of %d scans exceeded for company %q until %s", e.Limit, e.CompanyID, e.ResetAt.Format(time.RFC3339))
+}
+
+// Is reports whether target is the ErrTooManyRequests kind.
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
@@ -3,10 +3,24 @@ package domain
 import "context"
 
 // Repository ...
+//
+// Stored scan infos carry a version incremented on every update. UpdateByID
+// only applies when the version of the given scan infos matches the stored
+// one and DeleteByID only applies when the given version matches the stored
+// one. Otherwise they fail with ErrPreconditionFailed. A zero version skips
+// the check.
 type ScanInfosRepository interface {
 	Save(ctx context.Context, scanInfos ScanInfos) (string, error)
+	// SaveBatch stores all the scan infos or none of them and returns their
+	// IDs in the same order.
+	SaveBatch(ctx context.Context, scanInfos []ScanInfos) ([]string, error)
 	FindByID(ctx context.Context, id string) (ScanInfos, error)
 	UpdateByID(ctx context.Context, id string, scanInfos ScanInfos) error
-	DeleteByID(ctx context.Context, id string) error
-	FindAll(ctx context.Context) ([]ScanInfos, error)
+	DeleteByID(ctx context.Context, id string, version int64) error
+	List(ctx context.Context, query ScanInfosQuery) (ScanInfosPage, error)
+	// Import stores the scan infos as they are, keeping their IDs, versions
+	// and timestamps. Those whose ID is already stored are skipped so that an
+	// interrupted import can be replayed. It returns the number of stored
+	// scan infos.
+	Import(ctx context.Context, scanInfos []ScanInfos) (int, error)
 }
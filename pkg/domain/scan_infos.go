@@ -15,6 +15,9 @@ type ScanInfos struct {
 	TagID         string `db:"tag_id" json:"tag_id" bson:"tag_id" binding:"required"`
 
 	Results []string `db:"results" json:"results" bson:"results" binding:"required"`
+	// Findings are the structured results of the scan. They are stored apart
+	// from the scan record and left untouched on updates when nil.
+	Findings []Finding `db:"-" json:"findings,omitempty" bson:"findings"`
 
 	StartedAt   int64     `db:"started_at" json:"started_at" bson:"started_at" binding:"required"`
 	CompletedAt int64     `db:"completed_at" json:"completed_at" bson:"completed_at" binding:"required"`
@@ -24,6 +27,9 @@ type ScanInfos struct {
 
 	Error    string                 `db:"error" json:"error" bson:"error"`
 	Metadata map[string]interface{} `db:"metadata" json:"metadata" bson:"metadata" binding:"required"`
+
+	// Version is incremented on every update and used for optimistic locking.
+	Version int64 `db:"version" json:"version" bson:"version"`
 }
 
 type StoreScanInfosRequest struct {
@@ -65,3 +71,52 @@ func (r StoreScanInfosRequest) ToScanInfos() (s ScanInfos) {
 		Metadata:      r.Metadata,
 	}
 }
+
+// StoreScanInfosV2Request holds a scan submission carrying structured findings.
+// The legacy free-form results remain accepted but are optional.
+type StoreScanInfosV2Request struct {
+	CompanyID string `json:"company_id" binding:"required"`
+	Username  string `json:"username" binding:"required"`
+
+	ClientID string `json:"client_id" binding:"required"`
+
+	RepositoryURL string `json:"repository_url" binding:"required"`
+	CommitID      string `json:"commit_id" binding:"required"`
+	TagID         string `json:"tag_id" binding:"required"`
+
+	Results  []string  `json:"results"`
+	Findings []Finding `json:"findings" binding:"required,dive"`
+
+	StartedAt   int64 `json:"started_at" binding:"required"`
+	CompletedAt int64 `json:"completed_at" binding:"required"`
+	SentAt      int64 `json:"sent_at" binding:"required"`
+
+	Error    string                 `json:"error"`
+	Metadata map[string]interface{} `json:"metadata" binding:"required"`
+}
+
+func (r StoreScanInfosV2Request) ToScanInfos() ScanInfos {
+	s := StoreScanInfosRequest{
+		CompanyID:     r.CompanyID,
+		Username:      r.Username,
+		ClientID:      r.ClientID,
+		RepositoryURL: r.RepositoryURL,
+		CommitID:      r.CommitID,
+		TagID:         r.TagID,
+		Results:       r.Results,
+		StartedAt:     r.StartedAt,
+		CompletedAt:   r.CompletedAt,
+		SentAt:        r.SentAt,
+		Error:         r.Error,
+		Metadata:      r.Metadata,
+	}.ToScanInfos()
+
+	if s.Results == nil {
+		s.Results = []string{}
+	}
+
+	s.Findings = make([]Finding, len(r.Findings))
+	copy(s.Findings, r.Findings)
+	FillFingerprints(s.Findings)
+	return s
+}
@@ -0,0 +1,207 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Fields which can be used to sort a listing of scan infos.
+const (
+	SortByCreatedAt   = "created_at"
+	SortByUpdatedAt   = "updated_at"
+	SortByStartedAt   = "started_at"
+	SortByCompletedAt = "completed_at"
+	SortBySentAt      = "sent_at"
+)
+
+const (
+	// DefaultListLimit is the page size used when none is requested.
+	DefaultListLimit = 50
+	// MaxListLimit is the biggest page size a caller can request.
+	MaxListLimit = 500
+)
+
+// ScanInfosFilter holds the criteria a scan infos must match to be listed.
+// Empty string fields and zero time bounds are ignored. Time bounds are unix
+// epoch seconds and are inclusive.
+type ScanInfosFilter struct {
+	CompanyID     string
+	ClientID      string
+	RepositoryURL string
+	CommitID      string
+	TagID         string
+
+	StartedFrom   int64
+	StartedTo     int64
+	CompletedFrom int64
+	CompletedTo   int64
+
+	// HasError keeps only failed scans when true and only successful
+	// scans when false. A nil value disables this criteria.
+	HasError *bool
+}
+
+// Matches tells whether the scan infos satisfies all criteria of the filter.
+func (f ScanInfosFilter) Matches(s ScanInfos) bool {
+	switch {
+	case f.CompanyID != "" && s.CompanyID != f.CompanyID,
+		f.ClientID != "" && s.ClientID != f.ClientID,
+		f.RepositoryURL != "" && s.RepositoryURL != f.RepositoryURL,
+		f.CommitID != "" && s.CommitID != f.CommitID,
+		f.TagID != "" && s.TagID != f.TagID,
+		f.StartedFrom != 0 && s.StartedAt < f.StartedFrom,
+		f.StartedTo != 0 && s.StartedAt > f.StartedTo,
+		f.CompletedFrom != 0 && s.CompletedAt < f.CompletedFrom,
+		f.CompletedTo != 0 && s.CompletedAt > f.CompletedTo,
+		f.HasError != nil && *f.HasError != (s.Error != ""):
+		return false
+	}
+	return true
+}
+
+// ScanInfosQuery describes a page of scan infos to fetch from a repository.
+type ScanInfosQuery struct {
+	Filter     ScanInfosFilter
+	SortBy     string
+	Descending bool
+	Limit      int
+	// Cursor is the opaque value returned as next cursor by the previous page.
+	Cursor string
+	// SkipTotal spares counting the scan infos matching the filter on every
+	// page when walking through all of them. Total is then left to zero.
+	SkipTotal bool
+}
+
+// ScanInfosPage is a single page of scan infos matching a query.
+type ScanInfosPage struct {
+	Infos      []ScanInfos
+	NextCursor string
+	// Total is the number of scan infos matching the filter across all pages.
+	Total int64
+}
+
+// ScanInfosCursor is the decoded content of an opaque listing cursor. It
+// holds the sort key of the last item returned so that the next page starts
+// right after it (keyset pagination).
+type ScanInfosCursor struct {
+	SortBy     string `json:"s"`
+	Descending bool   `json:"d"`
+	Value      int64  `json:"v"`
+	ID         string `json:"i"`
+}
+
+// IsValidSortField tells whether field can be used to sort scan infos.
+func IsValidSortField(field string) bool {
+	switch field {
+	case SortByCreatedAt, SortByUpdatedAt, SortByStartedAt, SortByCompletedAt, SortBySentAt:
+		return true
+	}
+	return false
+}
+
+// IsTimeSortField tells whether the sort field is stored as a timestamp
+// instead of unix epoch seconds.
+func IsTimeSortField(field string) bool {
+	return field == SortByCreatedAt || field == SortByUpdatedAt
+}
+
+// Normalize applies the default values to the query and ensures it is valid.
+func (q *ScanInfosQuery) Normalize() error {
+	if q.SortBy == "" {
+		q.SortBy = SortByCreatedAt
+		q.Descending = true
+	}
+
+	if !IsValidSortField(q.SortBy) {
+		return NewError(ErrValidation, fmt.Errorf("invalid sort field %q", q.SortBy))
+	}
+
+	if q.Limit == 0 {
+		q.Limit = DefaultListLimit
+	}
+
+	if q.Limit < 0 || q.Limit > MaxListLimit {
+		return NewError(ErrValidation, fmt.Errorf("invalid limit %d. must be between 1 and %d", q.Limit, MaxListLimit))
+	}
+
+	if _, err := q.DecodeCursor(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DecodeCursor returns the decoded cursor of the query or nil when the query
+// targets the first page.
+func (q ScanInfosQuery) DecodeCursor() (*ScanInfosCursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, NewError(ErrValidation, fmt.Errorf("invalid cursor: %v", err))
+	}
+
+	var c ScanInfosCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, NewError(ErrValidation, fmt.Errorf("invalid cursor: %v", err))
+	}
+
+	if c.ID == "" || c.SortBy != q.SortBy || c.Descending != q.Descending {
+		return nil, NewError(ErrValidation, errors.New("invalid cursor: it does not match the requested sort order"))
+	}
+
+	return &c, nil
+}
+
+// NextCursor builds the opaque cursor pointing right after the given item.
+func (q ScanInfosQuery) NextCursor(last ScanInfos) string {
+	data, _ := json.Marshal(ScanInfosCursor{
+		SortBy:     q.SortBy,
+		Descending: q.Descending,
+		Value:      SortValue(last, q.SortBy),
+		ID:         last.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// SortValue returns the value of the sort field of a scan infos. Timestamps
+// are returned as unix epoch nanoseconds.
+func SortValue(s ScanInfos, field string) int64 {
+	switch field {
+	case SortByCreatedAt:
+		return s.CreatedAt.UnixNano()
+	case SortByUpdatedAt:
+		return s.UpdatedAt.UnixNano()
+	case SortByStartedAt:
+		return s.StartedAt
+	case SortByCompletedAt:
+		return s.CompletedAt
+	case SortBySentAt:
+		return s.SentAt
+	}
+	return 0
+}
+
+// ColumnValue returns the cursor sort value as expected by the storage
+// layer: a UTC time for timestamp fields and unix epoch seconds otherwise.
+func (c ScanInfosCursor) ColumnValue() interface{} {
+	if IsTimeSortField(c.SortBy) {
+		return time.Unix(0, c.Value).UTC()
+	}
+	return c.Value
+}
+
+// ParseSort converts a sort expression like "started_at" or "-started_at"
+// into a sort field and a descending flag.
+func ParseSort(expr string) (string, bool) {
+	if strings.HasPrefix(expr, "-") {
+		return strings.TrimPrefix(expr, "-"), true
+	}
+	return strings.TrimPrefix(expr, "+"), false
+}
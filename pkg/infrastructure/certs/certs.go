@@ -0,0 +1,232 @@
+// Package certs provides the certificate of the https server. It reloads the
+// certificate files when they change and can generate a self-signed
+// certificate for local development.
+package certs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Reloader serves the certificate loaded from a pair of certificate and key
+// files. It is safe for concurrent use.
+type Reloader struct {
+	logger   *zap.Logger
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+
+	watcher *fsnotify.Watcher
+}
+
+// NewReloader loads the certificate and key files.
+func NewReloader(logger *zap.Logger, certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{logger: logger, certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the certificate and key files again. The certificate in use is
+// kept when they cannot be loaded, for example while only one of them has
+// been replaced yet.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "cannot load server certificate")
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate. It is meant for the
+// GetCertificate field of a tls.Config.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reloadDelay groups the events of a rotation, which usually replaces
+// several files, into a single reload.
+const reloadDelay = 100 * time.Millisecond
+
+// Watch reloads the certificate whenever its files change until Close is
+// called. The parent directories are watched and any change into them
+// triggers a reload, so that files replaced by a rename or through symbolic
+// links, like the secrets mounted by Kubernetes, are noticed.
+func (r *Reloader) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "cannot watch server certificate files")
+	}
+
+	dirs := map[string]bool{}
+	for _, file := range []string{r.certFile, r.keyFile} {
+		dir := filepath.Dir(filepath.Clean(file))
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return errors.Wrapf(err, "cannot watch server certificate file %s", file)
+		}
+	}
+
+	r.watcher = watcher
+	go func() {
+		var pending <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+					pending = time.After(reloadDelay)
+				}
+
+			case <-pending:
+				pending = nil
+				r.reloadIfChanged()
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				r.logger.Error("server certificate files watcher failure", zap.Error(err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reloadIfChanged reloads the certificate after a change into the watched
+// directories, which may not concern the certificate files.
+func (r *Reloader) reloadIfChanged() {
+	previous, _ := r.GetCertificate(nil)
+	if err := r.Reload(); err != nil {
+		r.logger.Warn("failed to reload server certificate. keeping the previous one", zap.Error(err))
+		return
+	}
+
+	current, _ := r.GetCertificate(nil)
+	if !bytes.Equal(previous.Certificate[0], current.Certificate[0]) {
+		r.logger.Info("server certificate reloaded", zap.String("file", r.certFile))
+	}
+}
+
+// Close stops watching the certificate files.
+func (r *Reloader) Close() error {
+	if r.watcher == nil {
+		return nil
+	}
+	return r.watcher.Close()
+}
+
+// SelfSigned generates a self-signed certificate valid for a year for the
+// hosts, which are either ip addresses or dns names. It is only meant for
+// local development.
+func SelfSigned(hosts ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "cannot generate self-signed certificate key")
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "cannot generate self-signed certificate serial number")
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "localhost", Organization: []string{"backend-api development"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	for _, host := range append([]string{"localhost", "127.0.0.1", "::1"}, hosts...) {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "cannot generate self-signed certificate")
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "cannot parse self-signed certificate")
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// ParseVersion converts a TLS version such as 1.2 into its identifier.
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown tls version %q. expect 1.0, 1.1, 1.2 or 1.3", version)
+}
+
+// ParseCipherSuites converts cipher suite names such as
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 into their identifiers. Insecure
+// cipher suites are refused. An empty list keeps the Go defaults.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, found := known[name]
+		if !found {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,121 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func writeCertificate(t *testing.T, certFile, keyFile string) tls.Certificate {
+	cert, err := SelfSigned("scans.example.com")
+	assert.NoError(t, err)
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0o600))
+	assert.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0o600))
+	return cert
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	first := writeCertificate(t, certFile, keyFile)
+
+	r, err := NewReloader(zap.NewNop(), certFile, keyFile)
+	assert.NoError(t, err)
+	assert.NoError(t, r.Watch())
+	defer r.Close()
+
+	served, err := r.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Certificate[0], served.Certificate[0])
+
+	second := writeCertificate(t, certFile, keyFile)
+	assert.Eventually(t, func() bool {
+		served, _ := r.GetCertificate(nil)
+		return string(served.Certificate[0]) == string(second.Certificate[0])
+	}, 5*time.Second, 20*time.Millisecond)
+
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("not a key"), 0o600))
+	assert.Error(t, r.Reload())
+	served, _ = r.GetCertificate(nil)
+	assert.Equal(t, second.Certificate[0], served.Certificate[0])
+}
+
+func TestReloaderRotations(t *testing.T) {
+	servedEventually := func(t *testing.T, r *Reloader, cert tls.Certificate) {
+		assert.Eventually(t, func() bool {
+			served, _ := r.GetCertificate(nil)
+			return string(served.Certificate[0]) == string(cert.Certificate[0])
+		}, 5*time.Second, 20*time.Millisecond)
+	}
+
+	t.Run("files replaced by a rename", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+		writeCertificate(t, certFile, keyFile)
+		r, err := NewReloader(zap.NewNop(), certFile, keyFile)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Watch())
+		defer r.Close()
+
+		staging := t.TempDir()
+		second := writeCertificate(t, filepath.Join(staging, "server.crt"), filepath.Join(staging, "server.key"))
+		assert.NoError(t, os.Rename(filepath.Join(staging, "server.key"), keyFile))
+		assert.NoError(t, os.Rename(filepath.Join(staging, "server.crt"), certFile))
+		servedEventually(t, r, second)
+	})
+
+	// Kubernetes mounts the secrets as symbolic links to a ..data link which
+	// is atomically swapped to a new directory on rotation.
+	t.Run("kubernetes secret volume", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, "..v1"), 0o700))
+		writeCertificate(t, filepath.Join(dir, "..v1", "server.crt"), filepath.Join(dir, "..v1", "server.key"))
+		assert.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+		certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+		assert.NoError(t, os.Symlink(filepath.Join("..data", "server.crt"), certFile))
+		assert.NoError(t, os.Symlink(filepath.Join("..data", "server.key"), keyFile))
+
+		r, err := NewReloader(zap.NewNop(), certFile, keyFile)
+		assert.NoError(t, err)
+		assert.NoError(t, r.Watch())
+		defer r.Close()
+
+		assert.NoError(t, os.Mkdir(filepath.Join(dir, "..v2"), 0o700))
+		second := writeCertificate(t, filepath.Join(dir, "..v2", "server.crt"), filepath.Join(dir, "..v2", "server.key"))
+		assert.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+		assert.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+		servedEventually(t, r, second)
+	})
+}
+
+func TestSelfSigned(t *testing.T) {
+	cert, err := SelfSigned("10.0.0.1", "scans.example.com")
+	assert.NoError(t, err)
+	assert.NoError(t, cert.Leaf.VerifyHostname("localhost"))
+	assert.NoError(t, cert.Leaf.VerifyHostname("10.0.0.1"))
+	assert.NoError(t, cert.Leaf.VerifyHostname("scans.example.com"))
+}
+
+func TestParseSettings(t *testing.T) {
+	version, err := ParseVersion("1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), version)
+	_, err = ParseVersion("3.0")
+	assert.Error(t, err)
+
+	suites, err := ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, suites)
+	_, err = ParseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"})
+	assert.Error(t, err)
+}
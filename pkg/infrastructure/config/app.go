@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
@@ -13,6 +16,30 @@ type Config struct {
 		Host      string `mapstructure:"host"`
 		CertsFile string `mapstructure:"certs_file"`
 		KeyFile   string `mapstructure:"key_file"`
+
+		// TLS tunes the https server. The certificate files are reloaded
+		// when they change.
+		TLS struct {
+			// MinVersion is the lowest accepted TLS version: 1.0, 1.1, 1.2 or 1.3.
+			MinVersion string `mapstructure:"min_version"`
+			// CipherSuites restricts the TLS 1.0 to 1.2 cipher suites. Go
+			// defaults apply when empty.
+			CipherSuites []string `mapstructure:"cipher_suites"`
+			// SelfSigned generates an in-memory self-signed certificate when
+			// the certificate files do not exist. Only meant for development.
+			SelfSigned bool `mapstructure:"self_signed"`
+		} `mapstructure:"tls"`
+
+		// MTLS authenticates the clients with certificates issued by the
+		// client CA. They are required on /api/v1 and optional elsewhere so
+		// that browsers without certificates keep working.
+		MTLS struct {
+			Enabled      bool   `mapstructure:"enabled"`
+			ClientCAFile string `mapstructure:"client_ca_file"`
+			// Identity is the certificate field holding the client id: common_name,
+			// dns_san, uri_san or email_san.
+			Identity string `mapstructure:"identity"`
+		} `mapstructure:"mtls"`
 	} `mapstructure:"server"`
 
 	GinDisableReleaseMode bool   `mapstructure:"gin_disable_release_mode"`
@@ -25,8 +52,96 @@ type Config struct {
 		User         string `mapstructure:"user"`
 		Password     string `mapstructure:"password"`
 		DatabaseName string `mapstructure:"database_name"`
+		// AutoMigrate applies the pending migrations when the server starts.
+		// Otherwise they are applied with the migrate command.
+		AutoMigrate    bool   `mapstructure:"auto_migrate"`
+		MigrationsPath string `mapstructure:"migrations_path"`
 	} `mapstructure:"db_postgres"`
 
+	Auth struct {
+		// Disabled turns off the authentication of the api calls. Only meant
+		// for local development since all tenants then share all scans.
+		Disabled bool `mapstructure:"disabled"`
+
+		// JWT configures the bearer tokens issued by an identity provider.
+		// They are accepted when at least one verification key is configured.
+		JWT struct {
+			Issuer   string   `mapstructure:"issuer"`
+			Audience string   `mapstructure:"audience"`
+			JWKSFile string   `mapstructure:"jwks_file"`
+			PEMFiles []string `mapstructure:"pem_files"`
+			// Leeway tolerates clock differences with the token issuer.
+			Leeway        time.Duration `mapstructure:"leeway"`
+			CompanyClaim  string        `mapstructure:"company_claim"`
+			ClientIDClaim string        `mapstructure:"client_id_claim"`
+			RolesClaim    string        `mapstructure:"roles_claim"`
+		} `mapstructure:"jwt"`
+	} `mapstructure:"auth"`
+
+	Idempotency struct {
+		// TTL is how long an idempotency key is remembered.
+		TTL time.Duration `mapstructure:"ttl"`
+		// LockTimeout is how long a request processing a key holds it. A
+		// retry may take the key over afterwards if it has no response.
+		LockTimeout time.Duration `mapstructure:"lock_timeout"`
+	} `mapstructure:"idempotency"`
+
+	Validation struct {
+		// MaxResults bounds the number of results and of findings of a scan.
+		MaxResults int `mapstructure:"max_results"`
+		// MaxMetadataBytes bounds the size of the json encoded metadata of a scan.
+		MaxMetadataBytes int `mapstructure:"max_metadata_bytes"`
+		// MaxClockSkew is how far in the future the sent time of a scan may be.
+		MaxClockSkew time.Duration `mapstructure:"max_clock_skew"`
+	} `mapstructure:"validation"`
+
+	RateLimit struct {
+		// Enabled limits the rate of the api calls with a token bucket per key.
+		Enabled bool `mapstructure:"enabled"`
+		// Key selects what the buckets are kept for: api_key, ip or company.
+		// Callers without credentials are limited by ip.
+		Key               string  `mapstructure:"key"`
+		RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+		Burst             int     `mapstructure:"burst"`
+	} `mapstructure:"rate_limit"`
+
+	Quota struct {
+		// DailySubmissions bounds the number of scans a company can submit
+		// per UTC day. Zero means no limit.
+		DailySubmissions int64 `mapstructure:"daily_submissions"`
+		// Companies overrides the daily submissions of some companies.
+		Companies []CompanyQuota `mapstructure:"companies"`
+	} `mapstructure:"quota"`
+
+	Tracing struct {
+		// Exporter sends the spans to none (default), stdout or otlp.
+		Exporter string `mapstructure:"exporter"`
+		// Endpoint is the host:port of the OTLP/HTTP collector.
+		Endpoint string `mapstructure:"endpoint"`
+		Insecure bool   `mapstructure:"insecure"`
+		// File receives the spans of the stdout exporter when set.
+		File string `mapstructure:"file"`
+		// SampleRatio is the ratio of the traces started by the service
+		// which are recorded.
+		SampleRatio float64 `mapstructure:"sample_ratio"`
+		ServiceName string  `mapstructure:"service_name"`
+	} `mapstructure:"tracing"`
+
+	Shutdown struct {
+		// DrainDelay is how long the service keeps serving once it reports
+		// not ready, so that the load balancers stop sending traffic first.
+		DrainDelay time.Duration `mapstructure:"drain_delay"`
+		// HTTPTimeout bounds the completion of the in-flight requests.
+		HTTPTimeout time.Duration `mapstructure:"http_timeout"`
+		// WorkersTimeout bounds the stop of the background workers such as
+		// the certificate and keys watchers.
+		WorkersTimeout time.Duration `mapstructure:"workers_timeout"`
+		// DatabaseTimeout bounds the closing of the database connections.
+		DatabaseTimeout time.Duration `mapstructure:"database_timeout"`
+		// TracingTimeout bounds the flush of the pending spans.
+		TracingTimeout time.Duration `mapstructure:"tracing_timeout"`
+	} `mapstructure:"shutdown"`
+
 	DBMongoConfig struct {
 		Host         string `mapstructure:"host"`
 		Port         string `mapstructure:"port"`
@@ -36,9 +151,16 @@ type Config struct {
 	} `mapstructure:"db_mongo"`
 }
 
-func InitConfig(cfgFile string) *Config {
-	var config *Config
+// CompanyQuota is the daily submissions quota of a company.
+type CompanyQuota struct {
+	CompanyID        string `mapstructure:"company_id"`
+	DailySubmissions int64  `mapstructure:"daily_submissions"`
+}
 
+// InitConfig loads the config file then the BACKEND_API_* environment
+// overrides described by bindEnvs. Unknown keys are refused so that typos
+// surface instead of being ignored.
+func InitConfig(cfgFile string) (*Config, error) {
 	if cfgFile != "" {
 		// Use config file passed in as argument (From flag)
 		viper.SetConfigFile(cfgFile)
@@ -52,24 +174,80 @@ func InitConfig(cfgFile string) *Config {
 	viper.SetDefault("server.host", "127.0.0.1")
 	viper.SetDefault("server.port", "8080")
 	viper.SetDefault("database", "postgres")
+	viper.SetDefault("db_postgres.migrations_path", "pkg/infrastructure/postgres/migrations")
+	viper.SetDefault("server.tls.min_version", "1.2")
+	viper.SetDefault("server.mtls.identity", "common_name")
+	viper.SetDefault("auth.jwt.leeway", "30s")
+	viper.SetDefault("auth.jwt.company_claim", "company")
+	viper.SetDefault("auth.jwt.client_id_claim", "client_id")
+	viper.SetDefault("auth.jwt.roles_claim", "roles")
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("idempotency.lock_timeout", "1m")
+	viper.SetDefault("rate_limit.key", "api_key")
+	viper.SetDefault("rate_limit.requests_per_second", 10)
+	viper.SetDefault("rate_limit.burst", 20)
+	viper.SetDefault("tracing.exporter", "none")
+	viper.SetDefault("tracing.sample_ratio", 1)
+	viper.SetDefault("tracing.service_name", "backend-api")
+	viper.SetDefault("validation.max_results", 1000)
+	viper.SetDefault("validation.max_metadata_bytes", 65536)
+	viper.SetDefault("validation.max_clock_skew", "1h")
+	viper.SetDefault("shutdown.drain_delay", "0s")
+	viper.SetDefault("shutdown.http_timeout", "15s")
+	viper.SetDefault("shutdown.workers_timeout", "5s")
+	viper.SetDefault("shutdown.database_timeout", "5s")
+	viper.SetDefault("shutdown.tracing_timeout", "5s")
+
+	bindEnvs()
 
-	var err error
-	if err = viper.ReadInConfig(); err != nil {
-		panic("Unable to read config: " + err.Error())
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read config: %w", err)
 	}
+	return load()
+}
 
-	err = viper.Unmarshal(&config)
+// load decodes the settings after applying the secret files.
+func load() (*Config, error) {
+	if err := applySecretFiles(); err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := viper.UnmarshalExact(&config); err != nil {
+		return nil, fmt.Errorf("unable to decode config: %w", err)
+	}
+	return &config, nil
+}
+
+// Reload reads the config file and the secret files again and publishes them
+// into live. It is meant for the SIGHUP signal and for Watch. The settings in
+// use are kept when the new ones cannot be read or are not valid.
+func Reload(live *Live) error {
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("unable to read config: %w", err)
+	}
+
+	fresh, err := load()
 	if err != nil {
-		panic("unable to decode into struct, " + err.Error())
+		return err
+	}
+	if err := fresh.Validate(); err != nil {
+		return err
 	}
 
-	viper.WatchConfig()
+	current := live.Load()
+	fresh.GitCommit, fresh.GitTag = current.GitCommit, current.GitTag
+	live.Store(fresh)
+	return nil
+}
+
+// Watch reloads live whenever the config file changes. onError receives the
+// reasons why a change was not applied.
+func Watch(live *Live, onError func(error)) {
 	viper.OnConfigChange(func(e fsnotify.Event) {
-		err = viper.Unmarshal(&config)
-		if err != nil {
-			panic("unable to decode into struct: " + err.Error())
+		if err := Reload(live); err != nil {
+			onError(err)
 		}
 	})
-
-	return config
+	viper.WatchConfig()
 }
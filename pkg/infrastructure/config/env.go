@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// envPrefix prefixes the environment variables overriding the config keys.
+const envPrefix = "BACKEND_API"
+
+// keys lists the config keys which can be overridden by the environment, all
+// the leaves of Config. The lists of objects such as quota.companies are only
+// read from the config file.
+var keys = configKeys(reflect.TypeOf(Config{}), "")
+
+func configKeys(t reflect.Type, prefix string) []string {
+	var list []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		key := prefix + tag
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			list = append(list, configKeys(field.Type, key+".")...)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+		default:
+			list = append(list, key)
+		}
+	}
+	return list
+}
+
+// envName is the environment variable overriding key, for example
+// BACKEND_API_DB_POSTGRES_PASSWORD for db_postgres.password.
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// bindEnvs lets the BACKEND_API_<KEY> environment variables override the
+// config file. The lists take comma separated values.
+func bindEnvs() {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	for _, key := range keys {
+		_ = viper.BindEnv(key)
+	}
+}
+
+// applySecretFiles sets the keys whose BACKEND_API_<KEY>_FILE environment
+// variable names a file, such as a mounted container secret, to the content
+// of that file without its trailing newline.
+func applySecretFiles() error {
+	var problems []string
+	for _, key := range keys {
+		file, ok := os.LookupEnv(envName(key) + "_FILE")
+		if !ok {
+			continue
+		}
+
+		if _, ok := os.LookupEnv(envName(key)); ok {
+			problems = append(problems, fmt.Sprintf("both %s and %s_FILE are set", envName(key), envName(key)))
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("cannot read %s_FILE: %v", envName(key), err))
+			continue
+		}
+		viper.Set(key, strings.TrimRight(string(content), "\r\n"))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a config file with the given content into a temporary
+// directory and resets the settings loaded by the previous tests.
+func writeConfig(t *testing.T, content string) string {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	file := filepath.Join(t.TempDir(), "server.config.yml")
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func TestInitConfig(t *testing.T) {
+	t.Run("should pass: environment overrides the config file", func(t *testing.T) {
+		file := writeConfig(t, "database: postgres\ndb_postgres:\n  host: localhost\n  password: secret\n")
+		t.Setenv("BACKEND_API_DB_POSTGRES_HOST", "postgres")
+		t.Setenv("BACKEND_API_SERVER_PORT", "8443")
+		t.Setenv("BACKEND_API_SERVER_TLS_CIPHER_SUITES", "TLS_AES_128_GCM_SHA256,TLS_AES_256_GCM_SHA384")
+		t.Setenv("BACKEND_API_RATE_LIMIT_ENABLED", "true")
+
+		c, err := InitConfig(file)
+		require.NoError(t, err)
+		assert.Equal(t, "postgres", c.DBPostgresConfig.Host)
+		assert.Equal(t, "secret", c.DBPostgresConfig.Password)
+		assert.Equal(t, "8443", c.Server.Port)
+		assert.Equal(t, []string{"TLS_AES_128_GCM_SHA256", "TLS_AES_256_GCM_SHA384"}, c.Server.TLS.CipherSuites)
+		assert.True(t, c.RateLimit.Enabled)
+	})
+
+	t.Run("should pass: secret read from file", func(t *testing.T) {
+		file := writeConfig(t, "database: memory\nserver:\n  tls:\n    self_signed: true\n")
+		secret := filepath.Join(t.TempDir(), "postgres.password")
+		require.NoError(t, os.WriteFile(secret, []byte("s3cr3t\n"), 0o600))
+		t.Setenv("BACKEND_API_DB_POSTGRES_PASSWORD_FILE", secret)
+
+		c, err := InitConfig(file)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", c.DBPostgresConfig.Password)
+
+		require.NoError(t, os.WriteFile(secret, []byte("rotated"), 0o600))
+		live := NewLive(c)
+		require.NoError(t, Reload(live))
+		assert.Equal(t, "rotated", live.Load().DBPostgresConfig.Password)
+		assert.Equal(t, "s3cr3t", c.DBPostgresConfig.Password)
+	})
+
+	t.Run("should fail: secret file and value both set", func(t *testing.T) {
+		file := writeConfig(t, "database: postgres\n")
+		t.Setenv("BACKEND_API_DB_MONGO_PASSWORD", "secret")
+		t.Setenv("BACKEND_API_DB_MONGO_PASSWORD_FILE", "/run/secrets/mongo")
+		t.Setenv("BACKEND_API_DB_POSTGRES_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		_, err := InitConfig(file)
+		var verr *ValidationError
+		require.ErrorAs(t, err, &verr)
+		assert.Len(t, verr.Problems, 2)
+		assert.Contains(t, err.Error(), "both BACKEND_API_DB_MONGO_PASSWORD and BACKEND_API_DB_MONGO_PASSWORD_FILE are set")
+		assert.Contains(t, err.Error(), "cannot read BACKEND_API_DB_POSTGRES_PASSWORD_FILE")
+	})
+
+	t.Run("should pass: explicit empty password is only a warning", func(t *testing.T) {
+		const settings = "database: postgres\nserver:\n  tls:\n    self_signed: true\ndb_postgres:\n  port: \"5432\"\n"
+		file := writeConfig(t, settings+"  password: \"\"\n")
+
+		c, err := InitConfig(file)
+		require.NoError(t, err)
+		assert.NoError(t, c.Validate())
+		assert.Equal(t, []string{"empty postgres password. " +
+			"set db_postgres.password, BACKEND_API_DB_POSTGRES_PASSWORD or BACKEND_API_DB_POSTGRES_PASSWORD_FILE"}, c.Warnings())
+
+		file = writeConfig(t, settings)
+		c, err = InitConfig(file)
+		require.NoError(t, err)
+		assert.ErrorContains(t, c.Validate(), "missing postgres password")
+
+		secret := filepath.Join(t.TempDir(), "postgres.password")
+		require.NoError(t, os.WriteFile(secret, []byte("s3cr3t\n"), 0o600))
+		t.Setenv("BACKEND_API_DB_POSTGRES_PASSWORD_FILE", secret)
+		c, err = InitConfig(file)
+		require.NoError(t, err)
+		assert.NoError(t, c.Validate())
+		assert.Empty(t, c.Warnings())
+	})
+
+	t.Run("should fail: unknown keys", func(t *testing.T) {
+		file := writeConfig(t, "database: postgres\nserver:\n  prot: \"8080\"\n")
+
+		_, err := InitConfig(file)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "'server' has invalid keys: prot")
+	})
+}
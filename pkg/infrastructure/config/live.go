@@ -0,0 +1,27 @@
+package config
+
+import "sync/atomic"
+
+// Live publishes the settings of a running server. Reload replaces them as a
+// whole so that the concurrent readers always get a complete snapshot. The
+// snapshots are shared and must not be modified.
+type Live struct {
+	current atomic.Value
+}
+
+// NewLive publishes config as the current settings.
+func NewLive(config *Config) *Live {
+	l := &Live{}
+	l.current.Store(config)
+	return l
+}
+
+// Load returns the current settings.
+func (l *Live) Load() *Config {
+	return l.current.Load().(*Config)
+}
+
+// Store publishes config as the current settings.
+func (l *Live) Store(config *Config) {
+	l.current.Store(config)
+}
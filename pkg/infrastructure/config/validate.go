@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jeamon/backend-api/pkg/infrastructure/certs"
+	"github.com/spf13/viper"
+)
+
+// secretMask replaces the secrets into the printed settings.
+const secretMask = "********"
+
+// ValidationError lists all the problems found into a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the values which are not checked by the components using
+// them at startup. It reports all the problems at once into a ValidationError.
+func (c *Config) Validate() error {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	checkPort := func(name, port string) {
+		if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+			report("invalid %s port %q. expect a number between 1 and 65535", name, port)
+		}
+	}
+	checkFile := func(name, file string) {
+		if file == "" {
+			report("missing %s", name)
+			return
+		}
+		if _, err := os.Stat(file); err != nil {
+			report("cannot access %s: %v", name, err)
+		}
+	}
+
+	switch c.Database {
+	case "postgres":
+		checkPort("postgres", c.DBPostgresConfig.Port)
+		if c.DBPostgresConfig.Password == "" && !viper.IsSet("db_postgres.password") {
+			report("missing postgres password. %s", passwordHint("db_postgres.password"))
+		}
+	case "mongo":
+		checkPort("mongo", c.DBMongoConfig.Port)
+		if c.DBMongoConfig.Password == "" && !viper.IsSet("db_mongo.password") {
+			report("missing mongo password. %s", passwordHint("db_mongo.password"))
+		}
+	case "mockdb", "memory":
+	default:
+		report("unsupported database %q. expect postgres, mongo, mockdb or memory", c.Database)
+	}
+
+	checkPort("server", c.Server.Port)
+
+	// the self-signed certificate replaces the missing certificate files.
+	if !c.Server.TLS.SelfSigned {
+		checkFile("server certificate file", c.Server.CertsFile)
+		checkFile("server key file", c.Server.KeyFile)
+	}
+
+	if _, err := certs.ParseVersion(c.Server.TLS.MinVersion); err != nil {
+		report("%v", err)
+	}
+	if _, err := certs.ParseCipherSuites(c.Server.TLS.CipherSuites); err != nil {
+		report("%v", err)
+	}
+
+	if c.Server.MTLS.Enabled {
+		checkFile("mtls client ca file", c.Server.MTLS.ClientCAFile)
+		switch c.Server.MTLS.Identity {
+		case "common_name", "dns_san", "uri_san", "email_san":
+		default:
+			report("invalid mtls identity %q. expect common_name, dns_san, uri_san or email_san", c.Server.MTLS.Identity)
+		}
+	}
+
+	if c.Auth.JWT.JWKSFile != "" {
+		checkFile("jwks file", c.Auth.JWT.JWKSFile)
+	}
+	for _, file := range c.Auth.JWT.PEMFiles {
+		checkFile("jwt pem file", file)
+	}
+	// the tokens of other issuers or audiences signed by the same keys are refused.
+	if c.Auth.JWT.JWKSFile != "" || len(c.Auth.JWT.PEMFiles) > 0 {
+		if c.Auth.JWT.Issuer == "" {
+			report("missing jwt issuer. expect the issuer of the accepted tokens once jwt keys are set")
+		}
+		if c.Auth.JWT.Audience == "" {
+			report("missing jwt audience. expect the audience of the accepted tokens once jwt keys are set")
+		}
+	}
+
+	if c.RateLimit.Enabled {
+		switch c.RateLimit.Key {
+		case "api_key", "ip", "company":
+		default:
+			report("invalid rate limit key %q. expect api_key, ip or company", c.RateLimit.Key)
+		}
+
+		if c.RateLimit.RequestsPerSecond <= 0 || c.RateLimit.Burst <= 0 {
+			report("invalid rate limit. expect positive requests per second and burst")
+		}
+	}
+
+	if c.Quota.DailySubmissions < 0 {
+		report("invalid daily submissions quota %d. expect zero or a positive number", c.Quota.DailySubmissions)
+	}
+	for _, q := range c.Quota.Companies {
+		if q.CompanyID == "" || q.DailySubmissions < 0 {
+			report("invalid quota of company %q. expect a company id and zero or positive daily submissions", q.CompanyID)
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "stdout", "otlp":
+	default:
+		report("unsupported tracing exporter %q. expect none, stdout or otlp", c.Tracing.Exporter)
+	}
+
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		report("invalid tracing sample ratio %v. expect a number between 0 and 1", c.Tracing.SampleRatio)
+	}
+
+	if c.Validation.MaxResults <= 0 || c.Validation.MaxMetadataBytes <= 0 {
+		report("invalid validation limits. expect positive max results and max metadata bytes")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Warnings lists the settings which are accepted but likely mistaken, such as
+// the password of the selected database set to empty, which only suits a
+// database trusting the connections of the service.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	switch {
+	case c.Database == "postgres" && c.DBPostgresConfig.Password == "":
+		warnings = append(warnings, "empty postgres password. "+passwordHint("db_postgres.password"))
+	case c.Database == "mongo" && c.DBMongoConfig.Password == "":
+		warnings = append(warnings, "empty mongo password. "+passwordHint("db_mongo.password"))
+	}
+	return warnings
+}
+
+// passwordHint tells where the password of key can be set.
+func passwordHint(key string) string {
+	return fmt.Sprintf("set %s, %s or %s_FILE", key, envName(key), envName(key))
+}
+
+// MaskedSettings returns the effective settings, the config file values on
+// top of the defaults, with the secrets masked so that they can be printed.
+func MaskedSettings() map[string]interface{} {
+	settings := viper.AllSettings()
+	maskSecrets(settings)
+	return settings
+}
+
+func maskSecrets(settings map[string]interface{}) {
+	for key, value := range settings {
+		if nested, ok := value.(map[string]interface{}); ok {
+			maskSecrets(nested)
+			continue
+		}
+
+		if isSecret(key) && value != "" {
+			settings[key] = secretMask
+		}
+	}
+}
+
+// isSecret reports whether the setting named key holds a secret.
+func isSecret(key string) bool {
+	return key == "password" || strings.HasSuffix(key, "_password") || strings.Contains(key, "secret") || strings.HasSuffix(key, "_token")
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		c := &Config{Database: "postgres"}
+		c.Server.Port = "8080"
+		c.Server.TLS.SelfSigned = true
+		c.DBPostgresConfig.Port = "5432"
+		c.DBPostgresConfig.Password = "secret"
+		c.Tracing.Exporter = "none"
+		c.Tracing.SampleRatio = 1
+		c.Validation.MaxResults = 1000
+		c.Validation.MaxMetadataBytes = 65536
+		return c
+	}
+
+	assert.NoError(t, valid().Validate())
+
+	c := valid()
+	c.Database = "mysql"
+	assert.EqualError(t, c.Validate(), "invalid config:\n  - unsupported database \"mysql\". expect postgres, mongo, mockdb or memory")
+
+	c = valid()
+	c.DBPostgresConfig.Password = ""
+	assert.EqualError(t, c.Validate(), "invalid config:\n  - missing postgres password. "+
+		"set db_postgres.password, BACKEND_API_DB_POSTGRES_PASSWORD or BACKEND_API_DB_POSTGRES_PASSWORD_FILE")
+
+	c = valid()
+	c.Server.Port = "80800"
+	assert.Error(t, c.Validate())
+
+	c = valid()
+	c.RateLimit.Enabled = true
+	c.RateLimit.Key = "user"
+	c.RateLimit.RequestsPerSecond, c.RateLimit.Burst = 10, 20
+	assert.EqualError(t, c.Validate(), "invalid config:\n  - invalid rate limit key \"user\". expect api_key, ip or company")
+
+	c = valid()
+	c.Tracing.SampleRatio = 2
+	assert.Error(t, c.Validate())
+
+	c = valid()
+	c.Auth.JWT.PEMFiles = []string{filepath.Join(t.TempDir(), "idp.pem")}
+	require.NoError(t, os.WriteFile(c.Auth.JWT.PEMFiles[0], []byte("key"), 0o600))
+	assert.EqualError(t, c.Validate(), "invalid config:\n"+
+		"  - missing jwt issuer. expect the issuer of the accepted tokens once jwt keys are set\n"+
+		"  - missing jwt audience. expect the audience of the accepted tokens once jwt keys are set")
+	c.Auth.JWT.Issuer, c.Auth.JWT.Audience = "https://idp.example.com", "backend-api"
+	assert.NoError(t, c.Validate())
+
+	t.Run("should report all problems", func(t *testing.T) {
+		c := valid()
+		c.Database = "mysql"
+		c.Server.Port = "http"
+		c.Server.TLS.SelfSigned = false
+		c.Server.CertsFile = filepath.Join(t.TempDir(), "server.crt")
+		c.Server.TLS.MinVersion = "1.4"
+
+		var verr *ValidationError
+		require.True(t, errors.As(c.Validate(), &verr))
+		assert.Len(t, verr.Problems, 5)
+		assert.Contains(t, verr.Problems[0], "unsupported database")
+		assert.Contains(t, verr.Problems[1], "invalid server port")
+		assert.Contains(t, verr.Problems[2], "cannot access server certificate file")
+		assert.Equal(t, "missing server key file", verr.Problems[3])
+		assert.Contains(t, verr.Problems[4], "unknown tls version")
+	})
+}
+
+func TestMaskSecrets(t *testing.T) {
+	settings := map[string]interface{}{
+		"database": "postgres",
+		"db_postgres": map[string]interface{}{
+			"user":     "api",
+			"password": "secret",
+		},
+		"db_mongo": map[string]interface{}{
+			"password": "",
+		},
+	}
+
+	maskSecrets(settings)
+	assert.Equal(t, "postgres", settings["database"])
+	assert.Equal(t, map[string]interface{}{"user": "api", "password": secretMask}, settings["db_postgres"])
+	assert.Equal(t, map[string]interface{}{"password": ""}, settings["db_mongo"])
+}
@@ -0,0 +1,193 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout bounds a check registered without timeout.
+const DefaultTimeout = 5 * time.Second
+
+// Status summarizes the outcome of the checks.
+type Status string
+
+const (
+	// StatusOK reports that all checks passed.
+	StatusOK Status = "ok"
+	// StatusDegraded reports that only non-critical checks failed. The
+	// service keeps receiving traffic.
+	StatusDegraded Status = "degraded"
+	// StatusFailing reports that a critical check failed or that the service
+	// is shutting down. The service must not receive traffic anymore.
+	StatusFailing Status = "failing"
+)
+
+// Check probes a dependency of the service.
+type Check struct {
+	Name string
+	// Critical checks make the service not ready when they fail. The others
+	// only degrade it.
+	Critical bool
+	// Timeout bounds the duration of the probe. DefaultTimeout applies when zero.
+	Timeout time.Duration
+	Probe   func(ctx context.Context) error
+}
+
+// CheckResult is the outcome of a check.
+type CheckResult struct {
+	Status   Status `json:"status"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the outcome of all the checks.
+type Report struct {
+	Status       Status                 `json:"status"`
+	ShuttingDown bool                   `json:"shutting_down,omitempty"`
+	Checks       map[string]CheckResult `json:"checks"`
+}
+
+// Registry gathers the checks of the dependencies of the service. It is safe
+// for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+
+	shuttingDown int32
+}
+
+// NewRegistry provides an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Check)}
+}
+
+// Register adds a check. Names must be unique.
+func (r *Registry) Register(c Check) error {
+	if c.Name == "" || c.Probe == nil {
+		return fmt.Errorf("invalid health check. expect a name and a probe")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found := r.checks[c.Name]; found {
+		return fmt.Errorf("health check %q already registered", c.Name)
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	r.checks[c.Name] = c
+	return nil
+}
+
+// Shutdown marks the service as shutting down so that it reports not ready
+// from now on and load balancers stop sending traffic.
+func (r *Registry) Shutdown() {
+	atomic.StoreInt32(&r.shuttingDown, 1)
+}
+
+// ShuttingDown reports whether Shutdown was called.
+func (r *Registry) ShuttingDown() bool {
+	return atomic.LoadInt32(&r.shuttingDown) == 1
+}
+
+// Measure runs all checks concurrently, each within its own timeout.
+func (r *Registry) Measure(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := make([]Check, 0, len(r.checks))
+	for _, c := range r.checks {
+		checks = append(checks, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(checks, func(i, j int) bool { return checks[i].Name < checks[j].Name })
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			results[i] = run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, ShuttingDown: r.ShuttingDown(), Checks: make(map[string]CheckResult, len(checks))}
+	for i, c := range checks {
+		res := results[i]
+		report.Checks[c.Name] = res
+		switch {
+		case res.Status == StatusOK:
+		case c.Critical:
+			report.Status = StatusFailing
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+
+	if report.ShuttingDown {
+		report.Status = StatusFailing
+	}
+	return report
+}
+
+// run probes c within its timeout. A probe which does not return in time is
+// reported as failed without waiting for it.
+func run(ctx context.Context, c Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.Probe(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", c.Timeout)
+	}
+
+	res := CheckResult{Status: StatusOK, Critical: c.Critical, Duration: time.Since(start).String()}
+	if err != nil {
+		res.Status, res.Error = StatusFailing, err.Error()
+	}
+	return res
+}
+
+// LivenessHandler reports that the process is able to serve requests. It
+// does not probe the dependencies so that an outage of one of them does not
+// get the service restarted.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]Status{"status": StatusOK})
+	})
+}
+
+// ReadinessHandler reports whether the service can receive traffic with the
+// details of every check. It answers 503 when a critical check fails or once
+// the service is shutting down.
+func ReadinessHandler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Measure(req.Context())
+		status := http.StatusOK
+		if report.Status == StatusFailing {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
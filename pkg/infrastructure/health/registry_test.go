@@ -0,0 +1,115 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(time.Second) // ignores the cancellation for a while.
+		return nil
+	}
+
+	t.Run("should pass: all checks ok", func(t *testing.T) {
+		r := NewRegistry()
+		assert.NoError(t, r.Register(Check{Name: "db", Critical: true, Probe: ok}))
+		assert.NoError(t, r.Register(Check{Name: "cache", Probe: ok}))
+
+		report := r.Measure(context.Background())
+		assert.Equal(t, StatusOK, report.Status)
+		assert.Len(t, report.Checks, 2)
+		assert.Equal(t, StatusOK, report.Checks["db"].Status)
+		assert.True(t, report.Checks["db"].Critical)
+	})
+
+	t.Run("should pass: non-critical failure degrades", func(t *testing.T) {
+		r := NewRegistry()
+		assert.NoError(t, r.Register(Check{Name: "db", Critical: true, Probe: ok}))
+		assert.NoError(t, r.Register(Check{Name: "cache", Probe: failing}))
+
+		report := r.Measure(context.Background())
+		assert.Equal(t, StatusDegraded, report.Status)
+		assert.Equal(t, "connection refused", report.Checks["cache"].Error)
+	})
+
+	t.Run("should pass: critical failure fails", func(t *testing.T) {
+		r := NewRegistry()
+		assert.NoError(t, r.Register(Check{Name: "db", Critical: true, Probe: failing}))
+		assert.NoError(t, r.Register(Check{Name: "cache", Probe: failing}))
+
+		assert.Equal(t, StatusFailing, r.Measure(context.Background()).Status)
+	})
+
+	t.Run("should pass: slow check times out", func(t *testing.T) {
+		r := NewRegistry()
+		assert.NoError(t, r.Register(Check{Name: "db", Critical: true, Timeout: 20 * time.Millisecond, Probe: slow}))
+
+		start := time.Now()
+		report := r.Measure(context.Background())
+		assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+		assert.Equal(t, StatusFailing, report.Status)
+		assert.Equal(t, "check timed out after 20ms", report.Checks["db"].Error)
+	})
+
+	t.Run("should pass: shutting down fails", func(t *testing.T) {
+		r := NewRegistry()
+		assert.NoError(t, r.Register(Check{Name: "db", Critical: true, Probe: ok}))
+		r.Shutdown()
+
+		report := r.Measure(context.Background())
+		assert.Equal(t, StatusFailing, report.Status)
+		assert.True(t, report.ShuttingDown)
+	})
+
+	t.Run("should fail: invalid or duplicate checks", func(t *testing.T) {
+		r := NewRegistry()
+		assert.NoError(t, r.Register(Check{Name: "db", Probe: ok}))
+		assert.Error(t, r.Register(Check{Name: "db", Probe: ok}))
+		assert.Error(t, r.Register(Check{Name: "cache"}))
+		assert.Error(t, r.Register(Check{Probe: ok}))
+	})
+}
+
+func TestHandlers(t *testing.T) {
+	var dbErr error
+	r := NewRegistry()
+	assert.NoError(t, r.Register(Check{Name: "db", Critical: true, Probe: func(ctx context.Context) error { return dbErr }}))
+
+	readiness := func() (int, Report) {
+		rec := httptest.NewRecorder()
+		ReadinessHandler(r).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var report Report
+		assert.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+		return rec.Code, report
+	}
+
+	code, report := readiness()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+
+	dbErr = errors.New("connection refused")
+	code, report = readiness()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "connection refused", report.Checks["db"].Error)
+
+	dbErr = nil
+	r.Shutdown()
+	code, _ = readiness()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	rec := httptest.NewRecorder()
+	LivenessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
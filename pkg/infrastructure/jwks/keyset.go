@@ -0,0 +1,302 @@
+// Package jwks loads the public keys used to verify the signature of JSON Web
+// Tokens from a JSON Web Key Set file and from PEM files, and reloads them when
+// the files change.
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Config describes where the keys are read from.
+type Config struct {
+	// JWKSFile is a JSON Web Key Set file. Its keys are identified by their kid.
+	JWKSFile string
+	// PEMFiles are public keys or certificates files. Their keys are
+	// identified by the file name without extension.
+	PEMFiles []string
+}
+
+// KeySet holds the public keys currently loaded. It is safe for concurrent use.
+type KeySet struct {
+	logger *zap.Logger
+	config Config
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+
+	watcher *fsnotify.Watcher
+}
+
+// Load reads the keys described by c. It fails when no key can be loaded.
+func Load(logger *zap.Logger, c Config) (*KeySet, error) {
+	ks := &KeySet{logger: logger, config: c}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload reads the key files again. The keys in use are kept when it fails.
+func (ks *KeySet) Reload() error {
+	keys := make(map[string]crypto.PublicKey)
+	if ks.config.JWKSFile != "" {
+		data, err := ioutil.ReadFile(ks.config.JWKSFile)
+		if err != nil {
+			return errors.Wrap(err, "cannot read jwks file")
+		}
+
+		if err := parseJWKS(data, keys); err != nil {
+			return errors.Wrapf(err, "invalid jwks file %s", ks.config.JWKSFile)
+		}
+	}
+
+	for _, file := range ks.config.PEMFiles {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return errors.Wrap(err, "cannot read pem file")
+		}
+
+		key, err := parsePEM(data)
+		if err != nil {
+			return errors.Wrapf(err, "invalid pem file %s", file)
+		}
+		keys[strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))] = key
+	}
+
+	if len(keys) == 0 {
+		return errors.New("no jwt verification key configured")
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// Key returns the key identified by kid. When kid is empty, it returns the
+// only loaded key and fails if there are several of them.
+func (ks *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if kid == "" {
+		if len(ks.keys) == 1 {
+			for _, key := range ks.keys {
+				return key, nil
+			}
+		}
+		return nil, errors.New("token without key id while several keys are configured")
+	}
+
+	key, found := ks.keys[kid]
+	if !found {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// Watch reloads the keys whenever one of the key files changes until Close is
+// called. The parent directories are watched so that files replaced by a
+// rename, as done by most secret managers, are noticed.
+func (ks *KeySet) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.Wrap(err, "cannot watch jwt key files")
+	}
+
+	files := map[string]bool{}
+	for _, file := range append([]string{ks.config.JWKSFile}, ks.config.PEMFiles...) {
+		if file == "" {
+			continue
+		}
+
+		file = filepath.Clean(file)
+		files[file] = true
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			watcher.Close()
+			return errors.Wrapf(err, "cannot watch jwt key file %s", file)
+		}
+	}
+
+	ks.watcher = watcher
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				if !files[filepath.Clean(event.Name)] || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+
+				if err := ks.Reload(); err != nil {
+					ks.logger.Error("failed to reload jwt keys. keeping the previous ones", zap.Error(err))
+					continue
+				}
+				ks.logger.Info("jwt keys reloaded", zap.String("file", event.Name))
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				ks.logger.Error("jwt key files watcher failure", zap.Error(err))
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops watching the key files.
+func (ks *KeySet) Close() error {
+	if ks.watcher == nil {
+		return nil
+	}
+	return ks.watcher.Close()
+}
+
+// jsonWebKey holds the members of a public JSON Web Key (RFC 7517).
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS adds the signature keys of a JSON Web Key Set to keys.
+func parseJWKS(data []byte, keys map[string]crypto.PublicKey) error {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return err
+	}
+
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			return errors.Wrapf(err, "key %d", i)
+		}
+		keys[jwk.Kid] = key
+	}
+	return nil
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid modulus")
+		}
+
+		e, err := decodeBigInt(jwk.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid x coordinate")
+		}
+
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid y coordinate")
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// parsePEM returns the public key of a PEM encoded public key or certificate.
+func parsePEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no pem block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+
+	return nil, fmt.Errorf("unsupported pem block type %q", block.Type)
+}
@@ -0,0 +1,57 @@
+package jwks
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func writePublicKey(t *testing.T, file string) ed25519.PublicKey {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(public)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+	return public
+}
+
+func TestKeySetReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "issuer.pem")
+	first := writePublicKey(t, file)
+
+	ks, err := Load(zap.NewNop(), Config{PEMFiles: []string{file}})
+	assert.NoError(t, err)
+	assert.NoError(t, ks.Watch())
+	defer ks.Close()
+
+	key, err := ks.Key("issuer")
+	assert.NoError(t, err)
+	assert.Equal(t, first, key)
+
+	key, err = ks.Key("")
+	assert.NoError(t, err)
+	assert.Equal(t, first, key)
+
+	_, err = ks.Key("unknown")
+	assert.Error(t, err)
+
+	second := writePublicKey(t, file)
+	assert.Eventually(t, func() bool {
+		key, err := ks.Key("issuer")
+		return err == nil && second.Equal(key)
+	}, 5*time.Second, 20*time.Millisecond)
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("not a key"), 0o600))
+	assert.Error(t, ks.Reload())
+	key, err = ks.Key("issuer")
+	assert.NoError(t, err)
+	assert.Equal(t, second, key)
+}
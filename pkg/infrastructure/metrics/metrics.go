@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes the name of every metric of the service.
+const namespace = "backend_api"
+
+// Metrics holds the collectors of the service and the registry exposing them
+// in the Prometheus text format.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	repositoryDuration *prometheus.HistogramVec
+	repositoryErrors   *prometheus.CounterVec
+
+	scansIngested  *prometheus.CounterVec
+	scansWithError *prometheus.CounterVec
+	scanResults    prometheus.Histogram
+}
+
+// New provides a Metrics with all collectors registered, including the go
+// runtime and process ones.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests handled by route, method and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		repositoryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_call_duration_seconds",
+			Help:      "Latency of the scan infos repository calls by backend and operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"backend", "operation"}),
+		repositoryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_errors_total",
+			Help:      "Number of failed scan infos repository calls by backend, operation and error kind.",
+		}, []string{"backend", "operation", "kind"}),
+		scansIngested: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "scans_ingested_total",
+			Help:      "Number of scans stored by company.",
+		}, []string{"company"}),
+		scansWithError: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "scans_with_error_total",
+			Help:      "Number of scans stored with a non-empty error by company.",
+		}, []string{"company"}),
+		scanResults: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "scan_results",
+			Help:      "Number of results per stored scan.",
+			Buckets:   []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000},
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.repositoryDuration,
+		m.repositoryErrors,
+		m.scansIngested,
+		m.scansWithError,
+		m.scanResults,
+	)
+	return m
+}
+
+// Register adds a collector to the exposed ones.
+func (m *Metrics) Register(c prometheus.Collector) error {
+	return m.registry.Register(c)
+}
+
+// Handler serves the collected metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a handled HTTP request. The route is the path
+// template of the request (e.g. /api/v1/scaninfos/:id) to keep the number
+// of series bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// ObserveRepositoryCall records a call to a scan infos repository. The kind
+// of a failed call is empty when it succeeded.
+func (m *Metrics) ObserveRepositoryCall(backend, operation string, elapsed time.Duration, kind string) {
+	m.repositoryDuration.WithLabelValues(backend, operation).Observe(elapsed.Seconds())
+	if kind != "" {
+		m.repositoryErrors.WithLabelValues(backend, operation, kind).Inc()
+	}
+}
+
+// ObserveScanIngested records a stored scan of a company with the number of
+// its results and whether it reported an error.
+func (m *Metrics) ObserveScanIngested(company string, results int, withError bool) {
+	m.scansIngested.WithLabelValues(company).Inc()
+	m.scanResults.Observe(float64(results))
+	if withError {
+		m.scansWithError.WithLabelValues(company).Inc()
+	}
+}
@@ -3,11 +3,15 @@ package mongo
 import (
 	"context"
 	"fmt"
+	"reflect"
 
 	_ "github.com/golang-migrate/migrate/v4/source/file" // used to register a source for migration files.
 	"go.uber.org/zap"
 
 	healthMongo "github.com/hellofresh/health-go/v4/checks/mongo"
+	"github.com/jeamon/backend-api/pkg/infrastructure/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -57,7 +61,12 @@ func (c *Config) ToDSN() string {
 
 func GetClient(c *Config) (*mongo.Client, error) {
 	connURL := c.ToDSN()
-	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connURL))
+	// decode embedded documents (like the scan metadata) into maps instead of
+	// ordered slices so that they render as json objects.
+	registry := bson.NewRegistryBuilder()
+	registry.RegisterTypeMapEntry(bsontype.EmbeddedDocument, reflect.TypeOf(bson.M{}))
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(connURL).SetRegistry(registry.Build()).SetMonitor(tracing.NewCommandMonitor()))
 	if err != nil {
 		return nil, fmt.Errorf("could not connect to mongo database: %w", err)
 	}
@@ -10,8 +10,10 @@ import (
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file" // used to register a source for migration files.
 	healthPgx4 "github.com/hellofresh/health-go/v4/checks/pgx4"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jackc/pgx/v4/stdlib"
+	"github.com/jeamon/backend-api/pkg/infrastructure/tracing"
 	"go.uber.org/zap"
 )
 
@@ -35,21 +37,33 @@ type Config struct {
 // ConnectAndMigrate establishes a connection to the database based on the configuration provided.
 // Additionally it performs a data migration to ensure that the schema is on the latest version.
 func (c *Config) ConnectAndMigrate(logger *zap.Logger) (*Handler, error) {
-	logger.Info("Connecting to postgres database...")
-
-	pgxConn, stdConn, err := Connect(c)
+	h, err := c.Open(logger)
 	if err != nil {
-		return nil, fmt.Errorf("could not connect to db: %w", err)
+		return nil, err
 	}
 
 	logger.Info("Migrate db schema...")
 
-	err = Migrate(stdConn, c.MigrationPath, "up")
+	err = Migrate(h.StdDB, c.MigrationPath, "up")
 	if err != nil {
 		logger.Info("could not migrate schema up", zap.Error(err))
+		_ = h.Shutdown(context.Background())
 		return nil, fmt.Errorf("could not migrate schema up: %w", err)
 	}
 
+	return h, nil
+}
+
+// Open establishes a connection to the database based on the configuration provided
+// without migrating its schema.
+func (c *Config) Open(logger *zap.Logger) (*Handler, error) {
+	logger.Info("Connecting to postgres database...")
+
+	pgxConn, stdConn, err := Connect(c)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to db: %w", err)
+	}
+
 	return &Handler{
 		PGx:   pgxConn,
 		StdDB: stdConn,
@@ -79,6 +93,9 @@ func Connect(c *Config) (*pgxpool.Pool, *sql.DB, error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not parse config string: %w", err)
 	}
+	// pgx reports the statements to its logger, which records them as spans.
+	conf.ConnConfig.Logger = tracing.QueryTracer{}
+	conf.ConnConfig.LogLevel = pgx.LogLevelInfo
 
 	pgxConn, err := pgxpool.ConnectConfig(context.Background(), conf)
 	if err != nil {
@@ -100,10 +117,84 @@ func (c *Config) Check() func(ctx context.Context) error {
 	return healthPgx4.New(healthPgx4.Config{DSN: c.ToDSN()})
 }
 
+// Migrate applies all the up or all the down migrations found at path.
 func Migrate(db *sql.DB, path, action string) error {
+	m, err := newMigrate(db, path)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		err = m.Up()
+	case "down":
+		err = m.Down()
+	default:
+		return fmt.Errorf("unknown migration action %q", action)
+	}
+
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateTo applies the up or down migrations needed to reach version.
+func MigrateTo(db *sql.DB, path string, version uint) error {
+	m, err := newMigrate(db, path)
+	if err != nil {
+		return err
+	}
+
+	err = m.Migrate(version)
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
+	return nil
+}
+
+// MigrationVersion returns the version of the schema and whether the last
+// migration failed halfway. ErrNilVersion is returned before the first migration.
+func MigrationVersion(db *sql.DB, path string) (uint, bool, error) {
+	m, err := newMigrate(db, path)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return m.Version()
+}
+
+// ForceMigrationVersion records version as the version of the schema without
+// running any migration and clears the dirty flag. It is meant to recover from
+// a failed migration once the schema was fixed by hand. -1 means no version.
+func ForceMigrationVersion(db *sql.DB, path string, version int) error {
+	m, err := newMigrate(db, path)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Force(version); err != nil {
+		return fmt.Errorf("forcing version failed: %w", err)
+	}
+
+	return nil
+}
+
+// ErrNilVersion is returned by MigrationVersion when no migration was applied yet.
+var ErrNilVersion = migrate.ErrNilVersion
+
+func newMigrate(db *sql.DB, path string) (*migrate.Migrate, error) {
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		return fmt.Errorf("could not create driver: %w", err)
+		return nil, fmt.Errorf("could not create driver: %w", err)
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
@@ -112,27 +203,8 @@ func Migrate(db *sql.DB, path, action string) error {
 		driver,
 	)
 	if err != nil {
-		return fmt.Errorf("could not create new migrate instance: %w", err)
+		return nil, fmt.Errorf("could not create new migrate instance: %w", err)
 	}
 
-	switch action {
-	case "up":
-		err = m.Up()
-		if errors.Is(err, migrate.ErrNoChange) {
-			return nil
-		}
-		if err != nil {
-			return fmt.Errorf("migration failed: %w", err)
-		}
-	case "down":
-		err = m.Down()
-		if errors.Is(err, migrate.ErrNoChange) {
-			return nil
-		}
-		if err != nil {
-			return fmt.Errorf("migration failed: %w", err)
-		}
-	}
-
-	return nil
+	return m, nil
 }
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP EXTENSION IF EXISTS "uuid-ossp";
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS data.scan_infos_created_at_id_idx;
+DROP INDEX IF EXISTS data.scan_infos_started_at_id_idx;
+DROP INDEX IF EXISTS data.scan_infos_company_id_idx;
+DROP INDEX IF EXISTS data.scan_infos_repository_url_idx;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS scan_infos_created_at_id_idx ON data.scan_infos (created_at, id);
+CREATE INDEX IF NOT EXISTS scan_infos_started_at_id_idx ON data.scan_infos (started_at, id);
+CREATE INDEX IF NOT EXISTS scan_infos_company_id_idx ON data.scan_infos (company_id);
+CREATE INDEX IF NOT EXISTS scan_infos_repository_url_idx ON data.scan_infos (repository_url);
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS data.scan_findings
+(
+    id UUID DEFAULT uuid_generate_v1mc() PRIMARY KEY,
+    scan_id UUID NOT NULL REFERENCES data.scan_infos (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    rule_id TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    title TEXT NOT NULL,
+    file_path TEXT NOT NULL DEFAULT '',
+    start_line INTEGER NOT NULL DEFAULT 0,
+    end_line INTEGER NOT NULL DEFAULT 0,
+    fingerprint TEXT NOT NULL,
+    scanner TEXT NOT NULL DEFAULT '',
+    properties jsonb NOT NULL DEFAULT '{}'::jsonb,
+    UNIQUE (scan_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS scan_findings_fingerprint_idx ON data.scan_findings (fingerprint);
+CREATE INDEX IF NOT EXISTS scan_findings_rule_id_severity_idx ON data.scan_findings (rule_id, severity);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE data.scan_infos DROP COLUMN IF EXISTS version;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE data.scan_infos ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS data.idempotency_keys;
+
+COMMIT;
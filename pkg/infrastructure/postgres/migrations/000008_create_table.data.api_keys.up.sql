@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS data.api_keys
+(
+    id UUID DEFAULT uuid_generate_v1mc() PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    key_hash TEXT NOT NULL UNIQUE,
+    company_id TEXT NOT NULL,
+    scopes TEXT [] NOT NULL DEFAULT ARRAY []::TEXT [],
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_company_id_idx ON data.api_keys (company_id);
+
+COMMIT;
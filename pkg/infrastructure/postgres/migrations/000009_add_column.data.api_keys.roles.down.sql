@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE data.api_keys DROP COLUMN IF EXISTS roles;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE data.api_keys ADD COLUMN IF NOT EXISTS roles TEXT [] NOT NULL DEFAULT ARRAY []::TEXT [];
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS data.quota_usages;
+
+COMMIT;
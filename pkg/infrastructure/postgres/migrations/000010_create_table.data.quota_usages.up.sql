@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS data.quota_usages
+(
+    company_id TEXT NOT NULL,
+    day TEXT NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (company_id, day)
+);
+
+COMMIT;
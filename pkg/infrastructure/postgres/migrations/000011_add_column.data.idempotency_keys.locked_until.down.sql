@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE data.idempotency_keys DROP COLUMN IF EXISTS locked_until;
+
+COMMIT;
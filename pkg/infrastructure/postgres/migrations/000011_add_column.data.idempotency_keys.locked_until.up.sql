@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE data.idempotency_keys ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+COMMIT;
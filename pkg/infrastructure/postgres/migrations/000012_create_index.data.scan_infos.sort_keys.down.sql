@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS data.scan_infos_completed_at_id_idx;
+DROP INDEX IF EXISTS data.scan_infos_sent_at_id_idx;
+DROP INDEX IF EXISTS data.scan_infos_updated_at_id_idx;
+
+COMMIT;
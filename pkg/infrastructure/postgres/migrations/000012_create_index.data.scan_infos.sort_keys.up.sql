@@ -0,0 +1,7 @@
+BEGIN;
+
+CREATE INDEX IF NOT EXISTS scan_infos_completed_at_id_idx ON data.scan_infos (completed_at, id);
+CREATE INDEX IF NOT EXISTS scan_infos_sent_at_id_idx ON data.scan_infos (sent_at, id);
+CREATE INDEX IF NOT EXISTS scan_infos_updated_at_id_idx ON data.scan_infos (updated_at, id);
+
+COMMIT;
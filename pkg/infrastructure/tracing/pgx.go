@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer records a span for each SQL statement run by pgx. pgx v4 has
+// no tracing hooks so it is plugged as the logger of the connections, which
+// is told about each statement once it completed with its duration.
+type QueryTracer struct {
+	// Next receives the log entries when set.
+	Next pgx.Logger
+}
+
+// Log implements pgx.Logger.
+func (t QueryTracer) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if t.Next != nil {
+		t.Next.Log(ctx, level, msg, data)
+	}
+
+	sql, ok := data["sql"].(string)
+	if !ok {
+		return
+	}
+
+	end := time.Now()
+	start := end
+	if elapsed, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-elapsed)
+	}
+
+	_, span := Tracer().Start(ctx, "postgres "+msg,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBStatementKey.String(sql),
+		),
+	)
+	if err, ok := data["err"].(error); ok {
+		span.RecordError(err, trace.WithTimestamp(end))
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
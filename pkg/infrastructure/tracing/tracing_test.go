@@ -0,0 +1,77 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+func TestSetup(t *testing.T) {
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	t.Run("should pass: stdout exporter into a file", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "traces.json")
+		shutdown, err := Setup(zap.NewNop(), Config{Exporter: ExporterStdout, File: file, SampleRatio: 1, ServiceName: "backend-api"})
+		assert.NoError(t, err)
+
+		ctx, span := Tracer().Start(context.Background(), "test")
+		traceID := TraceID(ctx)
+		span.End()
+		assert.NoError(t, shutdown(context.Background()))
+
+		data, err := ioutil.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), traceID)
+		assert.Contains(t, string(data), `"Name":"test"`)
+	})
+
+	t.Run("should pass: none exporter", func(t *testing.T) {
+		shutdown, err := Setup(zap.NewNop(), Config{Exporter: ExporterNone})
+		assert.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("should fail: unsupported exporter", func(t *testing.T) {
+		_, err := Setup(zap.NewNop(), Config{Exporter: "jaeger"})
+		assert.Error(t, err)
+	})
+}
+
+func TestQueryTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	ctx, parent := Tracer().Start(context.Background(), "parent")
+	tracer := QueryTracer{}
+	tracer.Log(ctx, pgx.LogLevelInfo, "Query", map[string]interface{}{"sql": "SELECT 1", "time": time.Second})
+	tracer.Log(ctx, pgx.LogLevelError, "Exec", map[string]interface{}{"sql": "DELETE FROM x", "err": errors.New("boom")})
+	tracer.Log(ctx, pgx.LogLevelInfo, "closed connection", nil)
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+
+	query := spans[0]
+	assert.Equal(t, "postgres Query", query.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Contains(t, query.Attributes(), semconv.DBStatementKey.String("SELECT 1"))
+	assert.InDelta(t, time.Second, query.EndTime().Sub(query.StartTime()), float64(time.Millisecond))
+
+	exec := spans[1]
+	assert.Equal(t, "postgres Exec", exec.Name())
+	assert.Equal(t, codes.Error, exec.Status().Code)
+}
@@ -2,29 +2,46 @@ package web
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
+	"github.com/jeamon/backend-api/pkg/application"
 	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/jeamon/backend-api/pkg/infrastructure/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
-// RequestLoggerMiddleware logs the metadat and the body of the requests.
+// RequestLoggerMiddleware logs the metadat and the body of the requests. The
+// request is identified by its X-Request-ID header when valid or by a new id
+// otherwise, which is sent back into the X-Request-ID response header.
 func (w *ScanInfosService) RequestLoggerMiddleware() func(*gin.Context) {
 	return func(c *gin.Context) {
-		requestID := generateRequestID()
-		buf, _ := ioutil.ReadAll(c.Request.Body)
+		requestID := requestID(c.Request)
+		buf, readErr := ioutil.ReadAll(c.Request.Body)
 		data, err := readBody(ioutil.NopCloser(bytes.NewBuffer(buf)))
 
 		w.logger.Info(
 			"received request on:",
 			zap.String("url", c.Request.URL.Path),
-			zap.String("authorization", c.GetHeader("Authorization")),
+			zap.String("authorization", redactAuthorization(c.GetHeader("Authorization"))),
 			zap.String("method", c.Request.Method),
 			zap.String("requestid", requestID),
+			zap.String("traceid", c.GetString("x-traceid")),
 			zap.String("ip", getIP(c.Request)),
 			zap.String("agent", c.Request.UserAgent()),
 			zap.String("referer", c.Request.Referer()),
@@ -32,19 +49,338 @@ func (w *ScanInfosService) RequestLoggerMiddleware() func(*gin.Context) {
 			zap.Error(err),
 		)
 		c.Set("x-requestid", requestID)
-		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(buf))
+		c.Header("X-Request-ID", requestID)
+		c.Request = c.Request.WithContext(domain.WithRequestID(c.Request.Context(), requestID))
+		body := io.Reader(bytes.NewBuffer(buf))
+		if readErr != nil {
+			// the handlers fail on the read error after the bytes received.
+			body = io.MultiReader(body, errReader{err: readErr})
+		}
+		c.Request.Body = ioutil.NopCloser(body)
+		c.Next()
+	}
+}
+
+// TracingMiddleware starts the span of the request as a child of the trace
+// context received into the W3C traceparent header, if any, and carries it
+// by the request context.
+func (w *ScanInfosService) TracingMiddleware() func(*gin.Context) {
+	return func(c *gin.Context) {
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx, span := tracing.Tracer().Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(c.Request.Method),
+				semconv.HTTPRouteKey.String(route),
+				semconv.HTTPTargetKey.String(c.Request.URL.RequestURI()),
+			),
+		)
+		defer span.End()
+
+		c.Set("x-traceid", tracing.TraceID(ctx))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+// MetricsMiddleware records the count and the latency of the requests by
+// route template, method and status.
+func (w *ScanInfosService) MetricsMiddleware() func(*gin.Context) {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		w.metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// ClientCertificateMiddleware carries by the request context the client id
+// proven by the client certificate when mutual TLS is enabled. Requests without
+// a verified certificate are rejected when it is required.
+func (w *ScanInfosService) ClientCertificateMiddleware(required bool) func(*gin.Context) {
+	return func(c *gin.Context) {
+		mtls := w.application.Settings.Load().Server.MTLS
+		if !mtls.Enabled {
+			c.Next()
+			return
+		}
+
+		if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+			if !required {
+				c.Next()
+				return
+			}
+
+			w.logger.Error("missing client certificate", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")))
+			writeProblem(c, http.StatusUnauthorized, codeUnauthenticated, "expect a client certificate issued by the trusted client certificate authority.")
+			return
+		}
+
+		clientID := certificateIdentity(c.Request.TLS.VerifiedChains[0][0], mtls.Identity)
+		if clientID == "" {
+			w.logger.Error("client certificate without identity", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.String("field", mtls.Identity))
+			writeProblem(c, http.StatusUnauthorized, codeUnauthenticated, "expect the client id into the "+mtls.Identity+" of the client certificate.")
+			return
+		}
+
+		c.Request = c.Request.WithContext(domain.WithClientID(c.Request.Context(), clientID))
+		c.Next()
+	}
+}
+
+// RequireClientCertificate rejects the requests without a verified client
+// certificate when mutual TLS is enabled. It follows an optional
+// ClientCertificateMiddleware on the writes which must be bound to a client.
+func (w *ScanInfosService) RequireClientCertificate() func(*gin.Context) {
+	return func(c *gin.Context) {
+		if !w.application.Settings.Load().Server.MTLS.Enabled {
+			c.Next()
+			return
+		}
+
+		if _, ok := domain.ClientIDFromContext(c.Request.Context()); !ok {
+			w.logger.Error("missing client certificate", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")))
+			writeProblem(c, http.StatusUnauthorized, codeUnauthenticated, "expect a client certificate issued by the trusted client certificate authority.")
+			return
+		}
+		c.Next()
+	}
+}
+
+// AuthMiddleware authenticates the caller with the API key sent into the
+// X-API-Key header or with the bearer token of the Authorization header, which
+// is either an API key or a JWT. The caller is carried by the request context.
+// Unauthenticated calls are rejected.
+func (w *ScanInfosService) AuthMiddleware() func(*gin.Context) {
+	return func(c *gin.Context) {
+		if w.auth.Disabled() {
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader("X-API-Key")
+		token := ""
+		if key == "" {
+			token = bearerToken(c.GetHeader("Authorization"))
+			if strings.HasPrefix(token, application.APIKeyPrefix) {
+				key, token = token, ""
+			}
+		}
+
+		if key == "" && token == "" {
+			w.logger.Error("missing credentials", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")))
+			c.Header("WWW-Authenticate", `Bearer realm="backend-api"`)
+			writeProblem(c, http.StatusUnauthorized, codeUnauthenticated, "expect an api key into the X-API-Key header or an api key or jwt as bearer token into the Authorization header.")
+			return
+		}
+
+		var principal domain.Principal
+		var err error
+		if key != "" {
+			principal, err = w.auth.AuthenticateAPIKey(c.Request.Context(), key)
+		} else {
+			principal, err = w.auth.AuthenticateJWT(c.Request.Context(), token)
+		}
+		if err != nil {
+			w.logger.Error("unable to authenticate caller", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			c.Header("WWW-Authenticate", `Bearer realm="backend-api", error="invalid_token"`)
+			writeError(c, err)
+			return
+		}
+
+		if clientID, ok := domain.ClientIDFromContext(c.Request.Context()); ok {
+			principal.ClientID = clientID
+		}
+		c.Request = c.Request.WithContext(domain.WithPrincipal(c.Request.Context(), principal))
 		c.Next()
 	}
 }
 
+// RequirePermission rejects the callers whose roles or scopes do not grant
+// perm. It declares the permission needed by the handler it precedes, which
+// the application layer enforces again for the other entry points.
+func (w *ScanInfosService) RequirePermission(perm domain.Permission) func(*gin.Context) {
+	return func(c *gin.Context) {
+		if w.auth.Disabled() {
+			c.Next()
+			return
+		}
+
+		p, _ := domain.PrincipalFromContext(c.Request.Context())
+		if err := p.Require(perm); err != nil {
+			w.logger.Error("missing permission", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.String("permission", string(perm)))
+			writeError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// IPRateLimitMiddleware limits the calls by client ip before they are
+// authenticated. With the ip key, it limits every call. With the other keys,
+// the calls failing authentication are charged to the bucket of their ip, so
+// that a caller sending invalid credentials is rejected once that bucket is
+// empty without its credentials being looked up again.
+func (w *ScanInfosService) IPRateLimitMiddleware() func(*gin.Context) {
+	return func(c *gin.Context) {
+		settings := w.application.Settings.Load()
+		cfg := settings.RateLimit
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + getIP(c.Request)
+		if cfg.Key == "ip" {
+			if w.applyRateLimit(c, settings, w.limiter.allow(key, cfg.RequestsPerSecond, cfg.Burst, time.Now())) {
+				c.Next()
+			}
+			return
+		}
+
+		if d := w.limiter.peek(key, cfg.RequestsPerSecond, cfg.Burst, time.Now()); !d.allowed {
+			w.applyRateLimit(c, settings, d)
+			return
+		}
+
+		c.Next()
+		if c.Writer.Status() == http.StatusUnauthorized {
+			w.limiter.allow(key, cfg.RequestsPerSecond, cfg.Burst, time.Now())
+		}
+	}
+}
+
+// RateLimitMiddleware limits the rate of the authenticated api calls with a
+// token bucket kept per API key or company as configured, or per client ip
+// when authentication is disabled. The calls limited by ip are left to the
+// IPRateLimitMiddleware which precedes the authentication.
+func (w *ScanInfosService) RateLimitMiddleware() func(*gin.Context) {
+	return func(c *gin.Context) {
+		settings := w.application.Settings.Load()
+		cfg := settings.RateLimit
+		if !cfg.Enabled || cfg.Key == "ip" {
+			c.Next()
+			return
+		}
+
+		if w.applyRateLimit(c, settings, w.limiter.allow(rateLimitKey(c, cfg.Key), cfg.RequestsPerSecond, cfg.Burst, time.Now())) {
+			c.Next()
+		}
+	}
+}
+
+// applyRateLimit reports the state of the bucket with the RateLimit-* headers
+// and rejects the call with the time to wait into the Retry-After header when
+// it is not allowed. It reports whether the call can go on.
+func (w *ScanInfosService) applyRateLimit(c *gin.Context, settings *config.Config, d rateDecision) bool {
+	cfg := settings.RateLimit
+	c.Header("RateLimit-Limit", strconv.Itoa(d.limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(d.remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.reset)))
+	if d.allowed {
+		return true
+	}
+
+	w.logger.Error("rate limit exceeded", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.String("key", cfg.Key))
+	c.Header("Retry-After", strconv.Itoa(ceilSeconds(d.retryAfter)))
+	writeProblem(c, http.StatusTooManyRequests, codeRateLimited, fmt.Sprintf("rate limit of %g requests per second with bursts of %d exceeded.", cfg.RequestsPerSecond, cfg.Burst))
+	return false
+}
+
+// IdempotencyMiddleware answers the retries of a request sent with the same
+// Idempotency-Key header with the response of the original request instead of
+// processing them again. A key reused with a different request is rejected.
+// Only successful responses are remembered so that failed requests can be retried.
+func (w *ScanInfosService) IdempotencyMiddleware() func(*gin.Context) {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" || w.idempotency == nil {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			w.logger.Error("invalid idempotency key", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("expect Idempotency-Key header of at most %d characters.", maxIdempotencyKeyLength))
+			return
+		}
+
+		// a partially read body must not be reserved as the request.
+		body, err := ioutil.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			w.logger.Error("unable to read request body", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("unable to read the request body of at most %d bytes sent with an Idempotency-Key header.", maxIdempotentBodySize))
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		hash := hashRequest(c.Request, body)
+		// keys of different companies never collide.
+		if p, ok := domain.PrincipalFromContext(c.Request.Context()); ok {
+			key = p.CompanyID + ":" + key
+		}
+
+		record, reserved, err := w.idempotency.Reserve(c.Request.Context(), key, hash)
+		if err != nil {
+			w.logger.Error("unable to reserve idempotency key", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
+			return
+		}
+
+		if !reserved {
+			switch {
+			case record.RequestHash != hash:
+				w.logger.Error("idempotency key reused with another request", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")))
+				writeProblem(c, http.StatusUnprocessableEntity, codeIdempotencyKeyReused, "the Idempotency-Key header was already sent with a different request.")
+			case !record.Completed():
+				if wait := time.Until(record.LockedUntil); wait > 0 {
+					c.Header("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+				}
+				writeProblem(c, http.StatusConflict, codeRequestInProgress, "the original request sent with the Idempotency-Key header is still being processed.")
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.StatusCode, "application/json; charset=utf-8", record.Response)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// the outcome is saved even if the client went away.
+		ctx := context.Background()
+		if status := recorder.Status(); status >= 200 && status < 300 {
+			err = w.idempotency.Complete(ctx, key, status, recorder.body.Bytes())
+		} else {
+			err = w.idempotency.Release(ctx, key)
+		}
+
+		if err != nil {
+			w.logger.Error("unable to save idempotency key outcome", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+		}
+	}
+}
+
 func (w *ScanInfosService) NotFoundHandler() func(*gin.Context) {
 	return func(c *gin.Context) {
-		w.logger.Error("route does not exist", zap.String("requestid", c.GetString("x-requestid")))
-		c.JSON(http.StatusNotImplemented, errResponse{
-			RequestID:        c.GetString("x-requestid"),
-			Message:          "invalid request. make sure to use the exact endpoint.",
-			DeveloperMessage: "endpoint called with that method does not exist.",
-		})
+		w.logger.Error("route does not exist", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")))
+		writeProblem(c, http.StatusNotFound, codeRouteNotFound, "no endpoint matches the method and the path of the request.")
 	}
 }
 
@@ -56,29 +392,22 @@ func (w *ScanInfosService) NotFoundHandler() func(*gin.Context) {
 // @Produce  json
 // @Param scanInfosStore body ScanInfos true "Store Scan Infos"
 // @Success 200 {object} genericResponse
-// @Success 500 {object} errResponse
+// @Success 500 {object} problemResponse
 // @Router /api/v1/scaninfos [post]
 func (w *ScanInfosService) StoreScanInfosHandler() func(*gin.Context) {
 	return func(c *gin.Context) {
 		var req domain.StoreScanInfosRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			w.logger.Error("unable to bind store request input", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(http.StatusBadRequest, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "invalid request. make sure to provide expected data format",
-				DeveloperMessage: err.Error(),
-			})
+			w.logger.Error("unable to bind store request input", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, err.Error())
 			return
 		}
 
-		id, err := w.application.Store(c, req)
+		id, err := w.application.Store(c.Request.Context(), req)
 		if err != nil {
-			w.logger.Error("unable to store scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "an error occurred while storing the scan infos",
-				DeveloperMessage: err.Error(),
-			})
+			w.logger.Error("unable to store scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			setRetryAfter(c, err)
+			writeError(c, err)
 			return
 		}
 
@@ -90,6 +419,109 @@ func (w *ScanInfosService) StoreScanInfosHandler() func(*gin.Context) {
 	}
 }
 
+// scanInfosActions are the custom methods of the scan infos collection.
+var scanInfosActions = map[string]bool{
+	":batch": true,
+}
+
+// KnownActionMiddleware answers 404 to the custom methods of the scan infos
+// collection which do not exist. Their route matches any path starting with
+// the collection name, so they are rejected before the caller permissions
+// are checked.
+func (w *ScanInfosService) KnownActionMiddleware() func(*gin.Context) {
+	notFound := w.NotFoundHandler()
+	return func(c *gin.Context) {
+		if !scanInfosActions[c.Param("action")] {
+			notFound(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// ScanInfosActionHandler dispatches the custom methods of the scan infos
+// collection (ie. POST /scaninfos:batch) to their handler.
+func (w *ScanInfosService) ScanInfosActionHandler() func(*gin.Context) {
+	batch := w.BatchStoreScanInfosHandler()
+	notFound := w.NotFoundHandler()
+	return func(c *gin.Context) {
+		switch c.Param("action") {
+		case ":batch":
+			batch(c)
+		default:
+			notFound(c)
+		}
+	}
+}
+
+// BatchStoreScanInfosHandler ...
+// @Summary submit many performed scans
+// @Description store a batch of scan information sent as a json array or as newline delimited json
+// @Tags ScanInfos
+// @Accept  json
+// @Accept  application/x-ndjson
+// @Produce  json
+// @Param mode query string false "atomic (default) or best_effort"
+// @Param scanInfosBatch body []domain.StoreScanInfosRequest true "Scan Infos Batch"
+// @Success 200 {object} batchScanInfosResponse
+// @Success 207 {object} batchScanInfosResponse
+// @Failure 400 {object} problemResponse
+// @Failure 422 {object} problemResponse
+// @Failure 500 {object} problemResponse
+// @Router /api/v1/scaninfos:batch [post]
+func (w *ScanInfosService) BatchStoreScanInfosHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		mode := c.DefaultQuery("mode", domain.BatchModeAtomic)
+		if !domain.IsValidBatchMode(mode) {
+			w.logger.Error("invalid batch mode", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.String("mode", mode))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, "expect mode query parameter to be "+domain.BatchModeAtomic+" or "+domain.BatchModeBestEffort+".")
+			return
+		}
+
+		items, err := readBatchItems(c.ContentType(), c.Request.Body)
+		if err != nil {
+			w.logger.Error("unable to read batch request input", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+
+		results, err := w.application.StoreBatch(c.Request.Context(), items, mode)
+		if errors.Is(err, domain.ErrValidation) {
+			w.logger.Error("invalid scan infos batch", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblemErrors(c, errorStatus(err), errorCode(err), err.Error(), batchFieldErrors(results))
+			return
+		}
+		if err != nil {
+			w.logger.Error("unable to store scan infos batch", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			setRetryAfter(c, err)
+			writeError(c, err)
+			return
+		}
+
+		res := batchScanInfosResponse{
+			RequestID: c.GetString("x-requestid"),
+			Message:   "scan infos batch processed successfully",
+			Mode:      mode,
+			Results:   results,
+		}
+		for _, r := range results {
+			if r.ScanInfosID != "" {
+				res.Stored++
+			} else {
+				res.Failed++
+			}
+		}
+
+		status := http.StatusOK
+		if res.Failed > 0 {
+			res.Message = "scan infos batch partially stored"
+			status = http.StatusMultiStatus
+		}
+
+		c.JSON(status, res)
+	}
+}
+
 // GetScanInfosHandler ...
 // @Summary get a scan infos
 // @Description get a scan information by its id
@@ -98,62 +530,88 @@ func (w *ScanInfosService) StoreScanInfosHandler() func(*gin.Context) {
 // @Produce  json
 // @Param id path string true "ID string"
 // @Success 200 {object} ScanInfos
-// @Failure 400 {object} errResponse
-// @Failure 500 {object} errResponse
+// @Failure 400 {object} problemResponse
+// @Failure 404 {object} problemResponse
+// @Failure 500 {object} problemResponse
 // @Router /api/v1/scaninfos/{id} [get]
 func (w *ScanInfosService) GetScanInfosHandler() func(*gin.Context) {
 	return func(c *gin.Context) {
 		id := c.Param("id")
 		if _, err := uuid.FromString(id); err != nil {
-			w.logger.Error("bad request. invalid id", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(400, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "bad request. cannot get scan infos.",
-				DeveloperMessage: "expect non empty id as parameter of the scan infosto fetch.",
-			})
+			w.logger.Error("bad request. invalid id", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, "expect non empty id as parameter of the scan infos to fetch.")
 			return
 		}
 
-		infos, err := w.application.Get(c, id)
+		infos, err := w.application.Get(c.Request.Context(), id)
 		if err != nil {
-			w.logger.Error("unable to get scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(500, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "an error occurred while fetching the scan infos",
-				DeveloperMessage: err.Error(),
-			})
+			w.logger.Error("unable to get scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
 			return
 		}
+		// findings are only part of the v2 representation.
+		infos.Findings = nil
+		c.Header("ETag", formatETag(infos.Version))
 		c.JSON(200, infos)
 	}
 }
 
 // GetAllScanInfosHandler ...
-// @Summary get all scan infos
-// @Description get a list of all stored scan information
+// @Summary list scan infos
+// @Description get a page of stored scan information matching the filters
 // @Tags ScanInfos
 // @Accept  json
 // @Produce  json
+// @Param company_id query string false "filter by company id"
+// @Param client_id query string false "filter by client id"
+// @Param repository_url query string false "filter by repository url"
+// @Param commit_id query string false "filter by commit id"
+// @Param tag_id query string false "filter by tag id"
+// @Param started_from query int false "scans started at or after this unix time"
+// @Param started_to query int false "scans started at or before this unix time"
+// @Param completed_from query int false "scans completed at or after this unix time"
+// @Param completed_to query int false "scans completed at or before this unix time"
+// @Param has_error query bool false "keep only failed or successful scans"
+// @Param sort query string false "sort field, prefixed by - for descending order (default -created_at)"
+// @Param limit query int false "page size (default 50, max 500)"
+// @Param cursor query string false "next_cursor value of the previous page"
 // @Success 200 {object} getAllScanInfosResponse
-// @Failure 500 {object} errResponse
+// @Failure 400 {object} problemResponse
+// @Failure 500 {object} problemResponse
 // @Router /api/v1/scaninfos [get]
 func (w *ScanInfosService) GetAllScanInfosHandler() func(*gin.Context) {
 	return func(c *gin.Context) {
-		infos, err := w.application.GetAll(c)
+		var req listScanInfosRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			w.logger.Error("unable to bind list request query", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+
+		query := req.toQuery()
+		if err := query.Normalize(); err != nil {
+			w.logger.Error("invalid list request query", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+
+		page, err := w.application.List(c.Request.Context(), query)
 		if err != nil {
-			w.logger.Error("unable to fetch all scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(500, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "an error occurred while fetching all scan infos",
-				DeveloperMessage: err.Error(),
-			})
+			w.logger.Error("unable to fetch all scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
 			return
 		}
 
+		for i := range page.Infos {
+			page.Infos[i].Findings = nil
+		}
+
 		c.JSON(200, getAllScanInfosResponse{
-			RequestID: c.GetString("x-requestid"),
-			Message:   "all scan infos fetched successfully",
-			Infos:     infos,
+			RequestID:  c.GetString("x-requestid"),
+			Message:    "all scan infos fetched successfully",
+			Infos:      page.Infos,
+			NextCursor: page.NextCursor,
+			Total:      page.Total,
 		})
 	}
 }
@@ -165,29 +623,33 @@ func (w *ScanInfosService) GetAllScanInfosHandler() func(*gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param scanInfosStore body ScanInfos true "Update Scan Infos"
+// @Param If-Match header string false "entity tag of the version to update"
 // @Success 200 {object} genericResponse
-// @Success 500 {object} errResponse
+// @Failure 404 {object} problemResponse
+// @Failure 412 {object} problemResponse
+// @Success 500 {object} problemResponse
 // @Router /api/v1/scaninfos [put]
 func (w *ScanInfosService) UpdateScanInfosHandler() func(*gin.Context) {
 	return func(c *gin.Context) {
 		var req domain.ScanInfos
 		if err := c.ShouldBindJSON(&req); err != nil {
-			w.logger.Error("unable to bind update request input", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(http.StatusBadRequest, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "invalid request. make sure to provide expected data format",
-				DeveloperMessage: err.Error(),
-			})
+			w.logger.Error("unable to bind update request input", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+
+		version, err := parseIfMatch(c.Request)
+		if err != nil {
+			w.logger.Error("invalid update precondition", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusPreconditionFailed, codePreconditionFailed, err.Error())
 			return
 		}
+		// the expected version only comes from the If-Match header.
+		req.Version = version
 
-		if err := w.application.Update(c, req); err != nil {
-			w.logger.Error("unable to store scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "an error occurred while updating the scan infos",
-				DeveloperMessage: err.Error(),
-			})
+		if err := w.application.Update(c.Request.Context(), req); err != nil {
+			w.logger.Error("unable to store scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
 			return
 		}
 
@@ -199,6 +661,67 @@ func (w *ScanInfosService) UpdateScanInfosHandler() func(*gin.Context) {
 	}
 }
 
+// PatchScanInfosHandler ...
+// @Summary partially update an existing scan details
+// @Description apply a JSON Merge Patch or a JSON Patch document to a scan information based on its ID
+// @Tags ScanInfos
+// @Accept  application/merge-patch+json
+// @Accept  application/json-patch+json
+// @Produce  json
+// @Param id path string true "ID string"
+// @Param If-Match header string false "entity tag of the version to patch"
+// @Success 200 {object} domain.ScanInfos
+// @Failure 400 {object} problemResponse
+// @Failure 404 {object} problemResponse
+// @Failure 409 {object} problemResponse
+// @Failure 412 {object} problemResponse
+// @Failure 415 {object} problemResponse
+// @Failure 422 {object} problemResponse
+// @Failure 500 {object} problemResponse
+// @Router /api/v1/scaninfos/{id} [patch]
+func (w *ScanInfosService) PatchScanInfosHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if _, err := uuid.FromString(id); err != nil {
+			w.logger.Error("bad request. invalid id", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, "expect non empty id as parameter of the scan infos to patch.")
+			return
+		}
+
+		patchType := c.ContentType()
+		if !domain.IsValidPatchType(patchType) {
+			w.logger.Error("unsupported patch document type", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.String("type", patchType))
+			writeProblem(c, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "expect content type "+domain.MergePatchType+" or "+domain.JSONPatchType+".")
+			return
+		}
+
+		patch, err := c.GetRawData()
+		if err != nil || len(patch) == 0 {
+			w.logger.Error("unable to read patch request input", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, "expect a non empty request body.")
+			return
+		}
+
+		version, err := parseIfMatch(c.Request)
+		if err != nil {
+			w.logger.Error("invalid patch precondition", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusPreconditionFailed, codePreconditionFailed, err.Error())
+			return
+		}
+
+		infos, err := w.application.Patch(c.Request.Context(), id, patchType, patch, version)
+		if err != nil {
+			w.logger.Error("unable to patch scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
+			return
+		}
+
+		infos.Findings = nil
+		c.Header("ETag", formatETag(infos.Version))
+		c.JSON(http.StatusOK, infos)
+	}
+}
+
 // DeleteScanInfosHandler ...
 // @Summary get a scan infos
 // @Description get a scan information by its id
@@ -206,32 +729,33 @@ func (w *ScanInfosService) UpdateScanInfosHandler() func(*gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "ID string"
+// @Param If-Match header string false "entity tag of the version to delete"
 // @Success 200 {object} genericResponse
-// @Failure 400 {object} errResponse
-// @Failure 500 {object} errResponse
+// @Failure 400 {object} problemResponse
+// @Failure 404 {object} problemResponse
+// @Failure 412 {object} problemResponse
+// @Failure 500 {object} problemResponse
 // @Router /api/v1/scaninfos/{id} [delete]
 func (w *ScanInfosService) DeleteScanInfosHandler() func(*gin.Context) {
 	return func(c *gin.Context) {
 		id := c.Param("id")
-		fmt.Println(id)
 		if _, err := uuid.FromString(id); err != nil {
-			w.logger.Error("bad request. invalid id", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(http.StatusBadRequest, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "bad request. cannot delete scan infos.",
-				DeveloperMessage: "expect non empty id as parameter of the scan infos to delete.",
-			})
+			w.logger.Error("bad request. invalid id", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, "expect non empty id as parameter of the scan infos to delete.")
+			return
+		}
+
+		version, err := parseIfMatch(c.Request)
+		if err != nil {
+			w.logger.Error("invalid delete precondition", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusPreconditionFailed, codePreconditionFailed, err.Error())
 			return
 		}
 
-		err := w.application.Delete(c, id)
+		err = w.application.Delete(c.Request.Context(), id, version)
 		if err != nil {
-			w.logger.Error("unable to delete scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.Error(err))
-			c.JSON(http.StatusInternalServerError, errResponse{
-				RequestID:        c.GetString("x-requestid"),
-				Message:          "an error occurred while deleting the scan infos",
-				DeveloperMessage: err.Error(),
-			})
+			w.logger.Error("unable to delete scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
 			return
 		}
 
@@ -242,3 +766,110 @@ func (w *ScanInfosService) DeleteScanInfosHandler() func(*gin.Context) {
 		})
 	}
 }
+
+// DiffScanInfosHandler ...
+// @Summary compare two scan infos
+// @Description get the findings introduced, fixed and unchanged between a base scan and the scan id
+// @Tags ScanInfos
+// @Accept  json
+// @Produce  json
+// @Param id path string true "ID string of the head scan"
+// @Param base query string true "ID string of the base scan"
+// @Success 200 {object} diffScanInfosResponse
+// @Failure 400 {object} problemResponse
+// @Failure 404 {object} problemResponse
+// @Failure 500 {object} problemResponse
+// @Router /api/v1/scaninfos/{id}/diff [get]
+func (w *ScanInfosService) DiffScanInfosHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		id, baseID := c.Param("id"), c.Query("base")
+		_, errID := uuid.FromString(id)
+		_, errBase := uuid.FromString(baseID)
+		if errID != nil || errBase != nil {
+			w.logger.Error("bad request. invalid ids", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.NamedError("id", errID), zap.NamedError("base", errBase))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, "expect valid id as parameter and valid base id as query parameter of the scan infos to compare.")
+			return
+		}
+
+		diff, err := w.application.Diff(c.Request.Context(), id, baseID)
+		if err != nil {
+			w.logger.Error("unable to compare scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, diffScanInfosResponse{
+			RequestID:     c.GetString("x-requestid"),
+			Message:       "scan infos compared successfully",
+			ScanInfosDiff: diff,
+		})
+	}
+}
+
+// DiffScanInfosByRefsHandler ...
+// @Summary compare the scans of a repository at two commits or tags
+// @Description get the findings introduced, fixed and unchanged between the latest scans of a repository at base and head refs
+// @Tags ScanInfos
+// @Accept  json
+// @Produce  json
+// @Param repository_url query string true "repository url"
+// @Param base query string true "base commit id or tag id"
+// @Param head query string true "head commit id or tag id"
+// @Success 200 {object} diffScanInfosResponse
+// @Failure 400 {object} problemResponse
+// @Failure 404 {object} problemResponse
+// @Failure 500 {object} problemResponse
+// @Router /api/v1/scaninfos/diff [get]
+func (w *ScanInfosService) DiffScanInfosByRefsHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		repositoryURL, base, head := c.Query("repository_url"), c.Query("base"), c.Query("head")
+		if repositoryURL == "" || base == "" || head == "" {
+			w.logger.Error("bad request. missing refs", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, "expect non empty repository_url, base and head query parameters.")
+			return
+		}
+
+		diff, err := w.application.DiffByRefs(c.Request.Context(), repositoryURL, base, head)
+		if err != nil {
+			w.logger.Error("unable to compare scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, diffScanInfosResponse{
+			RequestID:     c.GetString("x-requestid"),
+			Message:       "scan infos compared successfully",
+			ScanInfosDiff: diff,
+		})
+	}
+}
+
+// GetQuotaUsagesHandler ...
+// @Summary list quota usages
+// @Description get the scans submitted by each company during a UTC day along with their daily quota
+// @Tags Admin
+// @Produce  json
+// @Param day query string false "UTC day formatted as 2006-01-02 (default today)"
+// @Success 200 {object} quotaUsagesResponse
+// @Failure 403 {object} problemResponse
+// @Failure 422 {object} problemResponse
+// @Failure 500 {object} problemResponse
+// @Router /api/v1/admin/quotas [get]
+func (w *ScanInfosService) GetQuotaUsagesHandler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		day := c.DefaultQuery("day", time.Now().UTC().Format(domain.QuotaDayLayout))
+		usages, err := w.quotas.Usages(c.Request.Context(), day)
+		if err != nil {
+			w.logger.Error("unable to list quota usages", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, quotaUsagesResponse{
+			RequestID: c.GetString("x-requestid"),
+			Message:   "quota usages fetched successfully",
+			Day:       day,
+			Usages:    usages,
+		})
+	}
+}
@@ -1,25 +1,56 @@
 package web
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/imroc/req"
 	"github.com/jeamon/backend-api/pkg/application"
 	"github.com/jeamon/backend-api/pkg/domain"
 	"github.com/jeamon/backend-api/pkg/infrastructure/config"
+	"github.com/jeamon/backend-api/pkg/infrastructure/jwks"
+	"github.com/jeamon/backend-api/pkg/infrastructure/metrics"
 	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
 	"github.com/jeamon/backend-api/pkg/interfaces/repository"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 var (
 	testLogger                = zap.L()
-	testConfigData            = &config.Config{}
-	testScanInfosID           = "7aec1a3e-f22d-11ec-a1c2-37e6aab6bd2c"
+	testUnknownScanInfosID    = "7aec1a3e-f22d-11ec-a1c2-37e6aab6bd2c"
 	testStoreScanInfosRequest = domain.StoreScanInfosRequest{
 		CompanyID:     "0",
 		Username:      "jeamon",
@@ -38,6 +69,34 @@ var (
 			"arch":      "amd64",
 		},
 	}
+	testStoreScanInfosV2Request = domain.StoreScanInfosV2Request{
+		CompanyID:     "0",
+		Username:      "jeamon",
+		ClientID:      "v1.0.0",
+		RepositoryURL: "https://github.com/jeamon/backend-api",
+		CommitID:      "d7b8ff1412ebfcde26f9ddfdf9608d1525647958",
+		TagID:         "v1.0.0",
+		Findings: []domain.Finding{
+			{
+				RuleID:    "G101",
+				Severity:  domain.SeverityHigh,
+				Title:     "Potential hardcoded credentials",
+				FilePath:  "pkg/infrastructure/config/app.go",
+				StartLine: 12,
+				EndLine:   12,
+				Scanner:   "gosec",
+				Properties: map[string]interface{}{
+					"confidence": "low",
+				},
+			},
+		},
+		StartedAt:   1655903720,
+		CompletedAt: 1655903723,
+		SentAt:      1655903725,
+		Metadata: map[string]interface{}{
+			"os": "linux",
+		},
+	}
 	testScanInfos = domain.ScanInfos{
 		ID:            "5c9828c6-f7f4-11ec-aa0a-d3f9ac7b9396",
 		CompanyID:     "0",
@@ -59,27 +118,809 @@ var (
 	}
 )
 
-func setupTestServer() *httptest.Server {
+// setupTestServer starts a server without authentication backed by an
+// in-memory repository seeded with testScanInfos and returns the ID of the
+// seeded record.
+func setupTestServer() (*httptest.Server, string) {
+	configData := &config.Config{}
+	configData.Auth.Disabled = true
+	ts, id, _ := newTestServer(config.NewLive(configData), nil)
+	return ts, id
+}
+
+// newTestServer starts a server using settings backed by in-memory
+// repositories seeded with testScanInfos. It returns the ID of the seeded
+// record and the use case managing the API keys. Bearer JWTs are verified with
+// jwtKeys when it is not nil.
+func newTestServer(settings *config.Live, jwtKeys application.JWTKeyProvider) (*httptest.Server, string, *application.AuthUsecase) {
+	router, id, authUc := newTestRouter(settings, jwtKeys)
+	return httptest.NewServer(router), id, authUc
+}
+
+// newTestRouter builds the router served by newTestServer.
+func newTestRouter(settings *config.Live, jwtKeys application.JWTKeyProvider) (http.Handler, string, *application.AuthUsecase) {
+	mockDB := mockdb.Config{}
+	mockdbHandler, _ := mockDB.ConnectAndMigrate(testLogger)
+	testRepo := repository.NewInMemoryScanInfosRepository(testLogger, mockdbHandler)
+	id, _ := testRepo.Save(context.Background(), testScanInfos)
+	quotaUc := application.NewQuotaUsecase(testLogger, settings, repository.NewInMemoryQuotaRepository(testLogger, mockdbHandler))
+	scanInfosUc := application.NewScanInfosUsecase(testLogger, settings, testRepo, quotaUc)
+	idempotencyUc := application.NewIdempotencyUsecase(testLogger, settings, repository.NewInMemoryIdempotencyRepository(testLogger, mockdbHandler))
+	authUc := application.NewAuthUsecase(testLogger, settings, repository.NewInMemoryAPIKeyRepository(testLogger, mockdbHandler), jwtKeys)
+	service := New(testLogger, scanInfosUc, idempotencyUc, authUc, quotaUc, nil)
+	gin.SetMode(gin.TestMode)
+	return service.Router(gin.Default()), id, authUc
+}
+
+func TestMetrics(t *testing.T) {
+	settings := config.NewLive(&config.Config{})
+	appMetrics := metrics.New()
 	mockDB := mockdb.Config{}
 	mockdbHandler, _ := mockDB.ConnectAndMigrate(testLogger)
-	testRepo := repository.NewMockDBScanInfosRepository(testLogger, mockdbHandler, "mockdb")
-	scanInfosUc := application.NewScanInfosUsecase(testLogger, testConfigData, testRepo)
-	service := New(testLogger, scanInfosUc)
+	testRepo := repository.NewInstrumentedScanInfosRepository(testLogger, repository.NewInMemoryScanInfosRepository(testLogger, mockdbHandler), "memory", appMetrics)
+	quotaUc := application.NewQuotaUsecase(testLogger, settings, repository.NewInMemoryQuotaRepository(testLogger, mockdbHandler))
+	scanInfosUc := application.NewScanInfosUsecase(testLogger, settings, testRepo, quotaUc)
+	idempotencyUc := application.NewIdempotencyUsecase(testLogger, settings, repository.NewInMemoryIdempotencyRepository(testLogger, mockdbHandler))
+	authUc := application.NewAuthUsecase(testLogger, settings, repository.NewInMemoryAPIKeyRepository(testLogger, mockdbHandler), nil)
 	gin.SetMode(gin.TestMode)
-	ts := httptest.NewServer(service.Router(gin.Default()))
-	return ts
+	router := New(testLogger, scanInfosUc, idempotencyUc, authUc, quotaUc, appMetrics).Router(gin.Default())
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	ctx := context.Background()
+	adminKey, _, err := authUc.CreateAPIKey(ctx, "admin", "1", nil, []string{domain.RoleAdmin})
+	assert.NoError(t, err)
+	viewerKey, _, err := authUc.CreateAPIKey(ctx, "viewer", testStoreScanInfosRequest.CompanyID, nil, []string{domain.RoleViewer})
+	assert.NoError(t, err)
+	admin := req.Header{"X-API-Key": adminKey}
+
+	res, err := req.Post(ts.URL+"/api/v1/scaninfos", admin, req.BodyJSON(&testStoreScanInfosRequest))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+	res, err = req.Get(ts.URL+"/api/v1/scaninfos/7aec1a3e-f22d-11ec-a1c2-37e6aab6bd2c", admin)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+
+	// the per company series are only disclosed to the admins.
+	res, err = req.Get(ts.URL + "/metrics")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode)
+	res, err = req.Get(ts.URL+"/metrics", req.Header{"X-API-Key": viewerKey})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+
+	res, err = req.Get(ts.URL+"/metrics", admin)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+	body := res.String()
+	for _, line := range []string{
+		`backend_api_http_requests_total{method="POST",route="/api/v1/scaninfos",status="200"} 1`,
+		`backend_api_http_requests_total{method="GET",route="/api/v1/scaninfos/:id",status="404"} 1`,
+		`backend_api_http_request_duration_seconds_count{method="POST",route="/api/v1/scaninfos",status="200"} 1`,
+		`backend_api_repository_call_duration_seconds_count{backend="memory",operation="save"} 1`,
+		`backend_api_repository_errors_total{backend="memory",kind="not_found",operation="find_by_id"} 1`,
+		`backend_api_scans_ingested_total{company="` + testStoreScanInfosRequest.CompanyID + `"} 1`,
+		`backend_api_scans_with_error_total{company="` + testStoreScanInfosRequest.CompanyID + `"} 1`,
+		`backend_api_scan_results_count 1`,
+	} {
+		assert.Contains(t, body, line)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	ts, id := setupTestServer()
+	defer ts.Close()
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"traceparent": traceparent})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	server, found := spans["GET /api/v1/scaninfos/:id"]
+	if assert.True(t, found) {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+		assert.Contains(t, server.Attributes(), semconv.HTTPStatusCodeKey.Int(http.StatusOK))
+	}
+
+	usecase, found := spans["ScanInfosUsecase.Get"]
+	if assert.True(t, found) && server != nil {
+		assert.Equal(t, server.SpanContext().SpanID(), usecase.Parent().SpanID())
+	}
+}
+
+func TestRequestIDAndProblemResponses(t *testing.T) {
+	ts, id := setupTestServer()
+	defer ts.Close()
+
+	t.Run("Request id tests", func(t *testing.T) {
+		t.Run("should pass: incoming request id echoed", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"X-Request-ID": "client-42.retry:1"})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.Equal(t, "client-42.retry:1", res.Response().Header.Get("X-Request-ID"))
+		})
+
+		t.Run("should pass: invalid request id replaced", func(t *testing.T) {
+			for _, invalid := range []string{"bad id", "<script>", strings.Repeat("a", maxRequestIDLength+1)} {
+				res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"X-Request-ID": invalid})
+				assert.NoError(t, err)
+				requestID := res.Response().Header.Get("X-Request-ID")
+				assert.NotEqual(t, invalid, requestID)
+				_, err = uuid.FromString(requestID)
+				assert.NoError(t, err)
+			}
+		})
+
+		t.Run("should pass: request id generated", func(t *testing.T) {
+			res, err := req.Get(ts.URL + "/ping")
+			assert.NoError(t, err)
+			assert.NotEmpty(t, res.Response().Header.Get("X-Request-ID"))
+		})
+	})
+
+	t.Run("Problem responses tests", func(t *testing.T) {
+		t.Run("should pass: unknown route", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/unknown", req.Header{"X-Request-ID": "req-1"})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+
+			var problem problemResponse
+			assert.NoError(t, res.ToJSON(&problem))
+			assert.Equal(t, problemResponse{
+				Type:      "about:blank",
+				Title:     "Not Found",
+				Status:    http.StatusNotFound,
+				Detail:    "no endpoint matches the method and the path of the request.",
+				Instance:  "/api/v1/unknown",
+				RequestID: "req-1",
+				Code:      codeRouteNotFound,
+			}, problem)
+		})
+
+		t.Run("should pass: unknown scan infos", func(t *testing.T) {
+			res, err := req.Get(ts.URL + "/api/v1/scaninfos/" + testUnknownScanInfosID)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+
+			var problem problemResponse
+			assert.NoError(t, res.ToJSON(&problem))
+			assert.Equal(t, codeNotFound, problem.Code)
+			assert.Equal(t, res.Response().Header.Get("X-Request-ID"), problem.RequestID)
+		})
+
+		t.Run("should pass: invalid body", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(map[string]string{"company_id": "0"}))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+
+			var problem problemResponse
+			assert.NoError(t, res.ToJSON(&problem))
+			assert.Equal(t, codeBadRequest, problem.Code)
+			assert.NotEmpty(t, problem.Detail)
+		})
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	ts, id, auth := newTestServer(config.NewLive(&config.Config{}), nil)
+	defer ts.Close()
+
+	ctx := context.Background()
+	readKey, _, err := auth.CreateAPIKey(ctx, "reader", testScanInfos.CompanyID, []string{domain.ScopeRead}, nil)
+	assert.NoError(t, err)
+	writeKey, _, err := auth.CreateAPIKey(ctx, "writer", testScanInfos.CompanyID, []string{domain.ScopeWrite}, nil)
+	assert.NoError(t, err)
+	otherKey, _, err := auth.CreateAPIKey(ctx, "other", "1", []string{domain.ScopeRead, domain.ScopeWrite, domain.ScopeDelete}, nil)
+	assert.NoError(t, err)
+	revokedKey, revoked, err := auth.CreateAPIKey(ctx, "revoked", testScanInfos.CompanyID, []string{domain.ScopeRead}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, auth.RevokeAPIKey(ctx, revoked.ID))
+
+	t.Run("Authentication tests", func(t *testing.T) {
+		t.Run("should pass: api key header", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"X-API-Key": readKey})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+
+		t.Run("should pass: bearer api key", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos", req.Header{"Authorization": "Bearer " + readKey})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			var all getAllScanInfosResponse
+			assert.NoError(t, res.ToJSON(&all))
+			assert.Equal(t, int64(1), all.Total)
+		})
+
+		t.Run("should fail: no api key", func(t *testing.T) {
+			res, err := req.Get(ts.URL + "/api/v1/scaninfos/" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Response().Header.Get("WWW-Authenticate"))
+		})
+
+		t.Run("should fail: unknown or revoked api key", func(t *testing.T) {
+			for _, key := range []string{"bk_unknown", "not-a-key", revokedKey} {
+				res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"X-API-Key": key})
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode)
+			}
+		})
+
+		t.Run("should fail: missing scope", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"X-API-Key": writeKey})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+
+			res, err = req.Delete(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"X-API-Key": readKey})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: scans of another company", func(t *testing.T) {
+			headers := req.Header{"X-API-Key": otherKey}
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, headers)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+
+			res, err = req.Get(ts.URL+"/api/v1/scaninfos?company_id="+testScanInfos.CompanyID, headers)
+			assert.NoError(t, err)
+			var all getAllScanInfosResponse
+			assert.NoError(t, res.ToJSON(&all))
+			assert.Equal(t, int64(0), all.Total)
+
+			res, err = req.Delete(ts.URL+"/api/v1/scaninfos/"+id, headers)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+
+			res, err = req.Post(ts.URL+"/api/v1/scaninfos", headers, req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+		})
+	})
+}
+
+func TestRoleBasedAccessControl(t *testing.T) {
+	ts, id, auth := newTestServer(config.NewLive(&config.Config{}), nil)
+	defer ts.Close()
+
+	ctx := context.Background()
+	keys := map[string]string{}
+	for _, role := range []string{domain.RoleViewer, domain.RoleSubmitter, domain.RoleMaintainer} {
+		key, _, err := auth.CreateAPIKey(ctx, role, testScanInfos.CompanyID, nil, []string{role})
+		assert.NoError(t, err)
+		keys[role] = key
+	}
+	adminKey, _, err := auth.CreateAPIKey(ctx, "admin", "1", nil, []string{domain.RoleAdmin})
+	assert.NoError(t, err)
+	keys[domain.RoleAdmin] = adminKey
+
+	forbidden := func(t *testing.T, res *req.Resp, perm domain.Permission) {
+		assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+		var body problemResponse
+		assert.NoError(t, res.ToJSON(&body))
+		assert.Equal(t, "missing permission: "+string(perm), body.Detail)
+		assert.Equal(t, codeForbidden, body.Code)
+	}
+
+	t.Run("Role based access control tests", func(t *testing.T) {
+		t.Run("viewer: reads but cannot submit", func(t *testing.T) {
+			headers := req.Header{"X-API-Key": keys[domain.RoleViewer]}
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, headers)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			res, err = req.Post(ts.URL+"/api/v1/scaninfos", headers, req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			forbidden(t, res, domain.PermissionCreateScans)
+
+			for _, path := range []string{"/api/v1/scaninfos:batch", "/api/v1/scaninfosbatch", "/api/v1/scaninfos:purge"} {
+				res, err = req.Post(ts.URL+path, headers, req.BodyJSON(&[]domain.StoreScanInfosRequest{testStoreScanInfosRequest}))
+				assert.NoError(t, err)
+				if path == "/api/v1/scaninfos:batch" {
+					forbidden(t, res, domain.PermissionCreateScans)
+				} else {
+					assert.Equal(t, http.StatusNotFound, res.Response().StatusCode, path)
+				}
+			}
+		})
+
+		t.Run("submitter: submits but cannot read", func(t *testing.T) {
+			headers := req.Header{"X-API-Key": keys[domain.RoleSubmitter]}
+			res, err := req.Post(ts.URL+"/api/v2/scaninfos", headers, req.BodyJSON(&testStoreScanInfosV2Request))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			res, err = req.Get(ts.URL+"/api/v1/scaninfos", headers)
+			assert.NoError(t, err)
+			forbidden(t, res, domain.PermissionReadScans)
+
+			res, err = req.Delete(ts.URL+"/api/v1/scaninfos/"+id, headers)
+			assert.NoError(t, err)
+			forbidden(t, res, domain.PermissionDeleteScans)
+		})
+
+		t.Run("admin: reads the scans of all companies", func(t *testing.T) {
+			headers := req.Header{"X-API-Key": keys[domain.RoleAdmin]}
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, headers)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			res, err = req.Get(ts.URL+"/api/v1/scaninfos?company_id="+testScanInfos.CompanyID, headers)
+			assert.NoError(t, err)
+			var all getAllScanInfosResponse
+			assert.NoError(t, res.ToJSON(&all))
+			assert.Equal(t, int64(2), all.Total)
+		})
+
+		t.Run("maintainer: deletes within its company", func(t *testing.T) {
+			res, err := req.Delete(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"X-API-Key": keys[domain.RoleMaintainer]})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+	})
+}
+
+// newTestCertificate issues a certificate for commonName signed by the parent
+// certificate, or a self-signed CA certificate when parent is nil.
+func newTestCertificate(t *testing.T, commonName string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	issuer, signer := template, interface{}(key)
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, signer)
+	assert.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestMutualTLSAuthentication(t *testing.T) {
+	ca := newTestCertificate(t, "clients-ca", nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	configData := &config.Config{}
+	configData.Auth.Disabled = true
+	configData.Server.MTLS.Enabled = true
+	configData.Server.MTLS.Identity = "common_name"
+	router, id, _ := newTestRouter(config.NewLive(configData), nil)
+	ts := httptest.NewUnstartedServer(router)
+	ts.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := func(certs ...tls.Certificate) *req.Req {
+		transport := ts.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = certs
+		r := req.New()
+		r.SetClient(&http.Client{Transport: transport})
+		return r
+	}
+	anonymous := client()
+	scanner := client(newTestCertificate(t, testStoreScanInfosRequest.ClientID, &ca))
+	other := client(newTestCertificate(t, "another-client", &ca))
+
+	t.Run("Mutual TLS tests", func(t *testing.T) {
+		t.Run("should pass: certificate matching the client_id", func(t *testing.T) {
+			res, err := scanner.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+
+		t.Run("should pass: certificate optional outside v1", func(t *testing.T) {
+			res, err := anonymous.Get(ts.URL + "/api/v2/scaninfos/" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: no certificate on v1", func(t *testing.T) {
+			res, err := anonymous.Get(ts.URL + "/api/v1/scaninfos/" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: no certificate on v2 submissions", func(t *testing.T) {
+			res, err := anonymous.Post(ts.URL+"/api/v2/scaninfos", req.BodyJSON(&testStoreScanInfosV2Request))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode)
+
+			res, err = scanner.Post(ts.URL+"/api/v2/scaninfos", req.BodyJSON(&testStoreScanInfosV2Request))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: certificate of another client", func(t *testing.T) {
+			res, err := other.Get(ts.URL + "/api/v1/scaninfos/" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			res, err = other.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+
+			res, err = other.Post(ts.URL+"/api/v2/scaninfos", req.BodyJSON(&testStoreScanInfosV2Request))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+		})
+	})
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	jwksData, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "test-key",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(privateKey.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.E)).Bytes()),
+	}}})
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(jwksFile, jwksData, 0o600))
+
+	keySet, err := jwks.Load(testLogger, jwks.Config{JWKSFile: jwksFile})
+	assert.NoError(t, err)
+
+	configData := &config.Config{}
+	configData.Auth.JWT.Issuer = "https://idp.example.com"
+	configData.Auth.JWT.Audience = "backend-api"
+	configData.Auth.JWT.CompanyClaim = "company"
+	configData.Auth.JWT.ClientIDClaim = "client_id"
+	configData.Auth.JWT.RolesClaim = "roles"
+	ts, id, _ := newTestServer(config.NewLive(configData), keySet)
+	defer ts.Close()
+
+	sign := func(edit func(jwt.MapClaims)) req.Header {
+		claims := jwt.MapClaims{
+			"iss":       configData.Auth.JWT.Issuer,
+			"aud":       configData.Auth.JWT.Audience,
+			"sub":       "jeamon",
+			"exp":       time.Now().Add(time.Hour).Unix(),
+			"nbf":       time.Now().Add(-time.Minute).Unix(),
+			"company":   testScanInfos.CompanyID,
+			"client_id": testScanInfos.ClientID,
+			"scope":     "read write",
+		}
+		edit(claims)
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test-key"
+		signed, err := token.SignedString(privateKey)
+		assert.NoError(t, err)
+		return req.Header{"Authorization": "Bearer " + signed}
+	}
+
+	t.Run("JWT authentication tests", func(t *testing.T) {
+		t.Run("should pass: valid token", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, sign(func(jwt.MapClaims) {}))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: invalid claims", func(t *testing.T) {
+			for name, edit := range map[string]func(jwt.MapClaims){
+				"expired":         func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+				"no expiration":   func(c jwt.MapClaims) { delete(c, "exp") },
+				"not valid yet":   func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+				"wrong issuer":    func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+				"no issuer":       func(c jwt.MapClaims) { delete(c, "iss") },
+				"wrong audience":  func(c jwt.MapClaims) { c["aud"] = []string{"another-api"} },
+				"no audience":     func(c jwt.MapClaims) { delete(c, "aud") },
+				"missing company": func(c jwt.MapClaims) { delete(c, "company") },
+			} {
+				res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, sign(edit))
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode, name)
+			}
+		})
+
+		t.Run("should fail: token signed by an unknown key", func(t *testing.T) {
+			otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			assert.NoError(t, err)
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+				"exp":     time.Now().Add(time.Hour).Unix(),
+				"company": testScanInfos.CompanyID,
+			})
+			token.Header["kid"] = "test-key"
+			signed, err := token.SignedString(otherKey)
+			assert.NoError(t, err)
+
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"Authorization": "Bearer " + signed})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode)
+		})
+
+		t.Run("should pass: submission by the client of the token", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testStoreScanInfosRequest), sign(func(jwt.MapClaims) {}))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: submission for another client", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testStoreScanInfosRequest), sign(func(c jwt.MapClaims) { c["client_id"] = "another-client" }))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+
+			res, err = req.Post(ts.URL+"/api/v2/scaninfos", req.BodyJSON(&testStoreScanInfosV2Request), sign(func(c jwt.MapClaims) { c["client_id"] = "another-client" }))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: missing scope or another company", func(t *testing.T) {
+			res, err := req.Delete(ts.URL+"/api/v1/scaninfos/"+id, sign(func(jwt.MapClaims) {}))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusForbidden, res.Response().StatusCode)
+
+			res, err = req.Get(ts.URL+"/api/v1/scaninfos/"+id, sign(func(c jwt.MapClaims) { c["company"] = "1" }))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+		})
+	})
+}
+
+func TestRateLimiting(t *testing.T) {
+	configData := &config.Config{}
+	configData.Auth.Disabled = true
+	configData.RateLimit.Enabled = true
+	configData.RateLimit.Key = "ip"
+	configData.RateLimit.RequestsPerSecond = 0.01
+	configData.RateLimit.Burst = 2
+	settings := config.NewLive(configData)
+	ts, id, _ := newTestServer(settings, nil)
+	defer ts.Close()
+
+	t.Run("Rate limiting tests", func(t *testing.T) {
+		t.Run("should pass: calls within the burst", func(t *testing.T) {
+			for remaining := 1; remaining >= 0; remaining-- {
+				res, err := req.Get(ts.URL + "/api/v1/scaninfos/" + id)
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+				assert.Equal(t, "2", res.Response().Header.Get("RateLimit-Limit"))
+				assert.Equal(t, strconv.Itoa(remaining), res.Response().Header.Get("RateLimit-Remaining"))
+			}
+		})
+
+		t.Run("should fail: calls over the burst", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusTooManyRequests, res.Response().StatusCode)
+			retryAfter, err := strconv.Atoi(res.Response().Header.Get("Retry-After"))
+			assert.NoError(t, err)
+			assert.InDelta(t, 100, retryAfter, 1)
+			assert.Equal(t, "0", res.Response().Header.Get("RateLimit-Remaining"))
+		})
+
+		t.Run("should pass: configuration changes apply live", func(t *testing.T) {
+			reloaded := *configData
+			reloaded.RateLimit.RequestsPerSecond = 1000
+			settings.Store(&reloaded)
+			res, err := req.Get(ts.URL + "/api/v1/scaninfos/" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusTooManyRequests, res.Response().StatusCode)
+			assert.Equal(t, "1", res.Response().Header.Get("Retry-After"))
+
+			time.Sleep(10 * time.Millisecond)
+			res, err = req.Get(ts.URL + "/api/v1/scaninfos/" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+	})
+
+	t.Run("Rate limiting before authentication tests", func(t *testing.T) {
+		newServer := func(key string) (*httptest.Server, string) {
+			configData := &config.Config{}
+			configData.RateLimit.Enabled = true
+			configData.RateLimit.Key = key
+			configData.RateLimit.RequestsPerSecond = 0.01
+			configData.RateLimit.Burst = 2
+			ts, _, auth := newTestServer(config.NewLive(configData), nil)
+			apiKey, _, err := auth.CreateAPIKey(context.Background(), "viewer", testScanInfos.CompanyID, nil, []string{domain.RoleViewer})
+			assert.NoError(t, err)
+			return ts, apiKey
+		}
+
+		t.Run("should fail: calls without credentials over the ip burst", func(t *testing.T) {
+			ts, _ := newServer("ip")
+			defer ts.Close()
+
+			for i := 0; i < 2; i++ {
+				res, err := req.Get(ts.URL + "/api/v1/scaninfos")
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode)
+			}
+			res, err := req.Get(ts.URL + "/api/v1/scaninfos")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusTooManyRequests, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Response().Header.Get("Retry-After"))
+		})
+
+		t.Run("should fail: invalid api keys charged to the ip", func(t *testing.T) {
+			ts, apiKey := newServer("api_key")
+			defer ts.Close()
+
+			// valid calls are charged to the api key only.
+			for i := 0; i < 2; i++ {
+				res, err := req.Get(ts.URL+"/api/v1/scaninfos", req.Header{"X-API-Key": apiKey})
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			}
+
+			invalid := req.Header{"X-API-Key": application.APIKeyPrefix + "invalid"}
+			for i := 0; i < 2; i++ {
+				res, err := req.Get(ts.URL+"/api/v1/scaninfos", invalid)
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusUnauthorized, res.Response().StatusCode)
+			}
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos", invalid)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusTooManyRequests, res.Response().StatusCode)
+			var errRes problemResponse
+			assert.NoError(t, res.ToJSON(&errRes))
+			assert.Equal(t, codeRateLimited, errRes.Code)
+		})
+	})
+}
+
+func TestDailyQuota(t *testing.T) {
+	configData := &config.Config{}
+	configData.Auth.Disabled = true
+	configData.Quota.DailySubmissions = 10
+	configData.Quota.Companies = []config.CompanyQuota{{CompanyID: testStoreScanInfosRequest.CompanyID, DailySubmissions: 2}}
+	ts, _, _ := newTestServer(config.NewLive(configData), nil)
+	defer ts.Close()
+
+	t.Run("Daily quota tests", func(t *testing.T) {
+		t.Run("should pass: submissions within the quota", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: batch over the quota", func(t *testing.T) {
+			batch := []domain.StoreScanInfosRequest{testStoreScanInfosRequest, testStoreScanInfosRequest}
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:batch?mode="+domain.BatchModeBestEffort, req.BodyJSON(&batch))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusTooManyRequests, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Response().Header.Get("Retry-After"))
+		})
+
+		t.Run("should fail: submissions over the quota", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v2/scaninfos", req.BodyJSON(&testStoreScanInfosV2Request))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			res, err = req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusTooManyRequests, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Response().Header.Get("Retry-After"))
+		})
+
+		t.Run("should pass: usages listed by the admin endpoint", func(t *testing.T) {
+			res, err := req.Get(ts.URL + "/api/v1/admin/quotas")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			var usages quotaUsagesResponse
+			assert.NoError(t, res.ToJSON(&usages))
+			assert.Equal(t, time.Now().UTC().Format(domain.QuotaDayLayout), usages.Day)
+			assert.Equal(t, []domain.QuotaUsage{{
+				CompanyID: testStoreScanInfosRequest.CompanyID,
+				Day:       usages.Day,
+				Count:     2,
+				Limit:     2,
+			}}, usages.Usages)
+
+			res, err = req.Get(ts.URL + "/api/v1/admin/quotas?day=yesterday")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, res.Response().StatusCode)
+		})
+	})
+}
+
+// TestSettingsReload reloads the settings while requests read them. Run it
+// with -race to catch the settings shared without synchronization.
+func TestSettingsReload(t *testing.T) {
+	configData := &config.Config{}
+	configData.Auth.Disabled = true
+	configData.RateLimit.Enabled = true
+	configData.RateLimit.Key = "ip"
+	configData.RateLimit.RequestsPerSecond = 1000
+	configData.RateLimit.Burst = 1000
+	settings := config.NewLive(configData)
+	router, _, _ := newTestRouter(settings, nil)
+	body, err := json.Marshal(testStoreScanInfosRequest)
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	var reloads sync.WaitGroup
+	reloads.Add(1)
+	go func() {
+		defer reloads.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			reloaded := *configData
+			reloaded.Quota.DailySubmissions = int64(1000 + i)
+			reloaded.Quota.Companies = []config.CompanyQuota{{CompanyID: testStoreScanInfosRequest.CompanyID, DailySubmissions: int64(1000 + i)}}
+			reloaded.Validation.MaxResults = 10 + i%10
+			settings.Store(&reloaded)
+		}
+	}()
+
+	var requests sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		requests.Add(1)
+		go func() {
+			defer requests.Done()
+			for j := 0; j < 20; j++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/scaninfos", strings.NewReader(string(body))))
+				assert.Equal(t, http.StatusOK, w.Code)
+			}
+		}()
+	}
+
+	requests.Wait()
+	close(done)
+	reloads.Wait()
 }
 
 func TestGetScanInfosHandler(t *testing.T) {
-	ts := setupTestServer()
+	ts, id := setupTestServer()
 	defer ts.Close()
 
 	t.Run("GetScanInfos endpoint tests", func(t *testing.T) {
 		t.Run("should pass: valid param <id> value", func(t *testing.T) {
-			res, err := req.Get(ts.URL + "/api/v1/scaninfos/" + testScanInfosID)
+			res, err := req.Get(ts.URL + "/api/v1/scaninfos/" + id)
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
 			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Equal(t, `"1"`, res.Response().Header.Get("ETag"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: unknown param <id> value", func(t *testing.T) {
+			res, err := req.Get(ts.URL + "/api/v1/scaninfos/" + testUnknownScanInfosID)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
 
@@ -87,14 +928,108 @@ func TestGetScanInfosHandler(t *testing.T) {
 			res, err := req.Get(ts.URL + "/api/v1/scaninfos/7aec1a3e")
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
-			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
 	})
 }
 
+func TestStoreScanInfosIdempotency(t *testing.T) {
+	ts, _ := setupTestServer()
+	defer ts.Close()
+
+	t.Run("StoreScanInfos idempotency tests", func(t *testing.T) {
+		key := req.Header{"Idempotency-Key": "store-scan-1"}
+		var original genericResponse
+
+		t.Run("should pass: first request with a key", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", key, req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.Empty(t, res.Response().Header.Get("Idempotent-Replayed"))
+			assert.NoError(t, res.ToJSON(&original))
+			assert.NotEmpty(t, original.ScanInfosID)
+		})
+
+		t.Run("should pass: retry replays the original response", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", key, req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.Equal(t, "true", res.Response().Header.Get("Idempotent-Replayed"))
+
+			var replayed genericResponse
+			assert.NoError(t, res.ToJSON(&replayed))
+			assert.Equal(t, original, replayed)
+
+			list, err := req.Get(ts.URL + "/api/v1/scaninfos")
+			assert.NoError(t, err)
+			var all getAllScanInfosResponse
+			assert.NoError(t, list.ToJSON(&all))
+			assert.Equal(t, int64(2), all.Total)
+		})
+
+		t.Run("should fail: key reused with another body", func(t *testing.T) {
+			body := testStoreScanInfosRequest
+			body.TagID = "v2.0.0"
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", key, req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should pass: failed request does not hold the key", func(t *testing.T) {
+			retry := req.Header{"Idempotency-Key": "store-scan-2"}
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", retry, req.BodyJSON(&domain.StoreScanInfosRequest{}))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+
+			res, err = req.Post(ts.URL+"/api/v1/scaninfos", retry, req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.Empty(t, res.Response().Header.Get("Idempotent-Replayed"))
+		})
+
+		t.Run("should fail: body over the size limit", func(t *testing.T) {
+			body := testStoreScanInfosRequest
+			body.TagID = strings.Repeat("v", maxIdempotentBodySize)
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", req.Header{"Idempotency-Key": "store-scan-3"}, req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			var errRes problemResponse
+			assert.NoError(t, res.ToJSON(&errRes))
+			assert.Equal(t, codeBadRequest, errRes.Code)
+		})
+
+		t.Run("should fail: body read error does not hold the key", func(t *testing.T) {
+			configData := &config.Config{}
+			configData.Auth.Disabled = true
+			router, _, _ := newTestRouter(config.NewLive(configData), nil)
+			payload, err := json.Marshal(testStoreScanInfosRequest)
+			assert.NoError(t, err)
+
+			// the body read before the failure is a complete request.
+			failed := io.MultiReader(bytes.NewReader(payload), iotest.ErrReader(errors.New("connection reset")))
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/scaninfos", failed)
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Idempotency-Key", "store-scan-4")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, r)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, problemContentType, rec.Header().Get("Content-Type"))
+
+			r = httptest.NewRequest(http.MethodPost, "/api/v1/scaninfos", bytes.NewReader(payload))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Idempotency-Key", "store-scan-4")
+			rec = httptest.NewRecorder()
+			router.ServeHTTP(rec, r)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+		})
+	})
+}
+
 func TestGetAllScanInfosHandler(t *testing.T) {
-	ts := setupTestServer()
+	ts, _ := setupTestServer()
 	defer ts.Close()
 
 	t.Run("GetAllScanInfos endpoint tests", func(t *testing.T) {
@@ -113,11 +1048,61 @@ func TestGetAllScanInfosHandler(t *testing.T) {
 			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
+
+		t.Run("should pass: valid filters, sort and limit", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos", req.QueryParam{
+				"company_id":   "0",
+				"started_from": 1655903720,
+				"has_error":    true,
+				"sort":         "-started_at",
+				"limit":        10,
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should pass: pages through stored scan infos", func(t *testing.T) {
+			_, err := req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+
+			var page getAllScanInfosResponse
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos", req.QueryParam{"limit": 1})
+			assert.NoError(t, err)
+			assert.NoError(t, res.ToJSON(&page))
+			assert.Equal(t, int64(2), page.Total)
+			assert.Len(t, page.Infos, 1)
+			assert.NotEmpty(t, page.NextCursor)
+
+			var next getAllScanInfosResponse
+			res, err = req.Get(ts.URL+"/api/v1/scaninfos", req.QueryParam{"limit": 1, "cursor": page.NextCursor})
+			assert.NoError(t, err)
+			assert.NoError(t, res.ToJSON(&next))
+			assert.Len(t, next.Infos, 1)
+			assert.NotEqual(t, page.Infos[0].ID, next.Infos[0].ID)
+			assert.Empty(t, next.NextCursor)
+		})
+
+		t.Run("should fail: invalid query parameters", func(t *testing.T) {
+			for _, params := range []req.QueryParam{
+				{"limit": 1000},
+				{"limit": "ten"},
+				{"sort": "username"},
+				{"cursor": "not-a-cursor"},
+			} {
+				res, err := req.Get(ts.URL+"/api/v1/scaninfos", params)
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode, params)
+				assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+				assert.NotEmpty(t, res.Bytes())
+			}
+		})
 	})
 }
 
 func TestStoreScanInfosHandler(t *testing.T) {
-	ts := setupTestServer()
+	ts, _ := setupTestServer()
 	defer ts.Close()
 
 	t.Run("StoreScanInfos endpoint tests", func(t *testing.T) {
@@ -133,7 +1118,7 @@ func TestStoreScanInfosHandler(t *testing.T) {
 			res, err := req.Post(ts.URL + "/api/v1/scaninfos")
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
-			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
 
@@ -141,30 +1126,204 @@ func TestStoreScanInfosHandler(t *testing.T) {
 			res, err := req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&domain.ScanInfos{}))
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
-			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: inconsistent scan infos", func(t *testing.T) {
+			body := testStoreScanInfosRequest
+			body.CommitID = "main"
+			body.CompletedAt = body.StartedAt - 10
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, res.Response().StatusCode)
+
+			var errRes problemResponse
+			assert.NoError(t, res.ToJSON(&errRes))
+			assert.Equal(t, codeValidationFailed, errRes.Code)
+			assert.Equal(t, domain.ValidationErrors{
+				{Field: "commit_id", Message: "must be a hexadecimal commit hash of 7 to 64 characters"},
+				{Field: "completed_at", Message: "must not be before started_at"},
+			}, errRes.Errors)
+		})
+	})
+}
+
+func TestBatchStoreScanInfosHandler(t *testing.T) {
+	ts, _ := setupTestServer()
+	defer ts.Close()
+
+	invalid := testStoreScanInfosRequest
+	invalid.CommitID = ""
+
+	t.Run("BatchStoreScanInfos endpoint tests", func(t *testing.T) {
+		t.Run("should pass: json array in atomic mode", func(t *testing.T) {
+			body := []domain.StoreScanInfosRequest{testStoreScanInfosRequest, testStoreScanInfosRequest}
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:batch", req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			var batch batchScanInfosResponse
+			assert.NoError(t, res.ToJSON(&batch))
+			assert.Equal(t, domain.BatchModeAtomic, batch.Mode)
+			assert.Equal(t, 2, batch.Stored)
+			assert.Len(t, batch.Results, 2)
+			assert.NotEmpty(t, batch.Results[1].ScanInfosID)
+		})
+
+		t.Run("should pass: ndjson in best effort mode", func(t *testing.T) {
+			body := `{"company_id":"0","username":"jeamon","client_id":"v1.0.0","repository_url":"https://github.com/jeamon/backend-api","commit_id":"d7b8ff1","tag_id":"v1.0.0","results":[],"started_at":1,"completed_at":2,"sent_at":3,"metadata":{}}
+
+{"company_id":"0"}
+not json
+`
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:batch?mode=best_effort", req.Header{"Content-Type": "application/x-ndjson"}, body)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusMultiStatus, res.Response().StatusCode)
+
+			var batch batchScanInfosResponse
+			assert.NoError(t, res.ToJSON(&batch))
+			assert.Equal(t, 1, batch.Stored)
+			assert.Equal(t, 2, batch.Failed)
+			assert.NotEmpty(t, batch.Results[0].ScanInfosID)
+			assert.NotEmpty(t, batch.Results[1].Error)
+			assert.Equal(t, 2, batch.Results[2].Index)
+			assert.NotEmpty(t, batch.Results[2].Error)
+		})
+
+		t.Run("should fail: invalid item in atomic mode", func(t *testing.T) {
+			body := []domain.StoreScanInfosRequest{testStoreScanInfosRequest, invalid}
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:batch", req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+
+			var errRes problemResponse
+			assert.NoError(t, res.ToJSON(&errRes))
+			assert.Equal(t, codeValidationFailed, errRes.Code)
+			assert.Equal(t, "1 out of 2 batch items are invalid", errRes.Detail)
+			assert.Equal(t, domain.ValidationErrors{
+				{Field: "[1].commit_id", Message: "is required"},
+			}, errRes.Errors)
+		})
+
+		t.Run("should fail: undecodable item in atomic mode", func(t *testing.T) {
+			body := `{"company_id":"0","username":"jeamon","client_id":"v1.0.0","repository_url":"https://github.com/jeamon/backend-api","commit_id":"d7b8ff1","tag_id":"v1.0.0","results":[],"started_at":1,"completed_at":2,"sent_at":3,"metadata":{}}
+not json
+`
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:batch", req.Header{"Content-Type": "application/x-ndjson"}, body)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, res.Response().StatusCode)
+
+			var errRes problemResponse
+			assert.NoError(t, res.ToJSON(&errRes))
+			assert.Equal(t, codeValidationFailed, errRes.Code)
+			assert.Len(t, errRes.Errors, 1)
+			assert.Equal(t, "[1]", errRes.Errors[0].Field)
+		})
+
+		t.Run("should fail: unknown batch mode", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:batch?mode=partial", req.BodyJSON(&[]domain.StoreScanInfosRequest{testStoreScanInfosRequest}))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: empty batch", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:batch", req.BodyJSON(&[]domain.StoreScanInfosRequest{}))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: json array over the batch size", func(t *testing.T) {
+			item, err := json.Marshal(testStoreScanInfosRequest)
+			assert.NoError(t, err)
+			items := make([]string, domain.MaxBatchSize+1)
+			for i := range items {
+				items[i] = string(item)
+			}
+			// the items past the limit are not read, so the invalid tail is not reported.
+			body := "[" + strings.Join(items, ",") + ", {oops"
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:batch", req.Header{"Content-Type": "application/json"}, body)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			var errRes problemResponse
+			assert.NoError(t, res.ToJSON(&errRes))
+			assert.Equal(t, fmt.Sprintf("batch too large. expect at most %d scan infos", domain.MaxBatchSize), errRes.Detail)
+
+			res, err = req.Post(ts.URL+"/api/v1/scaninfos:batch", req.Header{"Content-Type": "application/json"}, `{"company_id":"0"}`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+		})
+
+		t.Run("should fail: unknown action", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v1/scaninfos:purge", req.BodyJSON(&[]domain.StoreScanInfosRequest{testStoreScanInfosRequest}))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
 			assert.NotEmpty(t, res.Bytes())
 		})
 	})
 }
 
 func TestUpdateScanInfosHandler(t *testing.T) {
-	ts := setupTestServer()
+	ts, id := setupTestServer()
 	defer ts.Close()
 
 	t.Run("UpdateScanInfos endpoint tests", func(t *testing.T) {
 		t.Run("should pass: valid request body", func(t *testing.T) {
-			res, err := req.Put(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&testScanInfos))
+			body := testScanInfos
+			body.ID = id
+			res, err := req.Put(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&body))
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
 			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
 
+		t.Run("should pass: matching If-Match version", func(t *testing.T) {
+			body := testScanInfos
+			body.ID = id
+			res, err := req.Put(ts.URL+"/api/v1/scaninfos", req.Header{"If-Match": `"2"`}, req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: outdated If-Match version", func(t *testing.T) {
+			body := testScanInfos
+			body.ID = id
+			res, err := req.Put(ts.URL+"/api/v1/scaninfos", req.Header{"If-Match": `"2"`}, req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusPreconditionFailed, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: invalid If-Match value", func(t *testing.T) {
+			body := testScanInfos
+			body.ID = id
+			res, err := req.Put(ts.URL+"/api/v1/scaninfos", req.Header{"If-Match": "v3"}, req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusPreconditionFailed, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: unknown scan infos id", func(t *testing.T) {
+			body := testScanInfos
+			body.ID = testUnknownScanInfosID
+			res, err := req.Put(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
 		t.Run("should fail: request with empty body", func(t *testing.T) {
 			res, err := req.Put(ts.URL + "/api/v1/scaninfos")
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
-			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
 
@@ -172,38 +1331,235 @@ func TestUpdateScanInfosHandler(t *testing.T) {
 			res, err := req.Put(ts.URL+"/api/v1/scaninfos", req.BodyJSON(&domain.StoreScanInfosRequest{}))
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
-			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+	})
+}
+
+func TestPatchScanInfosHandler(t *testing.T) {
+	ts, id := setupTestServer()
+	defer ts.Close()
+
+	mergePatch := req.Header{"Content-Type": domain.MergePatchType}
+	jsonPatch := req.Header{"Content-Type": domain.JSONPatchType}
+
+	t.Run("PatchScanInfos endpoint tests", func(t *testing.T) {
+		t.Run("should pass: merge patch on nested metadata", func(t *testing.T) {
+			res, err := req.Patch(ts.URL+"/api/v1/scaninfos/"+id, mergePatch, `{"username":"someone","metadata":{"arch":null,"build":{"cores":4}}}`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.Equal(t, `"2"`, res.Response().Header.Get("ETag"))
+
+			var infos domain.ScanInfos
+			assert.NoError(t, res.ToJSON(&infos))
+			assert.Equal(t, "someone", infos.Username)
+			assert.Equal(t, testScanInfos.CommitID, infos.CommitID)
+			assert.NotContains(t, infos.Metadata, "arch")
+			assert.Equal(t, map[string]interface{}{"cores": float64(4)}, infos.Metadata["build"])
+		})
+
+		t.Run("should pass: json patch with matching If-Match version", func(t *testing.T) {
+			headers := req.Header{"Content-Type": domain.JSONPatchType, "If-Match": `"2"`}
+			res, err := req.Patch(ts.URL+"/api/v1/scaninfos/"+id, headers, `[{"op":"replace","path":"/metadata/build/cores","value":8},{"op":"add","path":"/results/-","value":"found something else"}]`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+
+			res, err = req.Get(ts.URL + "/api/v1/scaninfos/" + id)
+			assert.NoError(t, err)
+			var infos domain.ScanInfos
+			assert.NoError(t, res.ToJSON(&infos))
+			assert.Equal(t, int64(3), infos.Version)
+			assert.Equal(t, map[string]interface{}{"cores": float64(8)}, infos.Metadata["build"])
+			assert.Equal(t, []string{"found something", "found something else"}, infos.Results)
+		})
+
+		t.Run("should fail: outdated If-Match version", func(t *testing.T) {
+			headers := req.Header{"Content-Type": domain.MergePatchType, "If-Match": `"2"`}
+			res, err := req.Patch(ts.URL+"/api/v1/scaninfos/"+id, headers, `{"username":"jeamon"}`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusPreconditionFailed, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: failed json patch test operation", func(t *testing.T) {
+			res, err := req.Patch(ts.URL+"/api/v1/scaninfos/"+id, jsonPatch, `[{"op":"test","path":"/username","value":"nobody"}]`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusConflict, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: patched document is invalid", func(t *testing.T) {
+			res, err := req.Patch(ts.URL+"/api/v1/scaninfos/"+id, mergePatch, `{"commit_id":null}`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: patch modifies the id", func(t *testing.T) {
+			res, err := req.Patch(ts.URL+"/api/v1/scaninfos/"+id, jsonPatch, `[{"op":"replace","path":"/id","value":"`+testUnknownScanInfosID+`"}]`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: unsupported content type", func(t *testing.T) {
+			res, err := req.Patch(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"Content-Type": "application/json"}, `{"username":"jeamon"}`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusUnsupportedMediaType, res.Response().StatusCode)
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: unknown param <id> value", func(t *testing.T) {
+			res, err := req.Patch(ts.URL+"/api/v1/scaninfos/"+testUnknownScanInfosID, mergePatch, `{"username":"jeamon"}`)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
 			assert.NotEmpty(t, res.Bytes())
 		})
 	})
 }
 
 func TestDeleteScanInfosHandler(t *testing.T) {
-	ts := setupTestServer()
+	ts, id := setupTestServer()
 	defer ts.Close()
 
 	t.Run("DeleteScanInfos endpoint tests", func(t *testing.T) {
+		t.Run("should fail: outdated If-Match version", func(t *testing.T) {
+			res, err := req.Delete(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"If-Match": `"2"`})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusPreconditionFailed, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
 		t.Run("should pass: valid param <id> string", func(t *testing.T) {
-			res, err := req.Delete(ts.URL + "/api/v1/scaninfos/" + testScanInfosID)
+			res, err := req.Delete(ts.URL+"/api/v1/scaninfos/"+id, req.Header{"If-Match": `W/"1"`})
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
 			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
 
+		t.Run("should fail: already deleted param <id> value", func(t *testing.T) {
+			res, err := req.Delete(ts.URL + "/api/v1/scaninfos/" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
 		t.Run("should fail: invalid param <id> value", func(t *testing.T) {
 			res, err := req.Delete(ts.URL + "/api/v1/scaninfos/7aec1a3e")
 			assert.NoError(t, err)
 			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
-			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
 
 		t.Run("should fail: no param <id> value", func(t *testing.T) {
 			res, err := req.Delete(ts.URL + "/api/v1/scaninfos/")
 			assert.NoError(t, err)
-			assert.Equal(t, http.StatusNotImplemented, res.Response().StatusCode)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+	})
+}
+
+func TestStoreScanInfosV2Handler(t *testing.T) {
+	ts, _ := setupTestServer()
+	defer ts.Close()
+
+	t.Run("StoreScanInfosV2 endpoint tests", func(t *testing.T) {
+		t.Run("should pass: valid request body", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v2/scaninfos", req.BodyJSON(&testStoreScanInfosV2Request))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: request with v1 body", func(t *testing.T) {
+			res, err := req.Post(ts.URL+"/api/v2/scaninfos", req.BodyJSON(&testStoreScanInfosRequest))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: finding with invalid severity", func(t *testing.T) {
+			body := testStoreScanInfosV2Request
+			body.Findings = []domain.Finding{{RuleID: "G101", Severity: "urgent", Title: "hardcoded credentials"}}
+			res, err := req.Post(ts.URL+"/api/v2/scaninfos", req.BodyJSON(&body))
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+	})
+}
+
+func TestGetScanInfosV2Handler(t *testing.T) {
+	ts, id := setupTestServer()
+	defer ts.Close()
+
+	t.Run("GetScanInfosV2 endpoint tests", func(t *testing.T) {
+		t.Run("should pass: valid param <id> value", func(t *testing.T) {
+			res, err := req.Get(ts.URL + "/api/v2/scaninfos/" + id)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
 			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Contains(t, res.String(), `"findings":[]`)
+		})
+
+		t.Run("should fail: invalid param <id> value", func(t *testing.T) {
+			res, err := req.Get(ts.URL + "/api/v2/scaninfos/7aec1a3e")
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+	})
+}
+
+func TestDiffScanInfosHandler(t *testing.T) {
+	ts, id := setupTestServer()
+	defer ts.Close()
+
+	t.Run("DiffScanInfos endpoint tests", func(t *testing.T) {
+		t.Run("should pass: valid param <id> and <base> values", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id+"/diff", req.QueryParam{"base": id})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.Response().StatusCode)
+			assert.Equal(t, "application/json; charset=utf-8", res.Response().Header.Get("Content-Type"))
+			assert.Contains(t, res.String(), `"new":[]`)
+		})
+
+		t.Run("should fail: invalid param <base> value", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/"+id+"/diff", req.QueryParam{"base": "7aec1a3e"})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: no scan infos at refs", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/diff", req.QueryParam{
+				"repository_url": testScanInfos.RepositoryURL,
+				"base":           "v1.0.0",
+				"head":           "v1.1.0",
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusNotFound, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Bytes())
+		})
+
+		t.Run("should fail: missing refs query parameters", func(t *testing.T) {
+			res, err := req.Get(ts.URL+"/api/v1/scaninfos/diff", req.QueryParam{"repository_url": testScanInfos.RepositoryURL})
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, res.Response().StatusCode)
+			assert.Equal(t, problemContentType, res.Response().Header.Get("Content-Type"))
 			assert.NotEmpty(t, res.Bytes())
 		})
 	})
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"go.uber.org/zap"
+)
+
+// StoreScanInfosV2Handler ...
+// @Summary store a scan details with structured findings
+// @Description save a scan information carrying structured findings and return its id
+// @Tags ScanInfos
+// @Accept  json
+// @Produce  json
+// @Param scanInfosStore body domain.StoreScanInfosV2Request true "Store Scan Infos"
+// @Success 200 {object} genericResponse
+// @Failure 400 {object} problemResponse
+// @Failure 500 {object} problemResponse
+// @Router /api/v2/scaninfos [post]
+func (w *ScanInfosService) StoreScanInfosV2Handler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		var req domain.StoreScanInfosV2Request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			w.logger.Error("unable to bind store v2 request input", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, err.Error())
+			return
+		}
+
+		id, err := w.application.StoreV2(c.Request.Context(), req)
+		if err != nil {
+			w.logger.Error("unable to store scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			setRetryAfter(c, err)
+			writeError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, genericResponse{
+			RequestID:   c.GetString("x-requestid"),
+			Message:     "scan infos saved successfully",
+			ScanInfosID: id,
+		})
+	}
+}
+
+// GetScanInfosV2Handler ...
+// @Summary get a scan infos with structured findings
+// @Description get a scan information and its findings by its id
+// @Tags ScanInfos
+// @Accept  json
+// @Produce  json
+// @Param id path string true "ID string"
+// @Success 200 {object} scanInfosV2Response
+// @Failure 400 {object} problemResponse
+// @Failure 404 {object} problemResponse
+// @Failure 500 {object} problemResponse
+// @Router /api/v2/scaninfos/{id} [get]
+func (w *ScanInfosService) GetScanInfosV2Handler() func(*gin.Context) {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if _, err := uuid.FromString(id); err != nil {
+			w.logger.Error("bad request. invalid id", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeProblem(c, http.StatusBadRequest, codeBadRequest, "expect non empty id as parameter of the scan infos to fetch.")
+			return
+		}
+
+		infos, err := w.application.Get(c.Request.Context(), id)
+		if err != nil {
+			w.logger.Error("unable to get scan infos", zap.String("requestid", c.GetString("x-requestid")), zap.String("traceid", c.GetString("x-traceid")), zap.Error(err))
+			writeError(c, err)
+			return
+		}
+
+		c.Header("ETag", formatETag(infos.Version))
+		c.JSON(http.StatusOK, newScanInfosV2Response(infos))
+	}
+}
@@ -1,13 +1,24 @@
 package web
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
+	"github.com/jeamon/backend-api/pkg/domain"
 )
 
 // generateRequestID provides a random uid to trace a given request.
@@ -59,3 +70,357 @@ func readBody(reader io.Reader) (string, error) {
 	s := buf.String()
 	return s, nil
 }
+
+// errorStatus maps an error returned by the application layer to the
+// corresponding http status code.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return http.StatusUnauthorized
+	case errors.Is(err, domain.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrTooManyRequests):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// problemContentType is the media type of the error responses.
+const problemContentType = "application/problem+json"
+
+// Codes of the problem responses.
+const (
+	codeBadRequest           = "bad_request"
+	codeValidationFailed     = "validation_failed"
+	codeUnauthenticated      = "unauthenticated"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeRouteNotFound        = "route_not_found"
+	codeConflict             = "conflict"
+	codePreconditionFailed   = "precondition_failed"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeRateLimited          = "rate_limited"
+	codeQuotaExceeded        = "quota_exceeded"
+	codeIdempotencyKeyReused = "idempotency_key_reused"
+	codeRequestInProgress    = "request_in_progress"
+	codeServiceUnavailable   = "service_unavailable"
+	codeInternalError        = "internal_error"
+)
+
+// errorCode maps an error returned by the application layer to the code of
+// its problem response.
+func errorCode(err error) string {
+	var quotaErr *domain.QuotaExceededError
+	switch {
+	case errors.As(err, &quotaErr):
+		return codeQuotaExceeded
+	case errors.Is(err, domain.ErrNotFound):
+		return codeNotFound
+	case errors.Is(err, domain.ErrConflict):
+		return codeConflict
+	case errors.Is(err, domain.ErrValidation):
+		return codeValidationFailed
+	case errors.Is(err, domain.ErrUnavailable):
+		return codeServiceUnavailable
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		return codePreconditionFailed
+	case errors.Is(err, domain.ErrUnauthenticated):
+		return codeUnauthenticated
+	case errors.Is(err, domain.ErrForbidden):
+		return codeForbidden
+	case errors.Is(err, domain.ErrTooManyRequests):
+		return codeRateLimited
+	}
+	return codeInternalError
+}
+
+// writeProblem aborts the request with a problem response.
+func writeProblem(c *gin.Context, status int, code, detail string) {
+	writeProblemErrors(c, status, code, detail, nil)
+}
+
+// writeError aborts the request with the problem response matching an error
+// returned by the application layer, including its invalid fields.
+func writeError(c *gin.Context, err error) {
+	writeProblemErrors(c, errorStatus(err), errorCode(err), err.Error(), fieldErrors(err))
+}
+
+func writeProblemErrors(c *gin.Context, status int, code, detail string, errs domain.ValidationErrors) {
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(status, problemResponse{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		RequestID: c.GetString("x-requestid"),
+		Code:      code,
+		Errors:    errs,
+	})
+}
+
+// maxRequestIDLength bounds the size of the X-Request-ID header.
+const maxRequestIDLength = 128
+
+// requestID returns the X-Request-ID header of the request when it is a
+// valid request id. Otherwise it generates a new one.
+func requestID(r *http.Request) string {
+	id := r.Header.Get("X-Request-ID")
+	if id == "" || len(id) > maxRequestIDLength {
+		return generateRequestID()
+	}
+
+	for _, ch := range id {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9':
+		case strings.ContainsRune("-_.:", ch):
+		default:
+			return generateRequestID()
+		}
+	}
+	return id
+}
+
+// setRetryAfter tells with the Retry-After header when a caller which
+// exhausted its daily quota can submit again.
+func setRetryAfter(c *gin.Context, err error) {
+	var quotaErr *domain.QuotaExceededError
+	if errors.As(err, &quotaErr) {
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(time.Until(quotaErr.ResetAt))))
+	}
+}
+
+// rateLimitKey returns the key of the rate limiting bucket of the caller.
+// Callers without credentials are limited by ip.
+func rateLimitKey(c *gin.Context, key string) string {
+	p, ok := domain.PrincipalFromContext(c.Request.Context())
+	switch {
+	case ok && key == "api_key":
+		return "subject:" + p.Subject
+	case ok && key == "company" && p.CompanyID != "":
+		return "company:" + p.CompanyID
+	}
+	return "ip:" + getIP(c.Request)
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// fieldErrors returns the invalid fields reported by err, if any.
+func fieldErrors(err error) domain.ValidationErrors {
+	var errs domain.ValidationErrors
+	errors.As(err, &errs)
+	return errs
+}
+
+// batchFieldErrors lists the errors of the rejected batch items. Their fields
+// are prefixed by the index of the item, which is the field itself when the
+// item could not be decoded.
+func batchFieldErrors(results []domain.BatchItemResult) domain.ValidationErrors {
+	var errs domain.ValidationErrors
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+
+		prefix := fmt.Sprintf("[%d]", r.Index)
+		if len(r.Errors) == 0 {
+			errs = append(errs, domain.FieldError{Field: prefix, Message: r.Error})
+			continue
+		}
+		for _, e := range r.Errors {
+			errs = append(errs, domain.FieldError{Field: prefix + "." + e.Field, Message: e.Message})
+		}
+	}
+	return errs
+}
+
+// formatETag returns the strong entity tag of a scan infos version.
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseIfMatch returns the scan infos version expected by the If-Match
+// header. It returns 0 when the header is missing or set to "*".
+func parseIfMatch(r *http.Request) (int64, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	value = strings.TrimPrefix(value, "W/")
+	if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return 0, fmt.Errorf("invalid If-Match header value %q. expect a single entity tag", value)
+	}
+
+	version, err := strconv.ParseInt(value[1:len(value)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid If-Match header value %q. it does not match any version", value)
+	}
+
+	return version, nil
+}
+
+// ndjsonContentTypes are the media types of a newline delimited JSON body.
+var ndjsonContentTypes = map[string]bool{
+	"application/x-ndjson": true,
+	"application/ndjson":   true,
+	"application/jsonl":    true,
+}
+
+// readBatchItems decodes the scan submissions of a batch body which is either
+// a newline delimited JSON stream or a JSON array. An item that cannot be
+// decoded is reported on its own so that other items are still processed.
+func readBatchItems(contentType string, body io.Reader) ([]domain.BatchItem, error) {
+	var raws []json.RawMessage
+	if ndjsonContentTypes[contentType] {
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			raws = append(raws, json.RawMessage(append([]byte(nil), line...)))
+			if len(raws) > domain.MaxBatchSize {
+				break
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("invalid ndjson body: %v", err)
+		}
+	} else {
+		// the array is streamed so that the items over the limit are not read.
+		dec := json.NewDecoder(body)
+		if token, err := dec.Token(); err != nil || token != json.Delim('[') {
+			return nil, errors.New("invalid json body. expect an array of scan infos")
+		}
+
+		for dec.More() && len(raws) <= domain.MaxBatchSize {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, fmt.Errorf("invalid json body. expect an array of scan infos: %v", err)
+			}
+			raws = append(raws, raw)
+		}
+
+		if len(raws) <= domain.MaxBatchSize {
+			if _, err := dec.Token(); err != nil {
+				return nil, fmt.Errorf("invalid json body. expect an array of scan infos: %v", err)
+			}
+		}
+	}
+
+	if len(raws) == 0 {
+		return nil, errors.New("empty batch. expect at least one scan infos")
+	}
+
+	if len(raws) > domain.MaxBatchSize {
+		return nil, fmt.Errorf("batch too large. expect at most %d scan infos", domain.MaxBatchSize)
+	}
+
+	items := make([]domain.BatchItem, len(raws))
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, &items[i].Request); err != nil {
+			items[i].Err = fmt.Errorf("invalid scan infos: %v", err)
+		}
+	}
+
+	return items, nil
+}
+
+// maxIdempotencyKeyLength bounds the size of the Idempotency-Key header.
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodySize bounds the size of the bodies buffered to identify
+// the requests sent with an Idempotency-Key header.
+const maxIdempotentBodySize = 4 << 20
+
+// errReader fails every read with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// hashRequest identifies a request by its method, path and body.
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body written by a handler.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// bearerToken returns the token of a bearer Authorization header value.
+func bearerToken(authorization string) string {
+	parts := strings.SplitN(strings.TrimSpace(authorization), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+// redactAuthorization hides the credentials of an Authorization header value
+// and only keeps its scheme.
+func redactAuthorization(authorization string) string {
+	if authorization == "" {
+		return ""
+	}
+	return strings.SplitN(strings.TrimSpace(authorization), " ", 2)[0] + " [REDACTED]"
+}
+
+// certificateIdentity returns the client id held by the field of a client
+// certificate, which is its common name or its first DNS, URI or email SAN.
+func certificateIdentity(cert *x509.Certificate, field string) string {
+	switch field {
+	case "dns_san":
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+	case "uri_san":
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+	case "email_san":
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+	default:
+		return cert.Subject.CommonName
+	}
+	return ""
+}
@@ -4,16 +4,75 @@ import (
 	"github.com/jeamon/backend-api/pkg/domain"
 )
 
-type errResponse struct {
-	RequestID        string `json:"request_id"`
-	Message          string `json:"message"`
-	DeveloperMessage string `json:"developer_message"`
+// problemResponse is the RFC 7807 problem details body of every error
+// response. Code identifies the kind of error for programs and does not
+// change across versions, unlike Detail which explains this occurrence.
+type problemResponse struct {
+	Type      string                  `json:"type"`
+	Title     string                  `json:"title"`
+	Status    int                     `json:"status"`
+	Detail    string                  `json:"detail"`
+	Instance  string                  `json:"instance"`
+	RequestID string                  `json:"request_id"`
+	Code      string                  `json:"code"`
+	Errors    domain.ValidationErrors `json:"errors,omitempty"`
 }
 
 type getAllScanInfosResponse struct {
-	RequestID string             `json:"request_id"`
-	Message   string             `json:"message"`
-	Infos     []domain.ScanInfos `json:"infos"`
+	RequestID  string             `json:"request_id"`
+	Message    string             `json:"message"`
+	Infos      []domain.ScanInfos `json:"infos"`
+	NextCursor string             `json:"next_cursor"`
+	Total      int64              `json:"total"`
+}
+
+// listScanInfosRequest holds the query string parameters of the listing endpoint.
+type listScanInfosRequest struct {
+	CompanyID     string `form:"company_id"`
+	ClientID      string `form:"client_id"`
+	RepositoryURL string `form:"repository_url"`
+	CommitID      string `form:"commit_id"`
+	TagID         string `form:"tag_id"`
+	StartedFrom   int64  `form:"started_from"`
+	StartedTo     int64  `form:"started_to"`
+	CompletedFrom int64  `form:"completed_from"`
+	CompletedTo   int64  `form:"completed_to"`
+	HasError      *bool  `form:"has_error"`
+	Sort          string `form:"sort"`
+	Limit         int    `form:"limit"`
+	Cursor        string `form:"cursor"`
+}
+
+func (r listScanInfosRequest) toQuery() domain.ScanInfosQuery {
+	sortBy, descending := domain.ParseSort(r.Sort)
+	return domain.ScanInfosQuery{
+		Filter: domain.ScanInfosFilter{
+			CompanyID:     r.CompanyID,
+			ClientID:      r.ClientID,
+			RepositoryURL: r.RepositoryURL,
+			CommitID:      r.CommitID,
+			TagID:         r.TagID,
+			StartedFrom:   r.StartedFrom,
+			StartedTo:     r.StartedTo,
+			CompletedFrom: r.CompletedFrom,
+			CompletedTo:   r.CompletedTo,
+			HasError:      r.HasError,
+		},
+		SortBy:     sortBy,
+		Descending: descending,
+		Limit:      r.Limit,
+		Cursor:     r.Cursor,
+	}
+}
+
+// batchScanInfosResponse reports the outcome of every item of a batch.
+type batchScanInfosResponse struct {
+	RequestID string                   `json:"request_id"`
+	Message   string                   `json:"message"`
+	Mode      string                   `json:"mode"`
+	Stored    int                      `json:"stored"`
+	Failed    int                      `json:"failed"`
+	Results   []domain.BatchItemResult `json:"results"`
 }
 
 type genericResponse struct {
@@ -21,3 +80,32 @@ type genericResponse struct {
 	Message     string `json:"message"`
 	ScanInfosID string `json:"scan_infos_id"`
 }
+
+// scanInfosV2Response is the v2 representation of a scan infos which always
+// carries the list of structured findings.
+type scanInfosV2Response struct {
+	domain.ScanInfos
+	Findings []domain.Finding `json:"findings"`
+}
+
+func newScanInfosV2Response(s domain.ScanInfos) scanInfosV2Response {
+	findings := s.Findings
+	if findings == nil {
+		findings = []domain.Finding{}
+	}
+	return scanInfosV2Response{ScanInfos: s, Findings: findings}
+}
+
+type diffScanInfosResponse struct {
+	RequestID string `json:"request_id"`
+	Message   string `json:"message"`
+	domain.ScanInfosDiff
+}
+
+// quotaUsagesResponse lists the submissions counted for a day per company.
+type quotaUsagesResponse struct {
+	RequestID string              `json:"request_id"`
+	Message   string              `json:"message"`
+	Day       string              `json:"day"`
+	Usages    []domain.QuotaUsage `json:"usages"`
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// rateBucketIdleTTL is how long the bucket of a key not seen anymore is kept.
+const rateBucketIdleTTL = 10 * time.Minute
+
+// rateLimiter keeps a token bucket per key. The rate and burst are given on
+// each call so that configuration changes apply to the existing buckets.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+type rateBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateDecision describes the outcome of a call against a bucket.
+type rateDecision struct {
+	allowed bool
+	// limit is the size of the bucket and remaining its tokens left.
+	limit     int
+	remaining int
+	// reset is the time until the bucket is full again.
+	reset time.Duration
+	// retryAfter is the time to wait before the call would be allowed.
+	retryAfter time.Duration
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*rateBucket)}
+}
+
+// allow takes a token from the bucket of key refilled at rps tokens per
+// second up to burst tokens.
+func (l *rateLimiter) allow(key string, rps float64, burst int, now time.Time) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b := l.bucket(key, rps, burst, now)
+	d := rateDecision{limit: burst}
+	r := b.limiter.ReserveN(now, 1)
+	switch delay := r.DelayFrom(now); {
+	case !r.OK():
+		d.retryAfter = time.Second
+	case delay > 0:
+		r.CancelAt(now)
+		d.retryAfter = delay
+	default:
+		d.allowed = true
+	}
+	return d.withTokens(b.limiter.TokensAt(now), rps)
+}
+
+// peek reports whether a token could be taken from the bucket of key without
+// taking it. The bucket of an unknown key is full.
+func (l *rateLimiter) peek(key string, rps float64, burst int, now time.Time) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	d := rateDecision{limit: burst}
+	b, found := l.buckets[key]
+	if !found {
+		d.allowed = burst > 0
+		return d.withTokens(float64(burst), rps)
+	}
+
+	b = l.bucket(key, rps, burst, now)
+	tokens := b.limiter.TokensAt(now)
+	switch {
+	case tokens >= 1:
+		d.allowed = true
+	case rps > 0:
+		d.retryAfter = time.Duration((1 - tokens) / rps * float64(time.Second))
+	default:
+		d.retryAfter = time.Second
+	}
+	return d.withTokens(tokens, rps)
+}
+
+// bucket returns the bucket of key, created if needed, with the current rate
+// and burst.
+func (l *rateLimiter) bucket(key string, rps float64, burst int, now time.Time) *rateBucket {
+	b, found := l.buckets[key]
+	if !found {
+		b = &rateBucket{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+		l.buckets[key] = b
+	}
+	if b.limiter.Limit() != rate.Limit(rps) {
+		b.limiter.SetLimitAt(now, rate.Limit(rps))
+	}
+	if b.limiter.Burst() != burst {
+		b.limiter.SetBurstAt(now, burst)
+	}
+	b.lastSeen = now
+	return b
+}
+
+// withTokens sets the tokens left and the time until the bucket is full.
+func (d rateDecision) withTokens(tokens, rps float64) rateDecision {
+	tokens = math.Max(0, tokens)
+	d.remaining = int(tokens)
+	if rps > 0 {
+		d.reset = time.Duration((float64(d.limit) - tokens) / rps * float64(time.Second))
+	}
+	return d
+}
+
+// sweep drops the buckets of the keys idle for a while. It runs at most once
+// a minute.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) > rateBucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
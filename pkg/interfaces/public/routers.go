@@ -5,25 +5,55 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/jeamon/backend-api/pkg/domain"
 )
 
 func (w *ScanInfosService) Router(router *gin.Engine) *gin.Engine {
-	router.Use(w.RequestLoggerMiddleware())
+	router.Use(w.TracingMiddleware(), w.RequestLoggerMiddleware())
+	if w.metrics != nil {
+		router.Use(w.MetricsMiddleware())
+	}
 	router.NoRoute(w.NotFoundHandler())
+	// the metrics disclose the activity of every company.
+	if w.metrics != nil {
+		router.GET("/metrics", w.ClientCertificateMiddleware(true), w.AuthMiddleware(), w.RequirePermission(domain.PermissionAllCompanies), gin.WrapH(w.metrics.Handler()))
+	}
 	api := router.Group("/api/v1")
 	api.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Accept", "content-type", "User-Agent", "Accept-Language", "Referer", "DNT", "Connection", "Pragma", "Cache-Control", "TE"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Accept", "content-type", "User-Agent", "Accept-Language", "Referer", "DNT", "Connection", "Pragma", "Cache-Control", "TE", "If-Match", "Idempotency-Key", "Authorization", "X-API-Key", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "X-Request-ID", "Idempotent-Replayed", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	api.POST("/scaninfos", w.StoreScanInfosHandler())
-	api.GET("/scaninfos/:id", w.GetScanInfosHandler())
-	api.GET("/scaninfos", w.GetAllScanInfosHandler())
-	api.PUT("/scaninfos", w.UpdateScanInfosHandler())
-	api.DELETE("/scaninfos/:id", w.DeleteScanInfosHandler())
+	api.Use(w.IPRateLimitMiddleware(), w.ClientCertificateMiddleware(true), w.AuthMiddleware(), w.RateLimitMiddleware())
+
+	api.POST("/scaninfos", w.RequirePermission(domain.PermissionCreateScans), w.IdempotencyMiddleware(), w.StoreScanInfosHandler())
+	api.POST("/scaninfos:action", w.KnownActionMiddleware(), w.RequirePermission(domain.PermissionCreateScans), w.ScanInfosActionHandler())
+	api.GET("/scaninfos/:id", w.RequirePermission(domain.PermissionReadScans), w.GetScanInfosHandler())
+	api.GET("/scaninfos/:id/diff", w.RequirePermission(domain.PermissionReadScans), w.DiffScanInfosHandler())
+	api.GET("/scaninfos/diff", w.RequirePermission(domain.PermissionReadScans), w.DiffScanInfosByRefsHandler())
+	api.GET("/scaninfos", w.RequirePermission(domain.PermissionReadScans), w.GetAllScanInfosHandler())
+	api.PUT("/scaninfos", w.RequirePermission(domain.PermissionUpdateScans), w.UpdateScanInfosHandler())
+	api.PATCH("/scaninfos/:id", w.RequirePermission(domain.PermissionUpdateScans), w.PatchScanInfosHandler())
+	api.DELETE("/scaninfos/:id", w.RequirePermission(domain.PermissionDeleteScans), w.DeleteScanInfosHandler())
+	api.GET("/admin/quotas", w.RequirePermission(domain.PermissionAllCompanies), w.GetQuotaUsagesHandler())
+
+	apiV2 := router.Group("/api/v2")
+	apiV2.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers", "Accept", "content-type", "User-Agent", "Accept-Language", "Referer", "DNT", "Connection", "Pragma", "Cache-Control", "TE", "If-Match", "Idempotency-Key", "Authorization", "X-API-Key", "X-Request-ID"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", "X-Request-ID", "Idempotent-Replayed", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+
+	apiV2.Use(w.IPRateLimitMiddleware(), w.ClientCertificateMiddleware(false), w.AuthMiddleware(), w.RateLimitMiddleware())
+
+	apiV2.POST("/scaninfos", w.RequireClientCertificate(), w.RequirePermission(domain.PermissionCreateScans), w.StoreScanInfosV2Handler())
+	apiV2.GET("/scaninfos/:id", w.RequirePermission(domain.PermissionReadScans), w.GetScanInfosV2Handler())
 	return router
 }
@@ -2,14 +2,20 @@ package web
 
 import (
 	"github.com/jeamon/backend-api/pkg/application"
+	"github.com/jeamon/backend-api/pkg/infrastructure/metrics"
 	"go.uber.org/zap"
 )
 
 type ScanInfosService struct {
 	logger      *zap.Logger
 	application *application.ScanInfosUsecase
+	idempotency *application.IdempotencyUsecase
+	auth        *application.AuthUsecase
+	quotas      *application.QuotaUsecase
+	limiter     *rateLimiter
+	metrics     *metrics.Metrics
 }
 
-func New(logger *zap.Logger, application *application.ScanInfosUsecase) *ScanInfosService {
-	return &ScanInfosService{logger: logger, application: application}
+func New(logger *zap.Logger, application *application.ScanInfosUsecase, idempotency *application.IdempotencyUsecase, auth *application.AuthUsecase, quotas *application.QuotaUsecase, metrics *metrics.Metrics) *ScanInfosService {
+	return &ScanInfosService{logger: logger, application: application, idempotency: idempotency, auth: auth, quotas: quotas, limiter: newRateLimiter(), metrics: metrics}
 }
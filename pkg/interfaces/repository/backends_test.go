@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mongo"
+	"github.com/jeamon/backend-api/pkg/infrastructure/postgres"
+	"go.uber.org/zap"
+)
+
+// memoryBackend provides new in-memory repositories, which start empty.
+func memoryBackend(t *testing.T) *backend {
+	h, err := (&mockdb.Config{}).ConnectAndMigrate(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &backend{
+		reset:       func(t *testing.T, name string) {},
+		scanInfos:   func() domain.ScanInfosRepository { return NewInMemoryScanInfosRepository(zap.NewNop(), h) },
+		idempotency: func() domain.IdempotencyRepository { return NewInMemoryIdempotencyRepository(zap.NewNop(), h) },
+		apiKeys:     func() domain.APIKeyRepository { return NewInMemoryAPIKeyRepository(zap.NewNop(), h) },
+		quotas:      func() domain.QuotaRepository { return NewInMemoryQuotaRepository(zap.NewNop(), h) },
+	}
+}
+
+// postgresBackend connects to the database described by the
+// BACKEND_API_TEST_POSTGRES_* environment variables, for example the one
+// started with "docker-compose up -d postgres". It is skipped otherwise.
+func postgresBackend(t *testing.T) *backend {
+	host := os.Getenv("BACKEND_API_TEST_POSTGRES_HOST")
+	if host == "" {
+		t.Skip("BACKEND_API_TEST_POSTGRES_HOST is not set")
+	}
+
+	config := postgres.Config{
+		Host:          host,
+		Port:          envOrDefault("BACKEND_API_TEST_POSTGRES_PORT", "5432"),
+		User:          envOrDefault("BACKEND_API_TEST_POSTGRES_USER", "api"),
+		Password:      envOrDefault("BACKEND_API_TEST_POSTGRES_PASSWORD", "secret"),
+		Database:      envOrDefault("BACKEND_API_TEST_POSTGRES_DATABASE", "demo"),
+		MigrationPath: "../../infrastructure/postgres/migrations",
+	}
+	h, err := config.ConnectAndMigrate(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Shutdown(context.Background()) })
+
+	return &backend{
+		reset: func(t *testing.T, name string) {
+			if _, err := h.PGx.Exec(context.Background(), "TRUNCATE data."+name+" CASCADE"); err != nil {
+				t.Fatal(err)
+			}
+		},
+		scanInfos:   func() domain.ScanInfosRepository { return NewPostgresScanInfosRepository(zap.NewNop(), h) },
+		idempotency: func() domain.IdempotencyRepository { return NewPostgresIdempotencyRepository(zap.NewNop(), h) },
+		apiKeys:     func() domain.APIKeyRepository { return NewPostgresAPIKeyRepository(zap.NewNop(), h) },
+		quotas:      func() domain.QuotaRepository { return NewPostgresQuotaRepository(zap.NewNop(), h) },
+	}
+}
+
+// mongoBackend connects to the database described by the
+// BACKEND_API_TEST_MONGO_* environment variables, for example the one started
+// with "docker-compose up -d mongo". It is skipped otherwise.
+func mongoBackend(t *testing.T) *backend {
+	host := os.Getenv("BACKEND_API_TEST_MONGO_HOST")
+	if host == "" {
+		t.Skip("BACKEND_API_TEST_MONGO_HOST is not set")
+	}
+
+	config := mongo.Config{
+		Host:     host,
+		Port:     envOrDefault("BACKEND_API_TEST_MONGO_PORT", "27017"),
+		User:     envOrDefault("BACKEND_API_TEST_MONGO_USER", "api"),
+		Password: envOrDefault("BACKEND_API_TEST_MONGO_PASSWORD", "secret"),
+		Database: envOrDefault("BACKEND_API_TEST_MONGO_DATABASE", "demo_test"),
+	}
+	h, err := config.ConnectAndMigrate(zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Shutdown(context.Background()) })
+
+	db := config.Database
+	return &backend{
+		reset: func(t *testing.T, name string) {
+			if err := h.Client.Database(db).Collection(name).Drop(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		},
+		scanInfos:   func() domain.ScanInfosRepository { return NewMongoScanInfosRepository(zap.NewNop(), h, db) },
+		idempotency: func() domain.IdempotencyRepository { return NewMongoIdempotencyRepository(zap.NewNop(), h, db) },
+		apiKeys:     func() domain.APIKeyRepository { return NewMongoAPIKeyRepository(zap.NewNop(), h, db) },
+		quotas:      func() domain.QuotaRepository { return NewMongoQuotaRepository(zap.NewNop(), h, db) },
+	}
+}
+
+func envOrDefault(key, value string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return value
+}
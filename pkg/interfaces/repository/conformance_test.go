@@ -0,0 +1,601 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// backend builds the repositories of one storage backend. Each repository it
+// provides works on data emptied by reset, named after its table or
+// collection.
+type backend struct {
+	reset       func(t *testing.T, name string)
+	scanInfos   func() domain.ScanInfosRepository
+	idempotency func() domain.IdempotencyRepository
+	apiKeys     func() domain.APIKeyRepository
+	quotas      func() domain.QuotaRepository
+}
+
+func (b *backend) newScanInfosRepository(t *testing.T) domain.ScanInfosRepository {
+	b.reset(t, "scan_infos")
+	return b.scanInfos()
+}
+
+func (b *backend) newIdempotencyRepository(t *testing.T) domain.IdempotencyRepository {
+	b.reset(t, "idempotency_keys")
+	return b.idempotency()
+}
+
+func (b *backend) newAPIKeyRepository(t *testing.T) domain.APIKeyRepository {
+	b.reset(t, "api_keys")
+	return b.apiKeys()
+}
+
+func (b *backend) newQuotaRepository(t *testing.T) domain.QuotaRepository {
+	b.reset(t, "quota_usages")
+	return b.quotas()
+}
+
+// TestRepositoryConformance sets up each backend once then runs every suite
+// against it. A backend whose database is not configured is skipped.
+func TestRepositoryConformance(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(t *testing.T) *backend
+	}{
+		{"memory", memoryBackend},
+		{"postgres", postgresBackend},
+		{"mongo", mongoBackend},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			b := tc.setup(t)
+			t.Run("scan infos", func(t *testing.T) { testScanInfosRepositoryConformance(t, b.newScanInfosRepository) })
+			t.Run("idempotency keys", func(t *testing.T) { testIdempotencyRepositoryConformance(t, b.newIdempotencyRepository) })
+			t.Run("api keys", func(t *testing.T) { testAPIKeyRepositoryConformance(t, b.newAPIKeyRepository) })
+			t.Run("quotas", func(t *testing.T) { testQuotaRepositoryConformance(t, b.newQuotaRepository) })
+		})
+	}
+}
+
+const unknownScanInfosID = "7aec1a3e-f22d-11ec-a1c2-37e6aab6bd2c"
+
+// newTestScanInfos builds a scan infos with every field set. Only one out of
+// three scans has no error.
+func newTestScanInfos(n int) domain.ScanInfos {
+	now := time.Now().UTC()
+	s := domain.ScanInfos{
+		CompanyID:     fmt.Sprintf("company-%d", n%2),
+		Username:      "jeamon",
+		ClientID:      "v1.0.0",
+		RepositoryURL: "https://github.com/jeamon/backend-api",
+		CommitID:      "d7b8ff1412ebfcde26f9ddfdf9608d1525647958",
+		TagID:         fmt.Sprintf("v1.0.%d", n),
+		Results:       []string{"found something x", "found something y"},
+		Findings: []domain.Finding{
+			{
+				RuleID:      "G101",
+				Severity:    domain.SeverityHigh,
+				Title:       "Potential hardcoded credentials",
+				FilePath:    "main.go",
+				StartLine:   10,
+				EndLine:     12,
+				Fingerprint: "fingerprint-g101",
+				Scanner:     "gosec",
+				Properties:  map[string]interface{}{"confidence": "low"},
+			},
+		},
+		StartedAt:   1655903720 + int64(n),
+		CompletedAt: 1655903723 + int64(n),
+		SentAt:      1655903725 + int64(n),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Metadata: map[string]interface{}{
+			"os":        "linux",
+			"languages": []string{"go", "bash"},
+			"build":     map[string]interface{}{"arch": "amd64", "cores": 4},
+		},
+	}
+
+	if n%3 != 0 {
+		s.Error = fmt.Sprintf("got an exception %d", n)
+	}
+	return s
+}
+
+// normalizedJSON makes values comparable regardless of the concrete types
+// returned by each database driver.
+func normalizedJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// assertSameScanInfos checks that a stored scan infos kept all its fields.
+// Timestamps are compared with the millisecond precision of mongo.
+func assertSameScanInfos(t *testing.T, expected, actual domain.ScanInfos) {
+	assert.Equal(t, expected.CompanyID, actual.CompanyID)
+	assert.Equal(t, expected.Username, actual.Username)
+	assert.Equal(t, expected.ClientID, actual.ClientID)
+	assert.Equal(t, expected.RepositoryURL, actual.RepositoryURL)
+	assert.Equal(t, expected.CommitID, actual.CommitID)
+	assert.Equal(t, expected.TagID, actual.TagID)
+	assert.Equal(t, expected.Results, actual.Results)
+	assert.Equal(t, expected.StartedAt, actual.StartedAt)
+	assert.Equal(t, expected.CompletedAt, actual.CompletedAt)
+	assert.Equal(t, expected.SentAt, actual.SentAt)
+	assert.Equal(t, expected.Error, actual.Error)
+	assert.JSONEq(t, normalizedJSON(t, expected.Metadata), normalizedJSON(t, actual.Metadata))
+	assert.JSONEq(t, normalizedJSON(t, expected.Findings), normalizedJSON(t, actual.Findings))
+}
+
+// testScanInfosRepositoryConformance runs the behaviours every scan infos
+// repository backend must share against the repositories built by newRepo.
+func testScanInfosRepositoryConformance(t *testing.T, newRepo func(t *testing.T) domain.ScanInfosRepository) {
+	ctx := context.Background()
+
+	t.Run("save and find round trip keeps all fields", func(t *testing.T) {
+		repo := newRepo(t)
+		s := newTestScanInfos(1)
+		id, err := repo.Save(ctx, s)
+		require.NoError(t, err)
+		require.NotEmpty(t, id)
+
+		found, err := repo.FindByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, id, found.ID)
+		assertSameScanInfos(t, s, found)
+		assert.WithinDuration(t, s.CreatedAt, found.CreatedAt, time.Millisecond)
+		assert.WithinDuration(t, s.UpdatedAt, found.UpdatedAt, time.Millisecond)
+	})
+
+	t.Run("save batch stores all records in order", func(t *testing.T) {
+		repo := newRepo(t)
+		batch := []domain.ScanInfos{newTestScanInfos(1), newTestScanInfos(2), newTestScanInfos(3)}
+		ids, err := repo.SaveBatch(ctx, batch)
+		require.NoError(t, err)
+		require.Len(t, ids, len(batch))
+
+		for i, id := range ids {
+			found, err := repo.FindByID(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), found.Version)
+			assertSameScanInfos(t, batch[i], found)
+		}
+	})
+
+	t.Run("save stores missing lists and maps as empty", func(t *testing.T) {
+		repo := newRepo(t)
+		s := newTestScanInfos(1)
+		s.Results, s.Metadata, s.Findings = nil, nil, nil
+		id, err := repo.Save(ctx, s)
+		require.NoError(t, err)
+
+		found, err := repo.FindByID(ctx, id)
+		require.NoError(t, err)
+		assert.NotNil(t, found.Results)
+		assert.Empty(t, found.Results)
+		assert.NotNil(t, found.Metadata)
+		assert.Empty(t, found.Metadata)
+		assert.Empty(t, found.Findings)
+	})
+
+	t.Run("update replaces all fields but the creation time", func(t *testing.T) {
+		repo := newRepo(t)
+		s := newTestScanInfos(1)
+		id, err := repo.Save(ctx, s)
+		require.NoError(t, err)
+
+		updated := newTestScanInfos(2)
+		updated.ID = id
+		updated.Username = "someone"
+		updated.Results = []string{"found something z"}
+		updated.Metadata = map[string]interface{}{"os": "windows"}
+		updated.Findings[0].Title = "Hardcoded credentials"
+		require.NoError(t, repo.UpdateByID(ctx, id, updated))
+
+		found, err := repo.FindByID(ctx, id)
+		require.NoError(t, err)
+		assertSameScanInfos(t, updated, found)
+		assert.WithinDuration(t, s.CreatedAt, found.CreatedAt, time.Millisecond)
+		assert.False(t, found.UpdatedAt.Before(s.UpdatedAt.Truncate(time.Millisecond)))
+	})
+
+	t.Run("update without findings keeps the stored ones", func(t *testing.T) {
+		repo := newRepo(t)
+		s := newTestScanInfos(1)
+		id, err := repo.Save(ctx, s)
+		require.NoError(t, err)
+
+		updated := newTestScanInfos(1)
+		updated.Findings = nil
+		require.NoError(t, repo.UpdateByID(ctx, id, updated))
+
+		found, err := repo.FindByID(ctx, id)
+		require.NoError(t, err)
+		assert.JSONEq(t, normalizedJSON(t, s.Findings), normalizedJSON(t, found.Findings))
+	})
+
+	t.Run("delete removes the record", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.Save(ctx, newTestScanInfos(1))
+		require.NoError(t, err)
+		require.NoError(t, repo.DeleteByID(ctx, id, 0))
+
+		_, err = repo.FindByID(ctx, id)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("versions guard updates and deletes", func(t *testing.T) {
+		repo := newRepo(t)
+		id, err := repo.Save(ctx, newTestScanInfos(1))
+		require.NoError(t, err)
+
+		found, err := repo.FindByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), found.Version)
+
+		updated := newTestScanInfos(2)
+		updated.Version = 1
+		require.NoError(t, repo.UpdateByID(ctx, id, updated))
+
+		found, err = repo.FindByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), found.Version)
+
+		assert.ErrorIs(t, repo.UpdateByID(ctx, id, updated), domain.ErrPreconditionFailed)
+		assert.ErrorIs(t, repo.DeleteByID(ctx, id, 1), domain.ErrPreconditionFailed)
+
+		updated.Version = 0
+		require.NoError(t, repo.UpdateByID(ctx, id, updated))
+		found, err = repo.FindByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), found.Version)
+
+		require.NoError(t, repo.DeleteByID(ctx, id, 3))
+		_, err = repo.FindByID(ctx, id)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("missing records are reported as not found", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.FindByID(ctx, unknownScanInfosID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+
+		s := newTestScanInfos(1)
+		s.ID = unknownScanInfosID
+		assert.ErrorIs(t, repo.UpdateByID(ctx, unknownScanInfosID, s), domain.ErrNotFound)
+		assert.ErrorIs(t, repo.DeleteByID(ctx, unknownScanInfosID, 0), domain.ErrNotFound)
+	})
+
+	t.Run("list filters, sorts and pages through records", func(t *testing.T) {
+		repo := newRepo(t)
+		for i := 0; i < 5; i++ {
+			_, err := repo.Save(ctx, newTestScanInfos(i))
+			require.NoError(t, err)
+		}
+
+		q := domain.ScanInfosQuery{
+			Filter: domain.ScanInfosFilter{CompanyID: "company-0"},
+			SortBy: domain.SortByStartedAt,
+			Limit:  2,
+		}
+		var started []int64
+		for {
+			page, err := repo.List(ctx, q)
+			require.NoError(t, err)
+			assert.Equal(t, int64(3), page.Total)
+			for _, s := range page.Infos {
+				assert.Equal(t, "company-0", s.CompanyID)
+				assert.NotEmpty(t, s.Findings)
+				started = append(started, s.StartedAt)
+			}
+
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []int64{1655903720, 1655903722, 1655903724}, started)
+
+		hasError := false
+		page, err := repo.List(ctx, domain.ScanInfosQuery{
+			Filter:     domain.ScanInfosFilter{HasError: &hasError, StartedFrom: 1655903721},
+			SortBy:     domain.SortByStartedAt,
+			Descending: true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), page.Total)
+		assert.Empty(t, page.NextCursor)
+		require.Len(t, page.Infos, 1)
+		assert.Equal(t, int64(1655903723), page.Infos[0].StartedAt)
+
+		page, err = repo.List(ctx, domain.ScanInfosQuery{Filter: domain.ScanInfosFilter{CompanyID: "company-0"}, SkipTotal: true})
+		require.NoError(t, err)
+		assert.Zero(t, page.Total)
+		assert.Len(t, page.Infos, 3)
+
+		_, err = repo.List(ctx, domain.ScanInfosQuery{Limit: domain.MaxListLimit + 1})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+
+	t.Run("import keeps ids, versions and timestamps and skips stored records", func(t *testing.T) {
+		repo := newRepo(t)
+		created := time.Date(2022, 6, 22, 13, 15, 20, 0, time.UTC)
+		first, second := newTestScanInfos(1), newTestScanInfos(2)
+		first.ID, first.Version, first.CreatedAt, first.UpdatedAt = "5b1f7a4e-f22d-11ec-a1c2-37e6aab6bd2c", 3, created, created.Add(time.Hour)
+		second.ID = "6c2e8b5f-f22d-11ec-a1c2-37e6aab6bd2c"
+
+		n, err := repo.Import(ctx, []domain.ScanInfos{first, second})
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+
+		found, err := repo.FindByID(ctx, first.ID)
+		require.NoError(t, err)
+		assertSameScanInfos(t, first, found)
+		assert.Equal(t, int64(3), found.Version)
+		assert.WithinDuration(t, first.CreatedAt, found.CreatedAt, time.Millisecond)
+		assert.WithinDuration(t, first.UpdatedAt, found.UpdatedAt, time.Millisecond)
+
+		changed := first
+		changed.TagID = "v2.0.0"
+		n, err = repo.Import(ctx, []domain.ScanInfos{changed, second})
+		require.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		found, err = repo.FindByID(ctx, first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, first.TagID, found.TagID)
+		assert.JSONEq(t, normalizedJSON(t, first.Findings), normalizedJSON(t, found.Findings))
+
+		_, err = repo.Import(ctx, []domain.ScanInfos{newTestScanInfos(3)})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+
+	t.Run("concurrent writes are all applied", func(t *testing.T) {
+		repo := newRepo(t)
+		const writers = 20
+
+		var wg sync.WaitGroup
+		ids := make([]string, writers)
+		errs := make([]error, writers)
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ids[i], errs[i] = repo.Save(ctx, newTestScanInfos(i))
+			}(i)
+		}
+		wg.Wait()
+
+		unique := map[string]bool{}
+		for i := range ids {
+			require.NoError(t, errs[i])
+			unique[ids[i]] = true
+		}
+		assert.Len(t, unique, writers)
+
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				s := newTestScanInfos(i)
+				s.ID = ids[0]
+				errs[i] = repo.UpdateByID(ctx, ids[0], s)
+			}(i)
+		}
+		wg.Wait()
+
+		for i := range errs {
+			assert.NoError(t, errs[i])
+		}
+
+		page, err := repo.List(ctx, domain.ScanInfosQuery{Limit: domain.MaxListLimit})
+		require.NoError(t, err)
+		assert.Equal(t, int64(writers), page.Total)
+		assert.Len(t, page.Infos, writers)
+	})
+}
+
+// testIdempotencyRepositoryConformance runs the behaviours every idempotency
+// keys repository backend must share against the repositories built by newRepo.
+func testIdempotencyRepositoryConformance(t *testing.T, newRepo func(t *testing.T) domain.IdempotencyRepository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	record := domain.IdempotencyRecord{
+		Key:         "key-1",
+		RequestHash: "hash-1",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+		LockedUntil: now.Add(time.Minute),
+	}
+
+	t.Run("reserve, complete and replay a key", func(t *testing.T) {
+		repo := newRepo(t)
+		_, reserved, err := repo.Reserve(ctx, record)
+		require.NoError(t, err)
+		assert.True(t, reserved)
+
+		existing, reserved, err := repo.Reserve(ctx, record)
+		require.NoError(t, err)
+		assert.False(t, reserved)
+		assert.False(t, existing.Completed())
+
+		require.NoError(t, repo.Complete(ctx, record.Key, 200, []byte(`{"id":"1"}`)))
+		existing, reserved, err = repo.Reserve(ctx, record)
+		require.NoError(t, err)
+		assert.False(t, reserved)
+		assert.Equal(t, "hash-1", existing.RequestHash)
+		assert.Equal(t, 200, existing.StatusCode)
+		assert.Equal(t, `{"id":"1"}`, string(existing.Response))
+	})
+
+	t.Run("released and expired keys can be reserved again", func(t *testing.T) {
+		repo := newRepo(t)
+		_, reserved, err := repo.Reserve(ctx, record)
+		require.NoError(t, err)
+		require.True(t, reserved)
+		require.NoError(t, repo.Release(ctx, record.Key))
+
+		_, reserved, err = repo.Reserve(ctx, record)
+		require.NoError(t, err)
+		assert.True(t, reserved)
+
+		later := record
+		later.RequestHash = "hash-2"
+		later.CreatedAt = record.ExpiresAt
+		later.ExpiresAt = record.ExpiresAt.Add(time.Hour)
+		stored, reserved, err := repo.Reserve(ctx, later)
+		require.NoError(t, err)
+		assert.True(t, reserved)
+		assert.Equal(t, "hash-2", stored.RequestHash)
+	})
+
+	t.Run("abandoned keys are reclaimed by the same request once their lease ended", func(t *testing.T) {
+		repo := newRepo(t)
+		_, reserved, err := repo.Reserve(ctx, record)
+		require.NoError(t, err)
+		require.True(t, reserved)
+
+		retry := record
+		retry.CreatedAt = record.LockedUntil
+		retry.LockedUntil = record.LockedUntil.Add(time.Minute)
+		other := retry
+		other.RequestHash = "hash-2"
+		existing, reserved, err := repo.Reserve(ctx, other)
+		require.NoError(t, err)
+		assert.False(t, reserved)
+		assert.Equal(t, "hash-1", existing.RequestHash)
+
+		_, reserved, err = repo.Reserve(ctx, retry)
+		require.NoError(t, err)
+		assert.True(t, reserved)
+
+		// the key is held again by the retry.
+		_, reserved, err = repo.Reserve(ctx, retry)
+		require.NoError(t, err)
+		assert.False(t, reserved)
+
+		require.NoError(t, repo.Complete(ctx, record.Key, 200, []byte(`{"id":"1"}`)))
+		late := retry
+		late.CreatedAt = retry.LockedUntil.Add(time.Minute)
+		existing, reserved, err = repo.Reserve(ctx, late)
+		require.NoError(t, err)
+		assert.False(t, reserved)
+		assert.True(t, existing.Completed())
+	})
+
+	t.Run("completing an unknown key is reported as not found", func(t *testing.T) {
+		repo := newRepo(t)
+		assert.ErrorIs(t, repo.Complete(ctx, "unknown", 200, nil), domain.ErrNotFound)
+	})
+}
+
+// testAPIKeyRepositoryConformance runs the behaviours every API keys
+// repository backend must share against the repositories built by newRepo.
+func testAPIKeyRepositoryConformance(t *testing.T, newRepo func(t *testing.T) domain.APIKeyRepository) {
+	ctx := context.Background()
+	key := domain.APIKey{
+		ID:        "5c9828c6-f7f4-11ec-aa0a-d3f9ac7b9396",
+		Name:      "ci",
+		Hash:      "hash-1",
+		CompanyID: "company-1",
+		Scopes:    []string{domain.ScopeRead, domain.ScopeWrite},
+		Roles:     []string{domain.RoleViewer},
+		CreatedAt: time.Now().UTC(),
+	}
+
+	t.Run("save, find, list and revoke a key", func(t *testing.T) {
+		repo := newRepo(t)
+		require.NoError(t, repo.Save(ctx, key))
+		assert.ErrorIs(t, repo.Save(ctx, key), domain.ErrConflict)
+
+		found, err := repo.FindByHash(ctx, key.Hash)
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, found.ID)
+		assert.Equal(t, key.CompanyID, found.CompanyID)
+		assert.Equal(t, key.Scopes, found.Scopes)
+		assert.Equal(t, key.Roles, found.Roles)
+		assert.False(t, found.Revoked())
+
+		keys, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+
+		require.NoError(t, repo.Revoke(ctx, key.ID, time.Now().UTC()))
+		found, err = repo.FindByHash(ctx, key.Hash)
+		require.NoError(t, err)
+		assert.True(t, found.Revoked())
+	})
+
+	t.Run("missing keys are reported as not found", func(t *testing.T) {
+		repo := newRepo(t)
+		_, err := repo.FindByHash(ctx, "unknown")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.ErrorIs(t, repo.Revoke(ctx, unknownScanInfosID, time.Now()), domain.ErrNotFound)
+	})
+}
+
+// testQuotaRepositoryConformance runs the behaviours every quota usages
+// repository backend must share against the repositories built by newRepo.
+func testQuotaRepositoryConformance(t *testing.T, newRepo func(t *testing.T) domain.QuotaRepository) {
+	ctx := context.Background()
+
+	t.Run("consume within the limit, refund and list", func(t *testing.T) {
+		repo := newRepo(t)
+		count, ok, err := repo.Consume(ctx, "company-1", "2022-06-22", 2, 3)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int64(2), count)
+
+		count, ok, err = repo.Consume(ctx, "company-1", "2022-06-22", 2, 3)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, int64(2), count)
+
+		count, ok, err = repo.Consume(ctx, "company-1", "2022-06-22", 1, 3)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int64(3), count)
+
+		require.NoError(t, repo.Refund(ctx, "company-1", "2022-06-22", 1))
+		_, ok, err = repo.Consume(ctx, "company-2", "2022-06-22", 5, 0)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		_, ok, err = repo.Consume(ctx, "company-1", "2022-06-23", 4, 3)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		usages, err := repo.List(ctx, "2022-06-22")
+		require.NoError(t, err)
+		assert.Equal(t, []domain.QuotaUsage{
+			{CompanyID: "company-1", Day: "2022-06-22", Count: 2},
+			{CompanyID: "company-2", Day: "2022-06-22", Count: 5},
+		}, usages)
+
+		usages, err = repo.List(ctx, "2022-06-23")
+		require.NoError(t, err)
+		assert.Empty(t, usages)
+	})
+
+	t.Run("refunds do not go below zero", func(t *testing.T) {
+		repo := newRepo(t)
+		_, ok, err := repo.Consume(ctx, "company-1", "2022-06-22", 1, 0)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		require.NoError(t, repo.Refund(ctx, "company-1", "2022-06-22", 3))
+		require.NoError(t, repo.Refund(ctx, "company-1", "2022-06-23", 1))
+		usages, err := repo.List(ctx, "2022-06-22")
+		require.NoError(t, err)
+		assert.Equal(t, []domain.QuotaUsage{{CompanyID: "company-1", Day: "2022-06-22", Count: 0}}, usages)
+	})
+}
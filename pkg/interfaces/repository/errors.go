@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// postgresError classifies a postgres driver error under a domain error kind.
+func postgresError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if pgxscan.NotFound(err) {
+		return domain.NewError(domain.ErrNotFound, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "23505": // unique_violation
+			return domain.NewError(domain.ErrConflict, err)
+		case strings.HasPrefix(pgErr.Code, "22"), strings.HasPrefix(pgErr.Code, "23"): // data exception or integrity constraint violation
+			return domain.NewError(domain.ErrValidation, err)
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), strings.HasPrefix(pgErr.Code, "57P"): // connection exception, insufficient resources or operator intervention
+			return domain.NewError(domain.ErrUnavailable, err)
+		}
+		return err
+	}
+
+	if pgconn.Timeout(err) || isUnavailable(err) {
+		return domain.NewError(domain.ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// mongoError classifies a mongo driver error under a domain error kind.
+func mongoError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return domain.NewError(domain.ErrNotFound, err)
+	case mongo.IsDuplicateKeyError(err):
+		return domain.NewError(domain.ErrConflict, err)
+	case mongo.IsTimeout(err), mongo.IsNetworkError(err), errors.Is(err, mongo.ErrClientDisconnected), isUnavailable(err):
+		return domain.NewError(domain.ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// isUnavailable tells whether err is caused by an unreachable database.
+func isUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
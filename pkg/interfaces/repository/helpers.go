@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+)
+
+// withStorageDefaults replaces the missing lists and maps of a scan infos by
+// empty ones so that every backend stores and returns the same values.
+func withStorageDefaults(s domain.ScanInfos) domain.ScanInfos {
+	if s.Results == nil {
+		s.Results = []string{}
+	}
+
+	if s.Metadata == nil {
+		s.Metadata = map[string]interface{}{}
+	}
+
+	return s
+}
+
+// withImportDefaults prepares an imported scan infos. Unlike the saved ones,
+// it keeps its ID, version and timestamps. Records exported before the
+// versioning start at version 1 and missing timestamps are set to now.
+func withImportDefaults(s domain.ScanInfos) (domain.ScanInfos, error) {
+	if s.ID == "" {
+		return s, domain.NewError(domain.ErrValidation, errors.New("imported scan infos without id"))
+	}
+
+	if s.Version <= 0 {
+		s.Version = 1
+	}
+
+	now := time.Now().UTC()
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = now
+	}
+	if s.UpdatedAt.IsZero() {
+		s.UpdatedAt = s.CreatedAt
+	}
+
+	return withStorageDefaults(s), nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/metrics"
+	"go.uber.org/zap"
+)
+
+// InstrumentedScanInfosRepository decorates a scan infos repository to record
+// the latency and the errors of its calls and the scans it stores. Unexpected
+// errors are logged with the id of the request which led to the call.
+type InstrumentedScanInfosRepository struct {
+	logger  *zap.Logger
+	repo    domain.ScanInfosRepository
+	backend string
+	metrics *metrics.Metrics
+}
+
+// NewInstrumentedScanInfosRepository provides an instance of InstrumentedScanInfosRepository
+// structure. The backend names the decorated repository into the metrics.
+func NewInstrumentedScanInfosRepository(logger *zap.Logger, repo domain.ScanInfosRepository, backend string, m *metrics.Metrics) *InstrumentedScanInfosRepository {
+	return &InstrumentedScanInfosRepository{logger: logger, repo: repo, backend: backend, metrics: m}
+}
+
+// Save records the call then the stored scan infos.
+func (repo *InstrumentedScanInfosRepository) Save(ctx context.Context, s domain.ScanInfos) (string, error) {
+	start := time.Now()
+	id, err := repo.repo.Save(ctx, s)
+	repo.observe(ctx, "save", start, err)
+	if err == nil {
+		repo.metrics.ObserveScanIngested(s.CompanyID, len(s.Results), s.Error != "")
+	}
+	return id, err
+}
+
+// SaveBatch records the call then each stored scan infos.
+func (repo *InstrumentedScanInfosRepository) SaveBatch(ctx context.Context, scanInfos []domain.ScanInfos) ([]string, error) {
+	start := time.Now()
+	ids, err := repo.repo.SaveBatch(ctx, scanInfos)
+	repo.observe(ctx, "save_batch", start, err)
+	if err == nil {
+		for _, s := range scanInfos {
+			repo.metrics.ObserveScanIngested(s.CompanyID, len(s.Results), s.Error != "")
+		}
+	}
+	return ids, err
+}
+
+// Import records the call. The imported scan infos are not recorded as
+// ingested since they were already counted by the service which exported them.
+func (repo *InstrumentedScanInfosRepository) Import(ctx context.Context, scanInfos []domain.ScanInfos) (int, error) {
+	start := time.Now()
+	n, err := repo.repo.Import(ctx, scanInfos)
+	repo.observe(ctx, "import", start, err)
+	return n, err
+}
+
+func (repo *InstrumentedScanInfosRepository) FindByID(ctx context.Context, id string) (domain.ScanInfos, error) {
+	start := time.Now()
+	s, err := repo.repo.FindByID(ctx, id)
+	repo.observe(ctx, "find_by_id", start, err)
+	return s, err
+}
+
+func (repo *InstrumentedScanInfosRepository) UpdateByID(ctx context.Context, id string, s domain.ScanInfos) error {
+	start := time.Now()
+	err := repo.repo.UpdateByID(ctx, id, s)
+	repo.observe(ctx, "update_by_id", start, err)
+	return err
+}
+
+func (repo *InstrumentedScanInfosRepository) DeleteByID(ctx context.Context, id string, version int64) error {
+	start := time.Now()
+	err := repo.repo.DeleteByID(ctx, id, version)
+	repo.observe(ctx, "delete_by_id", start, err)
+	return err
+}
+
+func (repo *InstrumentedScanInfosRepository) List(ctx context.Context, query domain.ScanInfosQuery) (domain.ScanInfosPage, error) {
+	start := time.Now()
+	page, err := repo.repo.List(ctx, query)
+	repo.observe(ctx, "list", start, err)
+	return page, err
+}
+
+func (repo *InstrumentedScanInfosRepository) observe(ctx context.Context, operation string, start time.Time, err error) {
+	kind := errorKind(err)
+	repo.metrics.ObserveRepositoryCall(repo.backend, operation, time.Since(start), kind)
+	if kind == "internal" || kind == "unavailable" {
+		repo.logger.Error("scan infos repository call failed",
+			zap.String("requestid", domain.RequestIDFromContext(ctx)),
+			zap.String("backend", repo.backend),
+			zap.String("operation", operation),
+			zap.Error(err),
+		)
+	}
+}
+
+// errorKind names the domain error kind of err for the metrics labels. It
+// returns an empty string when err is nil.
+func errorKind(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, domain.ErrNotFound):
+		return "not_found"
+	case errors.Is(err, domain.ErrConflict):
+		return "conflict"
+	case errors.Is(err, domain.ErrValidation):
+		return "validation"
+	case errors.Is(err, domain.ErrPreconditionFailed):
+		return "precondition_failed"
+	case errors.Is(err, domain.ErrUnavailable):
+		return "unavailable"
+	default:
+		return "internal"
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// InMemoryAPIKeyRepository is a thread-safe API keys repository keeping all
+// keys into memory.
+type InMemoryAPIKeyRepository struct {
+	logger *zap.Logger
+	mock   *mockdb.Handler
+
+	mu   sync.RWMutex
+	keys map[string]domain.APIKey
+}
+
+// NewInMemoryAPIKeyRepository provides an instance of InMemoryAPIKeyRepository structure.
+func NewInMemoryAPIKeyRepository(logger *zap.Logger, h *mockdb.Handler) *InMemoryAPIKeyRepository {
+	return &InMemoryAPIKeyRepository{
+		logger: logger,
+		mock:   h,
+		keys:   make(map[string]domain.APIKey),
+	}
+}
+
+func (repo *InMemoryAPIKeyRepository) Save(ctx context.Context, k domain.APIKey) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	for _, existing := range repo.keys {
+		if existing.ID == k.ID || existing.Hash == k.Hash {
+			return domain.NewError(domain.ErrConflict, errors.New("could not save api key. duplicate key"))
+		}
+	}
+
+	k.Scopes = append([]string{}, k.Scopes...)
+	k.Roles = append([]string{}, k.Roles...)
+	k.CreatedAt = memoryTime(k.CreatedAt)
+	repo.keys[k.ID] = k
+	return nil
+}
+
+func (repo *InMemoryAPIKeyRepository) FindByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	for _, k := range repo.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return domain.APIKey{}, domain.NewError(domain.ErrNotFound, errors.New("could not find api key"))
+}
+
+func (repo *InMemoryAPIKeyRepository) List(ctx context.Context) ([]domain.APIKey, error) {
+	repo.mu.RLock()
+	keys := make([]domain.APIKey, 0, len(repo.keys))
+	for _, k := range repo.keys {
+		keys = append(keys, k)
+	}
+	repo.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// Revoke marks the key id as revoked. Revoking a key twice keeps its first
+// revocation time.
+func (repo *InMemoryAPIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	k, found := repo.keys[id]
+	if !found {
+		return domain.NewError(domain.ErrNotFound, errors.Errorf("could not revoke api key %s. no such key", id))
+	}
+
+	if k.RevokedAt == nil {
+		at = memoryTime(at)
+		k.RevokedAt = &at
+		repo.keys[id] = k
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// InMemoryIdempotencyRepository is a thread-safe idempotency keys repository
+// keeping all records into memory. Keys are only shared by the handlers of a
+// single instance.
+type InMemoryIdempotencyRepository struct {
+	logger *zap.Logger
+	mock   *mockdb.Handler
+
+	mu      sync.Mutex
+	records map[string]domain.IdempotencyRecord
+}
+
+// NewInMemoryIdempotencyRepository provides an instance of InMemoryIdempotencyRepository structure.
+func NewInMemoryIdempotencyRepository(logger *zap.Logger, h *mockdb.Handler) *InMemoryIdempotencyRepository {
+	return &InMemoryIdempotencyRepository{
+		logger:  logger,
+		mock:    h,
+		records: make(map[string]domain.IdempotencyRecord),
+	}
+}
+
+func (repo *InMemoryIdempotencyRepository) Reserve(ctx context.Context, r domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if existing, found := repo.records[r.Key]; found && existing.ExpiresAt.After(r.CreatedAt) {
+		abandoned := !existing.Completed() && !existing.LockedUntil.After(r.CreatedAt) && existing.RequestHash == r.RequestHash
+		if !abandoned {
+			return existing, false, nil
+		}
+	}
+
+	r.StatusCode, r.Response = 0, nil
+	repo.records[r.Key] = r
+	return r, true, nil
+}
+
+func (repo *InMemoryIdempotencyRepository) Complete(ctx context.Context, key string, statusCode int, response []byte) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	r, found := repo.records[key]
+	if !found {
+		return domain.NewError(domain.ErrNotFound, errors.Errorf("could not complete idempotency key %s. no such key", key))
+	}
+
+	r.StatusCode = statusCode
+	r.Response = append([]byte(nil), response...)
+	repo.records[key] = r
+	return nil
+}
+
+func (repo *InMemoryIdempotencyRepository) Release(ctx context.Context, key string) error {
+	repo.mu.Lock()
+	delete(repo.records, key)
+	repo.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
+	"go.uber.org/zap"
+)
+
+// InMemoryQuotaRepository is a thread-safe quota usages repository keeping all
+// counters into memory. Usages are only shared by the handlers of a single
+// instance and are lost on restart.
+type InMemoryQuotaRepository struct {
+	logger *zap.Logger
+	mock   *mockdb.Handler
+
+	mu     sync.Mutex
+	usages map[domain.QuotaUsage]int64
+}
+
+// NewInMemoryQuotaRepository provides an instance of InMemoryQuotaRepository structure.
+func NewInMemoryQuotaRepository(logger *zap.Logger, h *mockdb.Handler) *InMemoryQuotaRepository {
+	return &InMemoryQuotaRepository{
+		logger: logger,
+		mock:   h,
+		usages: make(map[domain.QuotaUsage]int64),
+	}
+}
+
+func (repo *InMemoryQuotaRepository) Consume(ctx context.Context, companyID, day string, n, limit int64) (int64, bool, error) {
+	key := domain.QuotaUsage{CompanyID: companyID, Day: day}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	count := repo.usages[key]
+	if limit > 0 && count+n > limit {
+		return count, false, nil
+	}
+
+	repo.usages[key] = count + n
+	return count + n, true, nil
+}
+
+func (repo *InMemoryQuotaRepository) Refund(ctx context.Context, companyID, day string, n int64) error {
+	key := domain.QuotaUsage{CompanyID: companyID, Day: day}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if count, found := repo.usages[key]; found {
+		if count -= n; count < 0 {
+			count = 0
+		}
+		repo.usages[key] = count
+	}
+	return nil
+}
+
+// List returns the usages of the day ordered by company.
+func (repo *InMemoryQuotaRepository) List(ctx context.Context, day string) ([]domain.QuotaUsage, error) {
+	usages := []domain.QuotaUsage{}
+	repo.mu.Lock()
+	for key, count := range repo.usages {
+		if key.Day == day {
+			key.Count = count
+			usages = append(usages, key)
+		}
+	}
+	repo.mu.Unlock()
+
+	sort.Slice(usages, func(i, j int) bool { return usages[i].CompanyID < usages[j].CompanyID })
+	return usages, nil
+}
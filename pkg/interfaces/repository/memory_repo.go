@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/mockdb"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// InMemoryScanInfosRepository is a thread-safe scan infos repository keeping
+// all records into memory. It mimics the postgres repository semantics and is
+// meant for local development, demos and tests.
+type InMemoryScanInfosRepository struct {
+	logger *zap.Logger
+	mock   *mockdb.Handler
+
+	mu    sync.RWMutex
+	infos map[string]domain.ScanInfos
+}
+
+// NewInMemoryScanInfosRepository provides an instance of InMemoryScanInfosRepository structure.
+func NewInMemoryScanInfosRepository(logger *zap.Logger, h *mockdb.Handler) *InMemoryScanInfosRepository {
+	return &InMemoryScanInfosRepository{
+		logger: logger,
+		mock:   h,
+		infos:  make(map[string]domain.ScanInfos),
+	}
+}
+
+// Save will create a new scan infos with a generated ID and not update existing one.
+func (repo *InMemoryScanInfosRepository) Save(ctx context.Context, s domain.ScanInfos) (string, error) {
+	uid, err := uuid.NewV4()
+	if err != nil {
+		return "", errors.Wrap(err, "could not save scan infos. unable to generate uuid")
+	}
+
+	s, err = memoryCopy(s)
+	if err != nil {
+		return "", errors.Wrap(err, "could not save scan infos")
+	}
+
+	s.ID = uid.String()
+	s.Version = 1
+	s.CreatedAt = memoryTime(s.CreatedAt)
+	s.UpdatedAt = memoryTime(s.UpdatedAt)
+
+	repo.mu.Lock()
+	repo.infos[s.ID] = s
+	repo.mu.Unlock()
+	return s.ID, nil
+}
+
+// SaveBatch creates all the scan infos at once. Either all of them are stored
+// or none. IDs are returned in the order of the given scan infos.
+func (repo *InMemoryScanInfosRepository) SaveBatch(ctx context.Context, infos []domain.ScanInfos) ([]string, error) {
+	ids := make([]string, len(infos))
+	items := make([]domain.ScanInfos, len(infos))
+	for i := range infos {
+		uid, err := uuid.NewV4()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not save scan infos batch. unable to generate uuid")
+		}
+
+		s, err := memoryCopy(infos[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not save scan infos batch. item %d", i)
+		}
+
+		s.ID = uid.String()
+		s.Version = 1
+		s.CreatedAt = memoryTime(s.CreatedAt)
+		s.UpdatedAt = memoryTime(s.UpdatedAt)
+		ids[i], items[i] = s.ID, s
+	}
+
+	repo.mu.Lock()
+	for _, s := range items {
+		repo.infos[s.ID] = s
+	}
+	repo.mu.Unlock()
+	return ids, nil
+}
+
+// Import stores the scan infos with their IDs at once. Those whose ID is
+// already stored are skipped.
+func (repo *InMemoryScanInfosRepository) Import(ctx context.Context, infos []domain.ScanInfos) (int, error) {
+	items := make([]domain.ScanInfos, len(infos))
+	for i := range infos {
+		s, err := withImportDefaults(infos[i])
+		if err != nil {
+			return 0, errors.Wrapf(err, "cannot import scan infos. item %d", i)
+		}
+
+		s, err = memoryCopy(s)
+		if err != nil {
+			return 0, errors.Wrapf(err, "could not import scan infos. item %d", i)
+		}
+
+		s.CreatedAt = memoryTime(s.CreatedAt)
+		s.UpdatedAt = memoryTime(s.UpdatedAt)
+		items[i] = s
+	}
+
+	var imported int
+	repo.mu.Lock()
+	for _, s := range items {
+		if _, found := repo.infos[s.ID]; found {
+			continue
+		}
+		repo.infos[s.ID] = s
+		imported++
+	}
+	repo.mu.Unlock()
+	return imported, nil
+}
+
+func (repo *InMemoryScanInfosRepository) FindByID(ctx context.Context, id string) (domain.ScanInfos, error) {
+	repo.mu.RLock()
+	s, found := repo.infos[id]
+	repo.mu.RUnlock()
+	if !found {
+		return domain.ScanInfos{}, domain.NewError(domain.ErrNotFound, errors.Errorf("could not find scan infos with ID: %s", id))
+	}
+
+	return memoryCopy(s)
+}
+
+// List returns a page of scan infos matching the query along with the total
+// number of matching records.
+func (repo *InMemoryScanInfosRepository) List(ctx context.Context, q domain.ScanInfosQuery) (domain.ScanInfosPage, error) {
+	page := domain.ScanInfosPage{Infos: []domain.ScanInfos{}}
+	if err := q.Normalize(); err != nil {
+		return page, errors.Wrap(err, "cannot list scan infos")
+	}
+
+	cursor, err := q.DecodeCursor()
+	if err != nil {
+		return page, errors.Wrap(err, "cannot list scan infos")
+	}
+
+	repo.mu.RLock()
+	matches := make([]domain.ScanInfos, 0, len(repo.infos))
+	for _, s := range repo.infos {
+		if q.Filter.Matches(s) {
+			matches = append(matches, s)
+		}
+	}
+	repo.mu.RUnlock()
+
+	if !q.SkipTotal {
+		page.Total = int64(len(matches))
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return memoryBefore(matches[i], matches[j], q.SortBy, q.Descending)
+	})
+
+	if cursor != nil {
+		start := sort.Search(len(matches), func(i int) bool {
+			return memoryAfterCursor(matches[i], q.SortBy, q.Descending, cursor.Value, cursor.ID)
+		})
+		matches = matches[start:]
+	}
+
+	if len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+		page.NextCursor = q.NextCursor(matches[q.Limit-1])
+	}
+
+	for _, s := range matches {
+		s, err := memoryCopy(s)
+		if err != nil {
+			return page, errors.Wrap(err, "could not list scan infos")
+		}
+		page.Infos = append(page.Infos, s)
+	}
+
+	return page, nil
+}
+
+// UpdateByID updates a scan infos record by its ID.
+func (repo *InMemoryScanInfosRepository) UpdateByID(ctx context.Context, id string, s domain.ScanInfos) error {
+	keepFindings := s.Findings == nil
+	s, err := memoryCopy(s)
+	if err != nil {
+		return errors.Wrapf(err, "cannot update scan infos with ID: %s", id)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	current, found := repo.infos[id]
+	if !found {
+		return domain.NewError(domain.ErrNotFound, errors.Errorf("cannot update scan infos with ID: %s. no such record", id))
+	}
+
+	if s.Version != 0 && s.Version != current.Version {
+		return domain.NewError(domain.ErrPreconditionFailed, errors.Errorf("cannot update scan infos with ID: %s. version does not match", id))
+	}
+
+	s.ID = id
+	s.Version = current.Version + 1
+	s.CreatedAt = current.CreatedAt
+	s.UpdatedAt = memoryTime(time.Now())
+	if keepFindings {
+		s.Findings = current.Findings
+	}
+
+	repo.infos[id] = s
+	return nil
+}
+
+// DeleteByID deletes a scan infos record by its ID.
+func (repo *InMemoryScanInfosRepository) DeleteByID(ctx context.Context, id string, version int64) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	current, found := repo.infos[id]
+	if !found {
+		return domain.NewError(domain.ErrNotFound, errors.Errorf("could not delete scan infos with ID: %s. no such record", id))
+	}
+
+	if version != 0 && version != current.Version {
+		return domain.NewError(domain.ErrPreconditionFailed, errors.Errorf("could not delete scan infos with ID: %s. version does not match", id))
+	}
+
+	delete(repo.infos, id)
+	return nil
+}
+
+// memoryCopy returns a deep copy of a scan infos. Metadata and findings
+// properties go through a json round trip like they do with postgres jsonb
+// columns, and missing lists are stored empty like with postgres defaults.
+func memoryCopy(s domain.ScanInfos) (domain.ScanInfos, error) {
+	var err error
+	s.Results = append([]string{}, s.Results...)
+	if s.Metadata, err = memoryJSONCopy(s.Metadata); err != nil {
+		return s, err
+	}
+
+	findings := make([]domain.Finding, len(s.Findings))
+	copy(findings, s.Findings)
+	for i := range findings {
+		if findings[i].Properties, err = memoryJSONCopy(findings[i].Properties); err != nil {
+			return s, err
+		}
+	}
+	s.Findings = findings
+	return s, nil
+}
+
+func memoryJSONCopy(m map[string]interface{}) (map[string]interface{}, error) {
+	res := map[string]interface{}{}
+	if m == nil {
+		return res, nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &res)
+	return res, err
+}
+
+// memoryTime stores timestamps with the postgres precision.
+func memoryTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Microsecond)
+}
+
+// memoryBefore tells whether a is listed before b for the given sort order.
+// The ID breaks the ties like it does with the keyset pagination of postgres.
+func memoryBefore(a, b domain.ScanInfos, sortBy string, descending bool) bool {
+	va, vb := domain.SortValue(a, sortBy), domain.SortValue(b, sortBy)
+	if va == vb {
+		if descending {
+			return a.ID > b.ID
+		}
+		return a.ID < b.ID
+	}
+
+	if descending {
+		return va > vb
+	}
+	return va < vb
+}
+
+// memoryAfterCursor tells whether s is listed after the cursor position.
+func memoryAfterCursor(s domain.ScanInfos, sortBy string, descending bool, value int64, id string) bool {
+	v := domain.SortValue(s, sortBy)
+	if v == value {
+		if descending {
+			return s.ID < id
+		}
+		return s.ID > id
+	}
+
+	if descending {
+		return v < value
+	}
+	return v > value
+}
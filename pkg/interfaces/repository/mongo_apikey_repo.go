@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	mongodb "github.com/jeamon/backend-api/pkg/infrastructure/mongo"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type MongoAPIKeyRepository struct {
+	logger *zap.Logger
+	mgo    *mongodb.Handler
+	dbname string
+}
+
+// NewMongoAPIKeyRepository provides an instance of MongoAPIKeyRepository structure.
+func NewMongoAPIKeyRepository(logger *zap.Logger, h *mongodb.Handler, dbname string) *MongoAPIKeyRepository {
+	return &MongoAPIKeyRepository{
+		logger: logger,
+		mgo:    h,
+		dbname: dbname,
+	}
+}
+
+func (repo *MongoAPIKeyRepository) Save(ctx context.Context, k domain.APIKey) error {
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("api_keys")
+	_, err := collection.InsertOne(ctx, k)
+	return errors.Wrap(mongoError(err), "could not save api key")
+}
+
+func (repo *MongoAPIKeyRepository) FindByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	var k domain.APIKey
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("api_keys")
+	err := collection.FindOne(ctx, bson.M{"key_hash": hash}).Decode(&k)
+	return k, errors.Wrap(mongoError(err), "could not find api key")
+}
+
+func (repo *MongoAPIKeyRepository) List(ctx context.Context) ([]domain.APIKey, error) {
+	keys := []domain.APIKey{}
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("api_keys")
+	res, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return keys, errors.Wrap(mongoError(err), "could not list api keys")
+	}
+
+	err = res.All(ctx, &keys)
+	return keys, errors.Wrap(mongoError(err), "could not list api keys")
+}
+
+// Revoke marks the key id as revoked. Revoking a key twice keeps its first
+// revocation time.
+func (repo *MongoAPIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("api_keys")
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at}},
+	)
+	if err != nil {
+		return errors.Wrapf(mongoError(err), "could not revoke api key %s", id)
+	}
+
+	count, err := collection.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return errors.Wrapf(mongoError(err), "could not revoke api key %s", id)
+	}
+
+	if count == 0 {
+		return domain.NewError(domain.ErrNotFound, errors.Errorf("could not revoke api key %s. no such key", id))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	mongodb "github.com/jeamon/backend-api/pkg/infrastructure/mongo"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type MongoIdempotencyRepository struct {
+	logger *zap.Logger
+	mgo    *mongodb.Handler
+	dbname string
+}
+
+// NewMongoIdempotencyRepository provides an instance of MongoIdempotencyRepository structure.
+func NewMongoIdempotencyRepository(logger *zap.Logger, h *mongodb.Handler, dbname string) *MongoIdempotencyRepository {
+	return &MongoIdempotencyRepository{
+		logger: logger,
+		mgo:    h,
+		dbname: dbname,
+	}
+}
+
+// Reserve upserts the record only over an expired or abandoned document with
+// the same key. When a live document exists, the upsert fails on the duplicate key and
+// that document is returned instead.
+func (repo *MongoIdempotencyRepository) Reserve(ctx context.Context, r domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error) {
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("idempotency_keys")
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": r.Key, "$or": bson.A{
+			bson.M{"expires_at": bson.M{"$lte": r.CreatedAt}},
+			bson.M{"status_code": 0, "locked_until": bson.M{"$lte": r.CreatedAt}, "request_hash": r.RequestHash},
+		}},
+		bson.M{"$set": bson.M{
+			"request_hash": r.RequestHash,
+			"status_code":  0,
+			"response":     nil,
+			"created_at":   r.CreatedAt,
+			"expires_at":   r.ExpiresAt,
+			"locked_until": r.LockedUntil,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err == nil {
+		return r, true, nil
+	}
+
+	if !mongo.IsDuplicateKeyError(err) {
+		return r, false, errors.Wrapf(mongoError(err), "could not reserve idempotency key %s", r.Key)
+	}
+
+	var existing domain.IdempotencyRecord
+	err = collection.FindOne(ctx, bson.M{"_id": r.Key}).Decode(&existing)
+	return existing, false, errors.Wrapf(mongoError(err), "could not find idempotency key %s", r.Key)
+}
+
+func (repo *MongoIdempotencyRepository) Complete(ctx context.Context, key string, statusCode int, response []byte) error {
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("idempotency_keys")
+	res, err := collection.UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"status_code": statusCode, "response": response}},
+	)
+	if err != nil {
+		return errors.Wrapf(mongoError(err), "could not complete idempotency key %s", key)
+	}
+
+	if res.MatchedCount == 0 {
+		return domain.NewError(domain.ErrNotFound, errors.Errorf("could not complete idempotency key %s. no such key", key))
+	}
+	return nil
+}
+
+func (repo *MongoIdempotencyRepository) Release(ctx context.Context, key string) error {
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("idempotency_keys")
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": key})
+	return errors.Wrapf(mongoError(err), "could not release idempotency key %s", key)
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jeamon/backend-api/pkg/domain"
+	mongodb "github.com/jeamon/backend-api/pkg/infrastructure/mongo"
+	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+)
+
+type MongoQuotaRepository struct {
+	logger *zap.Logger
+	mgo    *mongodb.Handler
+	dbname string
+}
+
+// NewMongoQuotaRepository provides an instance of MongoQuotaRepository structure.
+func NewMongoQuotaRepository(logger *zap.Logger, h *mongodb.Handler, dbname string) *MongoQuotaRepository {
+	return &MongoQuotaRepository{
+		logger: logger,
+		mgo:    h,
+		dbname: dbname,
+	}
+}
+
+// mongoQuotaID identifies the usage document of a company for a day.
+func mongoQuotaID(companyID, day string) string {
+	return companyID + "|" + day
+}
+
+// Consume upserts the usage only when the limit is not exceeded. When the
+// document exists above the limit, the upsert fails on the duplicate key.
+// Concurrent first upserts of a day can fail the same way, so the call is
+// retried while the limit allows it.
+func (repo *MongoQuotaRepository) Consume(ctx context.Context, companyID, day string, n, limit int64) (int64, bool, error) {
+	if limit > 0 && n > limit {
+		count, err := repo.count(ctx, companyID, day)
+		return count, false, err
+	}
+
+	filter := bson.M{"_id": mongoQuotaID(companyID, day)}
+	if limit > 0 {
+		filter["count"] = bson.M{"$lte": limit - n}
+	}
+
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("quota_usages")
+	for attempt := 0; ; attempt++ {
+		var usage domain.QuotaUsage
+		err := collection.FindOneAndUpdate(ctx, filter,
+			bson.M{
+				"$inc":         bson.M{"count": n},
+				"$setOnInsert": bson.M{"company_id": companyID, "day": day},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&usage)
+		if err == nil {
+			return usage.Count, true, nil
+		}
+
+		if !mongo.IsDuplicateKeyError(err) {
+			return 0, false, errors.Wrapf(mongoError(err), "could not consume quota of company %s", companyID)
+		}
+
+		count, err := repo.count(ctx, companyID, day)
+		if err != nil || count+n > limit || attempt > 0 {
+			return count, false, err
+		}
+	}
+}
+
+func (repo *MongoQuotaRepository) count(ctx context.Context, companyID, day string) (int64, error) {
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("quota_usages")
+	var usage domain.QuotaUsage
+	err := collection.FindOne(ctx, bson.M{"_id": mongoQuotaID(companyID, day)}).Decode(&usage)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return usage.Count, errors.Wrapf(mongoError(err), "could not get quota usage of company %s", companyID)
+}
+
+func (repo *MongoQuotaRepository) Refund(ctx context.Context, companyID, day string, n int64) error {
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("quota_usages")
+	// the pipeline update keeps the count from going below zero.
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": mongoQuotaID(companyID, day)}, mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"count": bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$count", n}}}}}}},
+	})
+	return errors.Wrapf(mongoError(err), "could not refund quota of company %s", companyID)
+}
+
+func (repo *MongoQuotaRepository) List(ctx context.Context, day string) ([]domain.QuotaUsage, error) {
+	usages := []domain.QuotaUsage{}
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("quota_usages")
+	cursor, err := collection.Find(ctx, bson.M{"day": day}, options.Find().SetSort(bson.M{"company_id": 1}))
+	if err != nil {
+		return usages, errors.Wrap(mongoError(err), "could not list quota usages")
+	}
+
+	err = cursor.All(ctx, &usages)
+	return usages, errors.Wrap(mongoError(err), "could not list quota usages")
+}
@@ -2,13 +2,16 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/jeamon/backend-api/pkg/domain"
 	mongodb "github.com/jeamon/backend-api/pkg/infrastructure/mongo"
 	"github.com/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
-	"gopkg.in/mgo.v2/bson"
 )
 
 type MongoScanInfosRepository struct {
@@ -31,53 +34,312 @@ func (repo *MongoScanInfosRepository) Save(ctx context.Context, s domain.ScanInf
 	if err != nil {
 		return s.ID, errors.Wrap(err, "could not save scan infos. unable to generate uuid")
 	}
+	s = withStorageDefaults(s)
 	s.ID = uid.String()
+	s.Version = 1
+	if s.Findings == nil {
+		s.Findings = []domain.Finding{}
+	}
+
 	collection := repo.mgo.Client.Database(repo.dbname).Collection("scan_infos")
 	_, err = collection.InsertOne(ctx, s)
 	if err != nil {
-		return s.ID, errors.Wrapf(err, "could not save scan infos")
+		return s.ID, errors.Wrapf(mongoError(err), "could not save scan infos")
 	}
 	return s.ID, nil
 }
 
+// SaveBatch creates all the scan infos with a single ordered insert. Since a
+// standalone mongo server does not support transactions, the documents already
+// inserted are removed when the insert fails so that either all of them are
+// stored or none. IDs are returned in the order of the given scan infos.
+func (repo *MongoScanInfosRepository) SaveBatch(ctx context.Context, infos []domain.ScanInfos) ([]string, error) {
+	ids := make([]string, len(infos))
+	docs := make([]interface{}, len(infos))
+	for i := range infos {
+		uid, err := uuid.NewV4()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not save scan infos batch. unable to generate uuid")
+		}
+
+		s := withStorageDefaults(infos[i])
+		s.ID = uid.String()
+		s.Version = 1
+		if s.Findings == nil {
+			s.Findings = []domain.Finding{}
+		}
+		ids[i], docs[i] = s.ID, s
+	}
+
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("scan_infos")
+	_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(true))
+	if err != nil {
+		if _, derr := collection.DeleteMany(context.Background(), bson.M{"_id": bson.M{"$in": ids}}); derr != nil {
+			repo.logger.Error("failed to remove partially saved scan infos batch", zap.String("requestid", domain.RequestIDFromContext(ctx)), zap.Strings("ids", ids), zap.Error(derr))
+		}
+		return nil, errors.Wrap(mongoError(err), "could not save scan infos batch")
+	}
+
+	return ids, nil
+}
+
+// Import inserts all the scan infos with their IDs with a single unordered
+// insert. The documents rejected because their ID is already stored are
+// skipped. The others are kept when the insert partially fails since
+// importing them again is harmless.
+func (repo *MongoScanInfosRepository) Import(ctx context.Context, infos []domain.ScanInfos) (int, error) {
+	docs := make([]interface{}, len(infos))
+	for i := range infos {
+		s, err := withImportDefaults(infos[i])
+		if err != nil {
+			return 0, errors.Wrapf(err, "cannot import scan infos. item %d", i)
+		}
+
+		if s.Findings == nil {
+			s.Findings = []domain.Finding{}
+		}
+		docs[i] = s
+	}
+
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("scan_infos")
+	_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err == nil {
+		return len(docs), nil
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return 0, errors.Wrap(mongoError(err), "could not import scan infos")
+	}
+
+	for _, we := range bulkErr.WriteErrors {
+		if we.Code != 11000 { // duplicate key
+			return 0, errors.Wrap(mongoError(err), "could not import scan infos")
+		}
+	}
+	return len(docs) - len(bulkErr.WriteErrors), nil
+}
+
 func (repo *MongoScanInfosRepository) FindByID(ctx context.Context, id string) (domain.ScanInfos, error) {
 	s := domain.ScanInfos{}
 	collection := repo.mgo.Client.Database(repo.dbname).Collection("scan_infos")
-	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(s)
-	return s, errors.Wrapf(err, "could not find scan infos with ID: %s", id)
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&s)
+	if err != nil {
+		return s, errors.Wrapf(mongoError(err), "could not find scan infos with ID: %s", id)
+	}
+
+	return mongoScanInfosDefaults(s), nil
 }
 
-func (repo *MongoScanInfosRepository) FindAll(ctx context.Context) ([]domain.ScanInfos, error) {
-	var res []domain.ScanInfos
+// List returns a page of scan infos matching the query along with the total
+// number of matching documents.
+func (repo *MongoScanInfosRepository) List(ctx context.Context, q domain.ScanInfosQuery) (domain.ScanInfosPage, error) {
+	page := domain.ScanInfosPage{Infos: []domain.ScanInfos{}}
+	if err := q.Normalize(); err != nil {
+		return page, errors.Wrap(err, "cannot list scan infos")
+	}
+
+	cursor, err := q.DecodeCursor()
+	if err != nil {
+		return page, errors.Wrap(err, "cannot list scan infos")
+	}
+
 	collection := repo.mgo.Client.Database(repo.dbname).Collection("scan_infos")
-	cursor, err := collection.Find(ctx, bson.M{})
+	filter := mongoScanInfosFilter(q.Filter)
+	if !q.SkipTotal {
+		page.Total, err = collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return page, errors.Wrap(mongoError(err), "could not count scan infos")
+		}
+	}
+
+	direction := 1
+	if q.Descending {
+		direction = -1
+	}
+
+	if cursor != nil {
+		filter = bson.M{"$and": bson.A{filter, mongoScanInfosAfter(q.SortBy, q.Descending, cursor)}}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: q.SortBy, Value: direction}, {Key: "_id", Value: direction}}).
+		SetLimit(int64(q.Limit) + 1)
+	res, err := collection.Find(ctx, filter, opts)
 	if err != nil {
-		return res, errors.Wrapf(err, "could not find all scan infos")
+		return page, errors.Wrap(mongoError(err), "could not list scan infos")
 	}
 
-	defer cursor.Close(ctx)
-	for cursor.Next(context.TODO()) {
+	defer res.Close(ctx)
+	for res.Next(ctx) {
 		var s domain.ScanInfos
-		if err := cursor.Decode(&s); err != nil {
-			return nil, err
+		if err := res.Decode(&s); err != nil {
+			return page, errors.Wrap(err, "could not decode scan infos")
 		}
-		res = append(res, s)
+		page.Infos = append(page.Infos, mongoScanInfosDefaults(s))
+	}
+
+	if err := res.Err(); err != nil {
+		return page, errors.Wrap(mongoError(err), "could not list scan infos")
+	}
+
+	if len(page.Infos) > q.Limit {
+		page.Infos = page.Infos[:q.Limit]
+		page.NextCursor = q.NextCursor(page.Infos[q.Limit-1])
 	}
-	return res, nil
+
+	return page, nil
+}
+
+// mongoScanInfosFilter converts a listing filter into a query document.
+func mongoScanInfosFilter(f domain.ScanInfosFilter) bson.M {
+	filter := bson.M{}
+	for _, c := range []struct{ field, value string }{
+		{"company_id", f.CompanyID},
+		{"client_id", f.ClientID},
+		{"repository_url", f.RepositoryURL},
+		{"commit_id", f.CommitID},
+		{"tag_id", f.TagID},
+	} {
+		if c.value != "" {
+			filter[c.field] = c.value
+		}
+	}
+
+	if r := mongoRange(f.StartedFrom, f.StartedTo); len(r) > 0 {
+		filter["started_at"] = r
+	}
+	if r := mongoRange(f.CompletedFrom, f.CompletedTo); len(r) > 0 {
+		filter["completed_at"] = r
+	}
+
+	if f.HasError != nil {
+		if *f.HasError {
+			filter["error"] = bson.M{"$ne": ""}
+		} else {
+			filter["error"] = ""
+		}
+	}
+
+	return filter
+}
+
+// mongoRange builds an inclusive range condition. Zero bounds are ignored.
+func mongoRange(from, to int64) bson.M {
+	r := bson.M{}
+	if from != 0 {
+		r["$gte"] = from
+	}
+	if to != 0 {
+		r["$lte"] = to
+	}
+	return r
+}
+
+// mongoScanInfosAfter builds the keyset condition selecting the documents
+// located after the cursor for the given sort order.
+func mongoScanInfosAfter(sortBy string, descending bool, c *domain.ScanInfosCursor) bson.M {
+	op := "$gt"
+	if descending {
+		op = "$lt"
+	}
+
+	value := c.ColumnValue()
+	return bson.M{"$or": bson.A{
+		bson.M{sortBy: bson.M{op: value}},
+		bson.M{sortBy: value, "_id": bson.M{op: c.ID}},
+	}}
 }
 
 func (repo *MongoScanInfosRepository) UpdateByID(ctx context.Context, id string, s domain.ScanInfos) error {
 	collection := repo.mgo.Client.Database(repo.dbname).Collection("scan_infos")
-	update := bson.M{"$set": bson.M{"username": s.Username, "company_id": s.CompanyID}}
-	_, err := collection.UpdateOne(
-		ctx, bson.M{"_id": s.ID},
+	s = withStorageDefaults(s)
+	fields := bson.M{
+		"company_id":     s.CompanyID,
+		"client_id":      s.ClientID,
+		"username":       s.Username,
+		"repository_url": s.RepositoryURL,
+		"commit_id":      s.CommitID,
+		"tag_id":         s.TagID,
+		"results":        s.Results,
+		"started_at":     s.StartedAt,
+		"completed_at":   s.CompletedAt,
+		"sent_at":        s.SentAt,
+		"updated_at":     time.Now().UTC(),
+		"error":          s.Error,
+		"metadata":       s.Metadata,
+	}
+	if s.Findings != nil {
+		fields["findings"] = s.Findings
+	}
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+	res, err := collection.UpdateOne(
+		ctx, mongoVersionedID(id, s.Version),
 		update,
 	)
-	return errors.Wrapf(err, "cannot update scan infos with ID: %s", id)
+	if err != nil {
+		return errors.Wrapf(mongoError(err), "cannot update scan infos with ID: %s", id)
+	}
+
+	if res.MatchedCount == 0 {
+		return errors.Wrapf(repo.missingReason(ctx, id), "cannot update scan infos with ID: %s", id)
+	}
+
+	return nil
+}
+
+func (repo *MongoScanInfosRepository) DeleteByID(ctx context.Context, id string, version int64) error {
+	collection := repo.mgo.Client.Database(repo.dbname).Collection("scan_infos")
+	res, err := collection.DeleteOne(ctx, mongoVersionedID(id, version))
+	if err != nil {
+		return errors.Wrapf(mongoError(err), "could not delete scan infos with ID: %s", id)
+	}
+
+	if res.DeletedCount == 0 {
+		return errors.Wrapf(repo.missingReason(ctx, id), "could not delete scan infos with ID: %s", id)
+	}
+
+	return nil
 }
 
-func (repo *MongoScanInfosRepository) DeleteByID(ctx context.Context, id string) error {
+// missingReason explains why a versioned write did not affect the document:
+// either it does not exist or its version changed.
+func (repo *MongoScanInfosRepository) missingReason(ctx context.Context, id string) error {
 	collection := repo.mgo.Client.Database(repo.dbname).Collection("scan_infos")
-	_, err := collection.DeleteOne(ctx, bson.M{"_id": id})
-	return errors.Wrapf(err, "could not delete scan infos with ID: %s", id)
+	count, err := collection.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return mongoError(err)
+	}
+
+	if count > 0 {
+		return domain.NewError(domain.ErrPreconditionFailed, errors.New("scan infos document version does not match"))
+	}
+	return domain.NewError(domain.ErrNotFound, errors.New("no such scan infos document"))
+}
+
+// mongoVersionedID selects a document by its ID and, when the version is not
+// zero, by its version. Documents stored before versioning are at version 1.
+func mongoVersionedID(id string, version int64) bson.M {
+	switch version {
+	case 0:
+		return bson.M{"_id": id}
+	case 1:
+		return bson.M{"_id": id, "$or": bson.A{
+			bson.M{"version": 1},
+			bson.M{"version": bson.M{"$exists": false}},
+		}}
+	}
+	return bson.M{"_id": id, "version": version}
+}
+
+// mongoScanInfosDefaults fills the lists missing from documents stored
+// before they were introduced.
+func mongoScanInfosDefaults(s domain.ScanInfos) domain.ScanInfos {
+	if s.Findings == nil {
+		s.Findings = []domain.Finding{}
+	}
+	if s.Version == 0 {
+		s.Version = 1
+	}
+	return withStorageDefaults(s)
 }
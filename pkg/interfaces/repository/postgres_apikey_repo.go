@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/postgres"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type PostgresAPIKeyRepository struct {
+	logger *zap.Logger
+	pg     *postgres.Handler
+}
+
+// NewPostgresAPIKeyRepository provides an instance of PostgresAPIKeyRepository structure.
+func NewPostgresAPIKeyRepository(logger *zap.Logger, h *postgres.Handler) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{
+		logger: logger,
+		pg:     h,
+	}
+}
+
+func (repo PostgresAPIKeyRepository) Save(ctx context.Context, k domain.APIKey) error {
+	sql, args, err := psql.Insert("data.api_keys").SetMap(
+		map[string]interface{}{
+			"id":         k.ID,
+			"name":       k.Name,
+			"key_hash":   k.Hash,
+			"company_id": k.CompanyID,
+			"scopes":     k.Scopes,
+			"roles":      k.Roles,
+			"created_at": k.CreatedAt,
+		}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "cannot save api key. failed to build query statement")
+	}
+
+	_, err = repo.pg.PGx.Exec(ctx, sql, args...)
+	return errors.Wrap(postgresError(err), "could not save api key")
+}
+
+func (repo PostgresAPIKeyRepository) FindByHash(ctx context.Context, hash string) (domain.APIKey, error) {
+	var k domain.APIKey
+	sql, args, err := psql.Select("*").From("data.api_keys").Where(sq.Eq{"key_hash": hash}).ToSql()
+	if err != nil {
+		return k, errors.Wrap(err, "cannot get api key. failed to build query statement")
+	}
+
+	err = pgxscan.Get(ctx, repo.pg.PGx, &k, sql, args...)
+	return k, errors.Wrap(postgresError(err), "could not find api key")
+}
+
+func (repo PostgresAPIKeyRepository) List(ctx context.Context) ([]domain.APIKey, error) {
+	keys := []domain.APIKey{}
+	sql, args, err := psql.Select("*").From("data.api_keys").OrderBy("created_at", "id").ToSql()
+	if err != nil {
+		return keys, errors.Wrap(err, "cannot list api keys. failed to build query statement")
+	}
+
+	err = pgxscan.Select(ctx, repo.pg.PGx, &keys, sql, args...)
+	return keys, errors.Wrap(postgresError(err), "could not list api keys")
+}
+
+// Revoke marks the key id as revoked. Revoking a key twice keeps its first
+// revocation time.
+func (repo PostgresAPIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	sql, args, err := psql.Update("data.api_keys").
+		Set("revoked_at", sq.Expr("COALESCE(revoked_at, ?)", at)).
+		Where(sq.Eq{"id": id}).ToSql()
+	if err != nil {
+		return errors.Wrapf(err, "cannot revoke api key %s. failed to build query statement", id)
+	}
+
+	tag, err := repo.pg.PGx.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrapf(postgresError(err), "could not revoke api key %s", id)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return domain.NewError(domain.ErrNotFound, errors.Errorf("could not revoke api key %s. no such key", id))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/postgres"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type PostgresIdempotencyRepository struct {
+	logger *zap.Logger
+	pg     *postgres.Handler
+}
+
+// NewPostgresIdempotencyRepository provides an instance of PostgresIdempotencyRepository structure.
+func NewPostgresIdempotencyRepository(logger *zap.Logger, h *postgres.Handler) *PostgresIdempotencyRepository {
+	return &PostgresIdempotencyRepository{
+		logger: logger,
+		pg:     h,
+	}
+}
+
+// Reserve inserts the record or replaces an expired or abandoned one with the
+// same key in a single statement so that concurrent requests cannot both
+// reserve a key.
+func (repo PostgresIdempotencyRepository) Reserve(ctx context.Context, r domain.IdempotencyRecord) (domain.IdempotencyRecord, bool, error) {
+	sql, args, err := psql.Insert("data.idempotency_keys").SetMap(
+		map[string]interface{}{
+			"key":          r.Key,
+			"request_hash": r.RequestHash,
+			"status_code":  0,
+			"response":     nil,
+			"created_at":   r.CreatedAt,
+			"expires_at":   r.ExpiresAt,
+			"locked_until": r.LockedUntil,
+		}).Suffix(`ON CONFLICT (key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash, status_code = 0, response = NULL,
+			created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at, locked_until = EXCLUDED.locked_until
+			WHERE data.idempotency_keys.expires_at <= EXCLUDED.created_at
+			OR (data.idempotency_keys.status_code = 0 AND data.idempotency_keys.locked_until <= EXCLUDED.created_at
+				AND data.idempotency_keys.request_hash = EXCLUDED.request_hash)
+			RETURNING key`).ToSql()
+	if err != nil {
+		return r, false, errors.Wrap(err, "cannot reserve idempotency key. failed to build query statement")
+	}
+
+	var key string
+	err = repo.pg.PGx.QueryRow(ctx, sql, args...).Scan(&key)
+	if err == nil {
+		return r, true, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return r, false, errors.Wrapf(postgresError(err), "could not reserve idempotency key %s", r.Key)
+	}
+
+	// the key is already reserved and has not expired yet.
+	var existing domain.IdempotencyRecord
+	sql, args, err = psql.Select("*").From("data.idempotency_keys").Where(sq.Eq{"key": r.Key}).ToSql()
+	if err != nil {
+		return r, false, errors.Wrap(err, "cannot get idempotency key. failed to build query statement")
+	}
+
+	err = pgxscan.Get(ctx, repo.pg.PGx, &existing, sql, args...)
+	return existing, false, errors.Wrapf(postgresError(err), "could not find idempotency key %s", r.Key)
+}
+
+func (repo PostgresIdempotencyRepository) Complete(ctx context.Context, key string, statusCode int, response []byte) error {
+	sql, args, err := psql.Update("data.idempotency_keys").
+		SetMap(map[string]interface{}{
+			"status_code": statusCode,
+			"response":    response,
+		}).Where(sq.Eq{"key": key}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "cannot complete idempotency key. failed to build query statement")
+	}
+
+	tag, err := repo.pg.PGx.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrapf(postgresError(err), "could not complete idempotency key %s", key)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return domain.NewError(domain.ErrNotFound, errors.Errorf("could not complete idempotency key %s. no such key", key))
+	}
+	return nil
+}
+
+func (repo PostgresIdempotencyRepository) Release(ctx context.Context, key string) error {
+	sql, args, err := psql.Delete("data.idempotency_keys").Where(sq.Eq{"key": key}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "cannot release idempotency key. failed to build query statement")
+	}
+
+	_, err = repo.pg.PGx.Exec(ctx, sql, args...)
+	return errors.Wrapf(postgresError(err), "could not release idempotency key %s", key)
+}
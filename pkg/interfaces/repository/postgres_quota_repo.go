@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/jeamon/backend-api/pkg/domain"
+	"github.com/jeamon/backend-api/pkg/infrastructure/postgres"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type PostgresQuotaRepository struct {
+	logger *zap.Logger
+	pg     *postgres.Handler
+}
+
+// NewPostgresQuotaRepository provides an instance of PostgresQuotaRepository structure.
+func NewPostgresQuotaRepository(logger *zap.Logger, h *postgres.Handler) *PostgresQuotaRepository {
+	return &PostgresQuotaRepository{
+		logger: logger,
+		pg:     h,
+	}
+}
+
+// Consume increments the usage in a single statement which only applies when
+// the limit is not exceeded, so that concurrent submissions cannot overrun it.
+func (repo PostgresQuotaRepository) Consume(ctx context.Context, companyID, day string, n, limit int64) (int64, bool, error) {
+	if limit > 0 && n > limit {
+		count, err := repo.count(ctx, companyID, day)
+		return count, false, err
+	}
+
+	sql, args, err := psql.Insert("data.quota_usages").SetMap(
+		map[string]interface{}{
+			"company_id": companyID,
+			"day":        day,
+			"count":      n,
+		}).Suffix(`ON CONFLICT (company_id, day) DO UPDATE SET count = data.quota_usages.count + EXCLUDED.count
+			WHERE ? < 1 OR data.quota_usages.count + EXCLUDED.count <= ?
+			RETURNING count`, limit, limit).ToSql()
+	if err != nil {
+		return 0, false, errors.Wrap(err, "cannot consume quota. failed to build query statement")
+	}
+
+	var count int64
+	err = repo.pg.PGx.QueryRow(ctx, sql, args...).Scan(&count)
+	if err == nil {
+		return count, true, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, errors.Wrapf(postgresError(err), "could not consume quota of company %s", companyID)
+	}
+
+	count, err = repo.count(ctx, companyID, day)
+	return count, false, err
+}
+
+func (repo PostgresQuotaRepository) count(ctx context.Context, companyID, day string) (int64, error) {
+	sql, args, err := psql.Select("count").From("data.quota_usages").
+		Where(sq.Eq{"company_id": companyID, "day": day}).ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "cannot get quota usage. failed to build query statement")
+	}
+
+	var count int64
+	err = repo.pg.PGx.QueryRow(ctx, sql, args...).Scan(&count)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return count, errors.Wrapf(postgresError(err), "could not get quota usage of company %s", companyID)
+}
+
+func (repo PostgresQuotaRepository) Refund(ctx context.Context, companyID, day string, n int64) error {
+	sql, args, err := psql.Update("data.quota_usages").
+		Set("count", sq.Expr("GREATEST(count - ?, 0)", n)).
+		Where(sq.Eq{"company_id": companyID, "day": day}).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "cannot refund quota. failed to build query statement")
+	}
+
+	_, err = repo.pg.PGx.Exec(ctx, sql, args...)
+	return errors.Wrapf(postgresError(err), "could not refund quota of company %s", companyID)
+}
+
+func (repo PostgresQuotaRepository) List(ctx context.Context, day string) ([]domain.QuotaUsage, error) {
+	usages := []domain.QuotaUsage{}
+	sql, args, err := psql.Select("company_id", "day", "count").From("data.quota_usages").
+		Where(sq.Eq{"day": day}).OrderBy("company_id").ToSql()
+	if err != nil {
+		return usages, errors.Wrap(err, "cannot list quota usages. failed to build query statement")
+	}
+
+	err = pgxscan.Select(ctx, repo.pg.PGx, &usages, sql, args...)
+	return usages, errors.Wrap(postgresError(err), "could not list quota usages")
+}
@@ -6,6 +6,7 @@ import (
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
 	"github.com/jeamon/backend-api/pkg/domain"
 	"github.com/jeamon/backend-api/pkg/infrastructure/postgres"
 	"github.com/pkg/errors"
@@ -28,30 +29,154 @@ func NewPostgresScanInfosRepository(logger *zap.Logger, h *postgres.Handler) *Po
 }
 
 // Save will create a new scan infos and not update existing one.
+// The scan infos record and its findings are stored in a single transaction.
 func (repo PostgresScanInfosRepository) Save(ctx context.Context, s domain.ScanInfos) (string, error) {
 	var id string
-	sql, args, err := psql.Insert("data.scan_infos").SetMap(
-		map[string]interface{}{
-			"company_id":     s.CompanyID,
-			"client_id":      s.ClientID,
-			"username":       s.Username,
-			"repository_url": s.RepositoryURL,
-			"commit_id":      s.CommitID,
-			"tag_id":         s.TagID,
-			"results":        s.Results,
-			"started_at":     s.StartedAt,
-			"completed_at":   s.CompletedAt,
-			"sent_at":        s.SentAt,
-			"created_at":     s.CreatedAt,
-			"updated_at":     s.UpdatedAt,
-			"metadata":       s.Metadata,
-		}).Suffix("RETURNING id").ToSql()
+	s = withStorageDefaults(s)
+	sql, args, err := postgresInsertScanInfos(s)
 	if err != nil {
 		return id, errors.Wrapf(err, "cannot save scan infos. failed to build query statement")
 	}
 
-	err = repo.pg.PGx.QueryRow(ctx, sql, args...).Scan(&id)
-	return id, errors.Wrapf(err, "could not save scan infos")
+	err = repo.pg.PGx.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, sql, args...).Scan(&id); err != nil {
+			return err
+		}
+		return insertFindings(ctx, tx, id, s.Findings)
+	})
+	return id, errors.Wrapf(postgresError(err), "could not save scan infos")
+}
+
+// SaveBatch creates all the scan infos in a single transaction: either all of
+// them are stored or none. The records are sent to the database as a single
+// batch of statements and their IDs are returned in the same order.
+func (repo PostgresScanInfosRepository) SaveBatch(ctx context.Context, infos []domain.ScanInfos) ([]string, error) {
+	batch := &pgx.Batch{}
+	items := make([]domain.ScanInfos, len(infos))
+	for i := range infos {
+		items[i] = withStorageDefaults(infos[i])
+		sql, args, err := postgresInsertScanInfos(items[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot save scan infos batch. failed to build query statement")
+		}
+		batch.Queue(sql, args...)
+	}
+
+	ids := make([]string, len(items))
+	err := repo.pg.PGx.BeginFunc(ctx, func(tx pgx.Tx) error {
+		results := tx.SendBatch(ctx, batch)
+		for i := range ids {
+			if err := results.QueryRow().Scan(&ids[i]); err != nil {
+				results.Close()
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+
+		if err := results.Close(); err != nil {
+			return err
+		}
+
+		for i := range items {
+			if err := insertFindings(ctx, tx, ids[i], items[i].Findings); err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(postgresError(err), "could not save scan infos batch")
+	}
+
+	return ids, nil
+}
+
+// postgresInsertScanInfos builds the statement inserting a scan infos record
+// and returning its generated ID.
+func postgresInsertScanInfos(s domain.ScanInfos) (string, []interface{}, error) {
+	return psql.Insert("data.scan_infos").SetMap(postgresScanInfosValues(s)).Suffix("RETURNING id").ToSql()
+}
+
+// postgresImportScanInfos builds the statement inserting a scan infos record
+// with its ID and version unless a record with the same ID exists.
+func postgresImportScanInfos(s domain.ScanInfos) (string, []interface{}, error) {
+	values := postgresScanInfosValues(s)
+	values["id"] = s.ID
+	values["version"] = s.Version
+	return psql.Insert("data.scan_infos").SetMap(values).Suffix("ON CONFLICT (id) DO NOTHING").ToSql()
+}
+
+func postgresScanInfosValues(s domain.ScanInfos) map[string]interface{} {
+	return map[string]interface{}{
+		"company_id":     s.CompanyID,
+		"client_id":      s.ClientID,
+		"username":       s.Username,
+		"repository_url": s.RepositoryURL,
+		"commit_id":      s.CommitID,
+		"tag_id":         s.TagID,
+		"results":        s.Results,
+		"started_at":     s.StartedAt,
+		"completed_at":   s.CompletedAt,
+		"sent_at":        s.SentAt,
+		"created_at":     s.CreatedAt,
+		"updated_at":     s.UpdatedAt,
+		"error":          s.Error,
+		"metadata":       s.Metadata,
+	}
+}
+
+// Import inserts all the scan infos with their IDs in a single transaction.
+// The findings are only inserted along with new records.
+func (repo PostgresScanInfosRepository) Import(ctx context.Context, infos []domain.ScanInfos) (int, error) {
+	batch := &pgx.Batch{}
+	items := make([]domain.ScanInfos, len(infos))
+	for i := range infos {
+		s, err := withImportDefaults(infos[i])
+		if err != nil {
+			return 0, errors.Wrapf(err, "cannot import scan infos. item %d", i)
+		}
+
+		sql, args, err := postgresImportScanInfos(s)
+		if err != nil {
+			return 0, errors.Wrapf(err, "cannot import scan infos. failed to build query statement")
+		}
+		items[i] = s
+		batch.Queue(sql, args...)
+	}
+
+	var imported int
+	err := repo.pg.PGx.BeginFunc(ctx, func(tx pgx.Tx) error {
+		results := tx.SendBatch(ctx, batch)
+		inserted := make([]bool, len(items))
+		for i := range items {
+			tag, err := results.Exec()
+			if err != nil {
+				results.Close()
+				return errors.Wrapf(err, "item %d", i)
+			}
+			inserted[i] = tag.RowsAffected() == 1
+		}
+
+		if err := results.Close(); err != nil {
+			return err
+		}
+
+		for i := range items {
+			if !inserted[i] {
+				continue
+			}
+
+			if err := insertFindings(ctx, tx, items[i].ID, items[i].Findings); err != nil {
+				return errors.Wrapf(err, "item %d", i)
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(postgresError(err), "could not import scan infos")
+	}
+
+	return imported, nil
 }
 
 func (repo PostgresScanInfosRepository) FindByID(ctx context.Context, id string) (domain.ScanInfos, error) {
@@ -62,22 +187,143 @@ func (repo PostgresScanInfosRepository) FindByID(ctx context.Context, id string)
 	}
 
 	err = pgxscan.Get(ctx, repo.pg.PGx, &s, sql, args...)
-	return s, errors.Wrapf(err, "could not find scan infos with ID: %s", id)
+	if err != nil {
+		return s, errors.Wrapf(postgresError(err), "could not find scan infos with ID: %s", id)
+	}
+
+	findings, err := loadFindings(ctx, repo.pg.PGx, id)
+	s.Findings = findings[id]
+	return s, errors.Wrapf(postgresError(err), "could not find findings of scan infos with ID: %s", id)
 }
 
-func (repo PostgresScanInfosRepository) FindAll(ctx context.Context) ([]domain.ScanInfos, error) {
-	sql, args, err := psql.Select("*").From("data.scan_infos").ToSql()
+// List returns a page of scan infos matching the query along with the total
+// number of matching records. It relies on keyset pagination so that deep
+// pages are as cheap to fetch as the first one.
+func (repo PostgresScanInfosRepository) List(ctx context.Context, q domain.ScanInfosQuery) (domain.ScanInfosPage, error) {
+	page := domain.ScanInfosPage{Infos: []domain.ScanInfos{}}
+	if err := q.Normalize(); err != nil {
+		return page, errors.Wrap(err, "cannot list scan infos")
+	}
+
+	cursor, err := q.DecodeCursor()
+	if err != nil {
+		return page, errors.Wrap(err, "cannot list scan infos")
+	}
+
+	where := postgresScanInfosFilter(q.Filter)
+	if !q.SkipTotal {
+		sql, args, err := psql.Select("COUNT(*)").From("data.scan_infos").Where(where).ToSql()
+		if err != nil {
+			return page, errors.Wrap(err, "cannot count scan infos. failed to build query statement")
+		}
+
+		if err = repo.pg.PGx.QueryRow(ctx, sql, args...).Scan(&page.Total); err != nil {
+			return page, errors.Wrap(postgresError(err), "could not count scan infos")
+		}
+	}
+
+	direction := "ASC"
+	if q.Descending {
+		direction = "DESC"
+	}
+
+	if cursor != nil {
+		where = append(where, postgresScanInfosAfter(q.SortBy, q.Descending, cursor))
+	}
+
+	sql, args, err := psql.Select("*").From("data.scan_infos").Where(where).
+		OrderBy(q.SortBy+" "+direction, "id "+direction).
+		Limit(uint64(q.Limit) + 1).ToSql()
+	if err != nil {
+		return page, errors.Wrap(err, "cannot list scan infos. failed to build query statement")
+	}
+
+	if err = pgxscan.Select(ctx, repo.pg.PGx, &page.Infos, sql, args...); err != nil {
+		return page, errors.Wrap(postgresError(err), "could not list scan infos")
+	}
+
+	if len(page.Infos) > q.Limit {
+		page.Infos = page.Infos[:q.Limit]
+		page.NextCursor = q.NextCursor(page.Infos[q.Limit-1])
+	}
+
+	ids := make([]string, len(page.Infos))
+	for i := range page.Infos {
+		ids[i] = page.Infos[i].ID
+	}
+
+	findings, err := loadFindings(ctx, repo.pg.PGx, ids...)
 	if err != nil {
-		return nil, err
+		return page, errors.Wrap(postgresError(err), "could not list findings of scan infos")
+	}
+
+	for i := range page.Infos {
+		page.Infos[i].Findings = findings[page.Infos[i].ID]
+	}
+
+	return page, nil
+}
+
+// postgresScanInfosFilter converts a listing filter into sql conditions.
+func postgresScanInfosFilter(f domain.ScanInfosFilter) sq.And {
+	where := sq.And{}
+	for _, c := range []struct{ column, value string }{
+		{"company_id", f.CompanyID},
+		{"client_id", f.ClientID},
+		{"repository_url", f.RepositoryURL},
+		{"commit_id", f.CommitID},
+		{"tag_id", f.TagID},
+	} {
+		if c.value != "" {
+			where = append(where, sq.Eq{c.column: c.value})
+		}
 	}
 
-	res := []domain.ScanInfos{}
-	err = pgxscan.Select(ctx, repo.pg.PGx, &res, sql, args...)
-	return res, errors.Wrapf(err, "could not find all scan infos")
+	if f.StartedFrom != 0 {
+		where = append(where, sq.GtOrEq{"started_at": f.StartedFrom})
+	}
+	if f.StartedTo != 0 {
+		where = append(where, sq.LtOrEq{"started_at": f.StartedTo})
+	}
+	if f.CompletedFrom != 0 {
+		where = append(where, sq.GtOrEq{"completed_at": f.CompletedFrom})
+	}
+	if f.CompletedTo != 0 {
+		where = append(where, sq.LtOrEq{"completed_at": f.CompletedTo})
+	}
+
+	if f.HasError != nil {
+		if *f.HasError {
+			where = append(where, sq.NotEq{"error": ""})
+		} else {
+			where = append(where, sq.Eq{"error": ""})
+		}
+	}
+
+	return where
 }
 
-// UpdateByID updates a scan infos record by its ID.
+// postgresScanInfosAfter builds the keyset condition selecting the records
+// located after the cursor for the given sort order.
+func postgresScanInfosAfter(sortBy string, descending bool, c *domain.ScanInfosCursor) sq.Sqlizer {
+	value := c.ColumnValue()
+	if descending {
+		return sq.Or{
+			sq.Lt{sortBy: value},
+			sq.And{sq.Eq{sortBy: value}, sq.Lt{"id": c.ID}},
+		}
+	}
+
+	return sq.Or{
+		sq.Gt{sortBy: value},
+		sq.And{sq.Eq{sortBy: value}, sq.Gt{"id": c.ID}},
+	}
+}
+
+// UpdateByID updates a scan infos record by its ID. The record version is
+// incremented and compared to the expected one in a single statement.
 func (repo PostgresScanInfosRepository) UpdateByID(ctx context.Context, id string, s domain.ScanInfos) error {
+	s = withStorageDefaults(s)
 	sql, args, err := psql.Update("data.scan_infos").SetMap(
 		map[string]interface{}{
 			"company_id":     s.CompanyID,
@@ -93,22 +339,143 @@ func (repo PostgresScanInfosRepository) UpdateByID(ctx context.Context, id strin
 			"updated_at":     time.Now().UTC(),
 			"error":          s.Error,
 			"metadata":       s.Metadata,
-		}).Where(sq.Eq{"id": id}).ToSql()
+			"version":        sq.Expr("version + 1"),
+		}).Where(postgresVersionedID(id, s.Version)).ToSql()
 	if err != nil {
 		return errors.Wrapf(err, "cannot update scan infos with ID: %s", id)
 	}
 
-	_, err = repo.pg.PGx.Exec(ctx, sql, args...)
-	return errors.Wrapf(err, "cannot update scan infos with ID: %s", id)
+	err = repo.pg.PGx.BeginFunc(ctx, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return postgresMissingReason(ctx, tx, id)
+		}
+
+		if s.Findings == nil {
+			return nil
+		}
+
+		if _, err := tx.Exec(ctx, "DELETE FROM data.scan_findings WHERE scan_id = $1", id); err != nil {
+			return err
+		}
+		return insertFindings(ctx, tx, id, s.Findings)
+	})
+	return errors.Wrapf(postgresError(err), "cannot update scan infos with ID: %s", id)
 }
 
 // DeleteByID deletes a scan infos record by its ID.
-func (repo PostgresScanInfosRepository) DeleteByID(ctx context.Context, id string) error {
-	sql, args, err := psql.Delete("data.scan_infos").Where(sq.Eq{"id": id}).ToSql()
+func (repo PostgresScanInfosRepository) DeleteByID(ctx context.Context, id string, version int64) error {
+	sql, args, err := psql.Delete("data.scan_infos").Where(postgresVersionedID(id, version)).ToSql()
 	if err != nil {
 		return errors.Wrapf(err, "cannot delete scan infos record with ID: %s", id)
 	}
 
-	_, err = repo.pg.PGx.Exec(ctx, sql, args...)
-	return errors.Wrapf(err, "could not delete scan infos with ID: %s", id)
+	tag, err := repo.pg.PGx.Exec(ctx, sql, args...)
+	if err != nil {
+		return errors.Wrapf(postgresError(err), "could not delete scan infos with ID: %s", id)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errors.Wrapf(postgresMissingReason(ctx, repo.pg.PGx, id), "could not delete scan infos with ID: %s", id)
+	}
+
+	return nil
+}
+
+// postgresVersionedID selects a record by its ID and, when the version is
+// not zero, by its version.
+func postgresVersionedID(id string, version int64) sq.Eq {
+	if version == 0 {
+		return sq.Eq{"id": id}
+	}
+	return sq.Eq{"id": id, "version": version}
+}
+
+// postgresMissingReason explains why a versioned write did not affect the
+// record: either it does not exist or its version changed.
+func postgresMissingReason(ctx context.Context, db interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}, id string) error {
+	var exists bool
+	err := db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM data.scan_infos WHERE id = $1)", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return domain.NewError(domain.ErrPreconditionFailed, errors.New("scan infos record version does not match"))
+	}
+	return domain.NewError(domain.ErrNotFound, errors.New("no such scan infos record"))
+}
+
+// scanFinding is a finding row along with the scan it belongs to.
+type scanFinding struct {
+	ScanID string `db:"scan_id"`
+	domain.Finding
+}
+
+// insertFindings stores the findings of a scan keeping their order.
+func insertFindings(ctx context.Context, tx pgx.Tx, scanID string, findings []domain.Finding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	builder := psql.Insert("data.scan_findings").Columns(
+		"scan_id", "position", "rule_id", "severity", "title", "file_path",
+		"start_line", "end_line", "fingerprint", "scanner", "properties",
+	)
+	for i, f := range findings {
+		properties := f.Properties
+		if properties == nil {
+			properties = map[string]interface{}{}
+		}
+		builder = builder.Values(
+			scanID, i, f.RuleID, f.Severity, f.Title, f.FilePath,
+			f.StartLine, f.EndLine, f.Fingerprint, f.Scanner, properties,
+		)
+	}
+
+	sql, args, err := builder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "failed to build findings insert statement")
+	}
+
+	_, err = tx.Exec(ctx, sql, args...)
+	return errors.Wrap(err, "could not save findings")
+}
+
+// loadFindings fetches the findings of the given scans grouped by scan ID.
+// Each requested scan gets a non-nil (possibly empty) list of findings.
+func loadFindings(ctx context.Context, db pgxscan.Querier, scanIDs ...string) (map[string][]domain.Finding, error) {
+	res := make(map[string][]domain.Finding, len(scanIDs))
+	if len(scanIDs) == 0 {
+		return res, nil
+	}
+
+	for _, id := range scanIDs {
+		res[id] = []domain.Finding{}
+	}
+
+	sql, args, err := psql.Select(
+		"scan_id", "rule_id", "severity", "title", "file_path",
+		"start_line", "end_line", "fingerprint", "scanner", "properties",
+	).From("data.scan_findings").Where(sq.Eq{"scan_id": scanIDs}).OrderBy("scan_id", "position").ToSql()
+	if err != nil {
+		return res, errors.Wrap(err, "failed to build findings select statement")
+	}
+
+	var rows []scanFinding
+	if err := pgxscan.Select(ctx, db, &rows, sql, args...); err != nil {
+		return res, err
+	}
+
+	for _, r := range rows {
+		res[r.ScanID] = append(res[r.ScanID], r.Finding)
+	}
+
+	return res, nil
 }